@@ -0,0 +1,604 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"mark-master-sheet/internal/backup"
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/excel"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/internal/lock"
+	"mark-master-sheet/internal/logger"
+	"mark-master-sheet/internal/processor"
+	"mark-master-sheet/internal/quarantine"
+	"mark-master-sheet/internal/watch"
+	"mark-master-sheet/pkg/models"
+)
+
+// watchDirName is the folder inside the output folder holding the log and summary of each watch run
+const watchDirName = "watch"
+
+// commandUsage describes the available subcommands
+const commandUsage = `Commands:
+  backups list                 List backups of the master sheet
+  backups restore [-yes] NAME  Restore a backup over the master sheet
+  backups prune                Remove backups according to retention rules
+  verify-backups               Re-check every backup against the manifest checksums
+  diff [-format text|csv|xlsx] [-o PATH] OLD [NEW]
+                               Compare two master sheets by student ID
+                               (NEW defaults to the configured master sheet)
+  undo [-yes] [RUN_ID]         Revert the cells written by a run (default: the last run)
+  clear-highlights             Remove update highlights and comments from the master sheet
+  unlock [-yes]                Remove a leftover master sheet lock file
+  watch                        Consolidate incrementally whenever submissions arrive
+                               (logs and summaries in OUTPUT/watch)
+  readmit [PATH...]            Move fixed files from the quarantine back to the student
+                               files folder (default: every quarantined file)
+`
+
+// runCommand dispatches a subcommand given after the global flags
+func runCommand(cfg *config.Config, log *logger.Logger, args []string, in io.Reader, out io.Writer) error {
+	switch args[0] {
+	case "backups":
+		return runBackupsCommand(cfg, log, args[1:], in, out)
+	case "verify-backups":
+		return runVerifyBackupsCommand(cfg, log, out)
+	case "diff":
+		return runDiffCommand(cfg, args[1:], out)
+	case "undo":
+		return runUndoCommand(cfg, log, args[1:], in, out)
+	case "clear-highlights":
+		return runClearHighlightsCommand(cfg, log, out)
+	case "unlock":
+		return runUnlockCommand(cfg, log, args[1:], in, out)
+	case "watch":
+		return runWatchCommand(cfg, log, args[1:], out)
+	case "readmit":
+		return runReadmitCommand(cfg, log, args[1:], out)
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], commandUsage)
+	}
+}
+
+// runBackupsCommand handles the backups list, restore and prune subcommands
+func runBackupsCommand(cfg *config.Config, log *logger.Logger, args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing backups subcommand\n\n%s", commandUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		backups, err := backup.List(cfg.Paths.BackupFolder, cfg.Paths.MasterSheetPath)
+		if err != nil {
+			return err
+		}
+		printBackups(out, backups)
+		return nil
+
+	case "restore":
+		fs := flag.NewFlagSet("backups restore", flag.ContinueOnError)
+		fs.SetOutput(out)
+		yes := fs.Bool("yes", false, "Restore without asking for confirmation")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: backups restore [-yes] NAME")
+		}
+
+		target, err := backup.Find(cfg.Paths.BackupFolder, cfg.Paths.MasterSheetPath, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		if !*yes && !confirm(in, out, fmt.Sprintf("Restore %s over %s?", target.Name, cfg.Paths.MasterSheetPath)) {
+			fmt.Fprintln(out, "Restore cancelled")
+			return nil
+		}
+
+		masterLock, err := acquireMasterLock(cfg, log, "backups restore")
+		if err != nil {
+			return err
+		}
+		defer releaseMasterLock(masterLock, log)
+
+		safetyBackup, err := backup.Restore(target.Path, cfg.Paths.MasterSheetPath, cfg.Paths.BackupFolder)
+		if err != nil && !backup.ManifestOnly(err) {
+			return err
+		}
+		if err != nil {
+			log.WithError(err).Warn("Backup restored with a problem in the backup manifest")
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+		if safetyBackup != "" {
+			log.LogBackupCreated(cfg.Paths.MasterSheetPath, safetyBackup)
+			fmt.Fprintf(out, "Previous master sheet backed up to: %s\n", safetyBackup)
+		}
+		log.WithField("backup_path", target.Path).Info("Backup restored over master sheet")
+		fmt.Fprintf(out, "Restored %s\n", target.Name)
+		return nil
+
+	case "prune":
+		if !cfg.Backup.HasRetention() {
+			fmt.Fprintln(out, "No retention rules configured in [backup]; nothing to prune")
+			return nil
+		}
+		pruned, err := backup.Prune(cfg.Paths.BackupFolder, cfg.Paths.MasterSheetPath, cfg.Backup)
+		for _, b := range pruned {
+			log.LogBackupPruned(b.Info.Path, b.Reason)
+			fmt.Fprintf(out, "Removed %s (%s)\n", b.Info.Name, b.Reason)
+		}
+		if err != nil && !backup.ManifestOnly(err) {
+			return err
+		}
+		if err != nil {
+			log.WithError(err).Warn("Backups pruned with a problem in the backup manifest")
+			fmt.Fprintf(out, "Warning: %v\n", err)
+		}
+		fmt.Fprintf(out, "Removed %d backup(s)\n", len(pruned))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown backups subcommand %q\n\n%s", args[0], commandUsage)
+	}
+}
+
+// runVerifyBackupsCommand re-checks every backup recorded in the manifest
+func runVerifyBackupsCommand(cfg *config.Config, log *logger.Logger, out io.Writer) error {
+	results, err := backup.Verify(cfg.Paths.BackupFolder)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No backups recorded in the manifest")
+		return nil
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.OK {
+			fmt.Fprintf(out, "OK      %s (run %s)\n", result.Entry.BackupFile, result.Entry.RunID)
+			continue
+		}
+		failed++
+		log.WithField("backup_file", result.Entry.BackupFile).Error("Backup verification failed: ", result.Problem)
+		fmt.Fprintf(out, "FAILED  %s (run %s): %s\n", result.Entry.BackupFile, result.Entry.RunID, result.Problem)
+	}
+
+	fmt.Fprintf(out, "\n%d backup(s) verified, %d failed\n", len(results), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d backup(s) failed verification", failed)
+	}
+	return nil
+}
+
+// runDiffCommand compares two master sheets and reports the differences
+func runDiffCommand(cfg *config.Config, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(out)
+	format := fs.String("format", "text", "Output format: text, csv or xlsx")
+	outputPath := fs.String("o", "", "Write the report to this file instead of the console")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: diff [-format text|csv|xlsx] [-o PATH] OLD [NEW]")
+	}
+
+	oldPath, newPath := fs.Arg(0), cfg.Paths.MasterSheetPath
+	if fs.NArg() == 2 {
+		newPath = fs.Arg(1)
+	}
+
+	reader := excel.NewReader(&cfg.Excel)
+	diff, err := reader.DiffMasterSheets(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	if *format == "xlsx" {
+		if *outputPath == "" {
+			return fmt.Errorf("diff -format xlsx requires -o PATH")
+		}
+		if err := diff.SaveWorkbook(*outputPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Diff workbook saved to: %s (%d added, %d removed, %d changed cells)\n",
+			*outputPath, len(diff.Added), len(diff.Removed), len(diff.Changed))
+		return nil
+	}
+
+	w := out
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create diff report: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "text":
+		err = diff.WriteText(w)
+	case "csv":
+		err = diff.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown diff format %q (use text, csv or xlsx)", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *outputPath != "" {
+		fmt.Fprintf(out, "Diff report saved to: %s\n", *outputPath)
+	}
+	return nil
+}
+
+// runUndoCommand reverts the cells written by a journaled run
+func runUndoCommand(cfg *config.Config, log *logger.Logger, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	fs.SetOutput(out)
+	yes := fs.Bool("yes", false, "Undo without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: undo [-yes] [RUN_ID]")
+	}
+
+	journalDir := journal.Dir(cfg.Paths.OutputFolder)
+	var j *journal.Journal
+	var err error
+	if fs.NArg() == 1 {
+		j, err = journal.Find(journalDir, fs.Arg(0))
+	} else {
+		j, err = journal.Latest(journalDir, cfg.Paths.MasterSheetPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if j.UndoneAt != nil {
+		return fmt.Errorf("run %s was already undone at %s", j.RunID, j.UndoneAt.Format("2006-01-02 15:04:05"))
+	}
+	if !journal.SameFile(j.MasterSheetPath, cfg.Paths.MasterSheetPath) {
+		return fmt.Errorf("run %s wrote to %s, not the configured master sheet", j.RunID, j.MasterSheetPath)
+	}
+
+	question := fmt.Sprintf("Revert %d cell write(s) made by run %s on %s?",
+		len(j.Entries), j.RunID, j.CreatedAt.Format("2006-01-02 15:04:05"))
+	if !*yes && !confirm(in, out, question) {
+		fmt.Fprintln(out, "Undo cancelled")
+		return nil
+	}
+
+	masterLock, err := acquireMasterLock(cfg, log, "undo "+j.RunID)
+	if err != nil {
+		return err
+	}
+	defer releaseMasterLock(masterLock, log)
+
+	safetyBackup, err := backup.Create(cfg.Paths.MasterSheetPath, cfg.Paths.BackupFolder, "undo-"+j.RunID)
+	if err != nil && !backup.ManifestOnly(err) {
+		return fmt.Errorf("failed to back up master sheet before undo: %w", err)
+	}
+	if err != nil {
+		log.WithField("backup_path", safetyBackup).WithError(err).Warn("Backup created with a problem in the backup manifest")
+	}
+	log.LogBackupCreated(cfg.Paths.MasterSheetPath, safetyBackup)
+
+	writer := excel.NewWriter(&cfg.Excel)
+	result, err := writer.UndoJournal(cfg.Paths.MasterSheetPath, j)
+	if err != nil {
+		return err
+	}
+	if _, err := j.Save(journalDir); err != nil {
+		return err
+	}
+
+	log.WithField("run_id", j.RunID).WithField("reverted", result.Reverted).
+		WithField("conflicts", len(result.Conflicts)).Info("Run undone")
+
+	for _, c := range result.Conflicts {
+		fmt.Fprintf(out, "CONFLICT  %s (student %s): run wrote %q, now %q - left unchanged\n",
+			c.Entry.Cell, c.Entry.StudentID, c.Entry.NewValue, c.CurrentValue)
+	}
+	for _, row := range result.KeptRows {
+		fmt.Fprintf(out, "KEPT      row %d (student %s): added by the run but changed since - left in place\n",
+			row.Row, row.StudentID)
+	}
+	fmt.Fprintf(out, "Reverted %d cell(s) from run %s; %d conflict(s)\n", result.Reverted, j.RunID, len(result.Conflicts))
+	if result.RemovedRows > 0 {
+		fmt.Fprintf(out, "Removed %d row(s) added by the run\n", result.RemovedRows)
+	}
+	if result.Recalculated > 0 {
+		fmt.Fprintf(out, "Recalculated %d dependent formula(s)\n", result.Recalculated)
+	}
+	for _, msg := range result.FormulaErrors {
+		fmt.Fprintf(out, "  %s\n", msg)
+	}
+	fmt.Fprintf(out, "Master sheet before undo backed up to: %s\n", safetyBackup)
+
+	if len(result.Conflicts) > 0 {
+		return fmt.Errorf("%d cell(s) changed since run %s and were not reverted", len(result.Conflicts), j.RunID)
+	}
+	return nil
+}
+
+// runClearHighlightsCommand removes the update highlights and comments from the master sheet
+func runClearHighlightsCommand(cfg *config.Config, log *logger.Logger, out io.Writer) error {
+	masterLock, err := acquireMasterLock(cfg, log, "clear-highlights")
+	if err != nil {
+		return err
+	}
+	defer releaseMasterLock(masterLock, log)
+
+	journals, err := journal.List(journal.Dir(cfg.Paths.OutputFolder))
+	if err != nil {
+		return err
+	}
+
+	writer := excel.NewWriter(&cfg.Excel)
+	cleared, removed, err := writer.ClearHighlights(cfg.Paths.MasterSheetPath, journals)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("cells", cleared).WithField("comments", removed).Info("Update highlights cleared")
+	fmt.Fprintf(out, "Cleared highlight from %d cell(s) and removed %d comment(s)\n", cleared, removed)
+	return nil
+}
+
+// runUnlockCommand removes a master sheet lock left behind by a run that did not finish
+func runUnlockCommand(cfg *config.Config, log *logger.Logger, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	fs.SetOutput(out)
+	yes := fs.Bool("yes", false, "Remove the lock without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	holder, err := lock.Read(cfg.Paths.MasterSheetPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintln(out, "Master sheet is not locked")
+			return nil
+		}
+		fmt.Fprintf(out, "Lock file is unreadable: %v\n", err)
+	} else {
+		state := "active"
+		if lock.IsStale(*holder, cfg.Processing.LockStaleAfter()) {
+			state = "stale"
+		}
+		fmt.Fprintf(out, "Locked by %s (%s)\n", holder, state)
+	}
+
+	if !*yes && !confirm(in, out, "Remove the lock? Only do this if that run is no longer running.") {
+		fmt.Fprintln(out, "Unlock cancelled")
+		return nil
+	}
+
+	if err := lock.Remove(cfg.Paths.MasterSheetPath); err != nil {
+		return err
+	}
+	log.WithField("lock_path", lock.Path(cfg.Paths.MasterSheetPath)).Warn("Master sheet lock removed manually")
+	fmt.Fprintln(out, "Lock removed")
+	return nil
+}
+
+// runWatchCommand watches the student files folder until interrupted, consolidating new and changed files
+func runWatchCommand(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return watchStudentFiles(ctx, cfg, log, out)
+}
+
+// watchStudentFiles runs an incremental consolidation at start and after every batch of changed
+// submissions until ctx is cancelled
+func watchStudentFiles(ctx context.Context, cfg *config.Config, log *logger.Logger, out io.Writer) error {
+	cfg.Processing.Incremental = true
+
+	watcher, err := watch.New(cfg.Paths.StudentFilesFolder, cfg.Watch.Debounce(), cfg.Watch.Settle(), func(err error) {
+		log.WithError(err).Warn("Watch error")
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	trigger := func(ctx context.Context, changed []string) error {
+		return runWatchTrigger(ctx, cfg, log, out, changed)
+	}
+
+	// Catch up with submissions that arrived while nothing was watching
+	fmt.Fprintf(out, "Watching %s (press Ctrl+C to stop)\n", cfg.Paths.StudentFilesFolder)
+	if err := trigger(ctx, nil); err != nil && ctx.Err() == nil {
+		log.WithError(err).Warn("Initial watch run failed")
+	}
+
+	err = watcher.Run(ctx, trigger)
+	fmt.Fprintln(out, "Watch stopped")
+	return err
+}
+
+// watchRun is the summary file written for each run started by watch mode
+type watchRun struct {
+	TriggeredAt time.Time                 `json:"triggered_at"`
+	Changed     []string                  `json:"changed,omitempty"`
+	LogPath     string                    `json:"log_path"`
+	Summary     *models.ProcessingSummary `json:"summary,omitempty"`
+	Error       string                    `json:"error,omitempty"`
+}
+
+// runWatchTrigger runs one incremental consolidation with its own run log and summary file.
+// Runs blocked by the master sheet lock return the error, so the watcher tries again later.
+func runWatchTrigger(ctx context.Context, cfg *config.Config, log *logger.Logger, out io.Writer, changed []string) error {
+	run := watchRun{TriggeredAt: time.Now(), Changed: changed}
+	dir := filepath.Join(cfg.Paths.OutputFolder, watchDirName)
+	name := run.TriggeredAt.Format("20060102-150405.000")
+	run.LogPath = filepath.Join(dir, name+".log")
+
+	runLog, closeLog, err := log.WithRunLog(run.LogPath)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	runLog.WithField("changed", len(changed)).Info("Watch run started")
+
+	runCtx := ctx
+	if cfg.Processing.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, time.Duration(cfg.Processing.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	summary, runErr := processor.NewProcessor(cfg, runLog).ProcessFiles(runCtx, false)
+	run.Summary = summary
+	if runErr != nil {
+		run.Error = runErr.Error()
+		runLog.WithError(runErr).Error("Watch run failed")
+	}
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watch run summary: %w", err)
+	}
+	summaryPath := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(summaryPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write watch run summary: %w", err)
+	}
+
+	stamp := run.TriggeredAt.Format("15:04:05")
+	switch {
+	case runErr != nil:
+		fmt.Fprintf(out, "[%s] run failed: %v (see %s)\n", stamp, runErr, run.LogPath)
+	default:
+		fmt.Fprintf(out, "[%s] run %s: %d read, %d unchanged, %d students updated, %d errors (see %s)\n",
+			stamp, summary.RunID, summary.SuccessfulFiles+summary.FailedFiles+summary.SkippedFiles,
+			summary.UnchangedFiles, summary.StudentsUpdated, len(summary.Errors), summaryPath)
+	}
+
+	if errors.Is(runErr, lock.ErrLocked) || errors.Is(runErr, lock.ErrOpenInExcel) {
+		return runErr
+	}
+	return nil
+}
+
+// runReadmitCommand moves quarantined files that now pass validation back to the student files folder
+func runReadmitCommand(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("readmit", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	entries, err := quarantine.List(cfg.QuarantineFolder())
+	if err != nil {
+		return err
+	}
+
+	// Select the files given as paths relative to the quarantine folder, or all of them
+	selected := entries
+	if fs.NArg() > 0 {
+		selected = nil
+		for _, arg := range fs.Args() {
+			found := false
+			for _, entry := range entries {
+				if entry.Reason.RelativePath == filepath.ToSlash(arg) || entry.Path == arg {
+					selected = append(selected, entry)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s is not in the quarantine folder %s", arg, cfg.QuarantineFolder())
+			}
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(out, "No quarantined files")
+		return nil
+	}
+
+	reader := excel.NewReader(&cfg.Excel)
+	readmitted := 0
+	for _, entry := range selected {
+		name := entry.Reason.RelativePath
+		if _, err := reader.ReadStudentData(entry.Path); err != nil {
+			fmt.Fprintf(out, "Still invalid: %s: %v\n", name, err)
+			continue
+		}
+		if err := quarantine.Readmit(entry); err != nil {
+			fmt.Fprintf(out, "Not re-admitted: %s: %v\n", name, err)
+			continue
+		}
+		log.WithField("file_path", entry.Reason.Source).Info("File re-admitted from quarantine")
+		fmt.Fprintf(out, "Re-admitted %s\n", name)
+		readmitted++
+	}
+
+	fmt.Fprintf(out, "%d of %d file(s) re-admitted\n", readmitted, len(selected))
+	return nil
+}
+
+// acquireMasterLock takes the master sheet lock for a command that writes to the master
+func acquireMasterLock(cfg *config.Config, log *logger.Logger, command string) (*lock.Lock, error) {
+	masterLock, err := lock.Acquire(cfg.Paths.MasterSheetPath, command, cfg.Processing.LockStaleAfter())
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("lock_path", masterLock.Path).Debug("Master sheet lock acquired")
+	return masterLock, nil
+}
+
+// releaseMasterLock releases a lock taken by acquireMasterLock, logging failures
+func releaseMasterLock(masterLock *lock.Lock, log *logger.Logger) {
+	if err := masterLock.Release(); err != nil {
+		log.WithError(err).Warn("Failed to release master sheet lock")
+	}
+}
+
+// printBackups prints a table of backups to the given writer
+func printBackups(out io.Writer, backups []backup.Info) {
+	if len(backups) == 0 {
+		fmt.Fprintln(out, "No backups found")
+		return
+	}
+
+	fmt.Fprintf(out, "%-4s %-20s %10s  %s\n", "#", "Created", "Size", "Name")
+	for i, b := range backups {
+		fmt.Fprintf(out, "%-4d %-20s %10s  %s\n",
+			i+1, b.CreatedAt.Format("2006-01-02 15:04:05"), backup.FormatSize(b.Size), b.Name)
+	}
+	fmt.Fprintf(out, "\n%d backup(s), newest created %s ago\n",
+		len(backups), time.Since(backups[0].CreatedAt).Round(time.Second))
+}
+
+// confirm asks a yes/no question and reports whether the answer was yes
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
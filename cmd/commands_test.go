@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/backup"
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/excel"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/internal/lock"
+	"mark-master-sheet/internal/logger"
+	"mark-master-sheet/internal/quarantine"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestRunCommandUnknown tests that unknown commands are rejected
+func TestRunCommandUnknown(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"bogus"}, strings.NewReader(""), &out); err == nil {
+		t.Error("runCommand() expected error for unknown command")
+	}
+	if err := runCommand(cfg, log, []string{"backups"}, strings.NewReader(""), &out); err == nil {
+		t.Error("runCommand() expected error for missing backups subcommand")
+	}
+}
+
+// TestBackupsCommand tests listing and restoring backups from the command line
+func TestBackupsCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+
+	backupPath, err := backup.Create(cfg.Paths.MasterSheetPath, cfg.Paths.BackupFolder, "test-run")
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	os.WriteFile(cfg.Paths.MasterSheetPath, []byte("changed"), 0644)
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"backups", "list"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("backups list error = %v", err)
+	}
+	if !strings.Contains(out.String(), filepath.Base(backupPath)) {
+		t.Errorf("backups list output missing backup name: %s", out.String())
+	}
+
+	// Declining the confirmation must leave the master untouched
+	out.Reset()
+	args := []string{"backups", "restore", filepath.Base(backupPath)}
+	if err := runCommand(cfg, log, args, strings.NewReader("n\n"), &out); err != nil {
+		t.Fatalf("backups restore error = %v", err)
+	}
+	if content, _ := os.ReadFile(cfg.Paths.MasterSheetPath); string(content) != "changed" {
+		t.Errorf("backups restore changed master after declined confirmation")
+	}
+
+	out.Reset()
+	if err := runCommand(cfg, log, args, strings.NewReader("y\n"), &out); err != nil {
+		t.Fatalf("backups restore error = %v", err)
+	}
+	if content, _ := os.ReadFile(cfg.Paths.MasterSheetPath); string(content) != "original" {
+		t.Errorf("backups restore master content = %q, want %q", content, "original")
+	}
+}
+
+func createCommandTestConfig(t *testing.T) (*config.Config, *logger.Logger) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{
+		Paths: config.PathsConfig{
+			MasterSheetPath: filepath.Join(tempDir, "master.xlsx"),
+			BackupFolder:    filepath.Join(tempDir, "backups"),
+			OutputFolder:    filepath.Join(tempDir, "output"),
+		},
+	}
+	if err := os.WriteFile(cfg.Paths.MasterSheetPath, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create master file: %v", err)
+	}
+
+	log, err := logger.NewLogger(&config.LoggingConfig{Level: "ERROR", ConsoleOutput: true}, tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	return cfg, log
+}
+
+// TestVerifyBackupsCommand tests the verify-backups command
+func TestVerifyBackupsCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+
+	backupPath, err := backup.Create(cfg.Paths.MasterSheetPath, cfg.Paths.BackupFolder, "test-run")
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"verify-backups"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("verify-backups error = %v", err)
+	}
+
+	os.WriteFile(backupPath, []byte("tampered"), 0644)
+	out.Reset()
+	if err := runCommand(cfg, log, []string{"verify-backups"}, strings.NewReader(""), &out); err == nil {
+		t.Error("verify-backups expected error for tampered backup")
+	}
+	if !strings.Contains(out.String(), "FAILED") {
+		t.Errorf("verify-backups output should report the failure: %s", out.String())
+	}
+}
+
+// TestDiffCommand tests comparing master sheets from the command line
+func TestDiffCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+	cfg.Excel.MasterWorksheetName = "001"
+
+	tempDir := t.TempDir()
+	oldPath := filepath.Join(tempDir, "old.xlsx")
+	for path, mark := range map[string]int{oldPath: 70, cfg.Paths.MasterSheetPath: 75} {
+		f := excelize.NewFile()
+		f.SetSheetName("Sheet1", "001")
+		f.SetSheetRow("001", "A1", &[]interface{}{"Name", "Student ID", "Mark"})
+		f.SetSheetRow("001", "A2", &[]interface{}{"John Doe", "STU001", mark})
+		if err := f.SaveAs(path); err != nil {
+			t.Fatalf("Failed to create master file: %v", err)
+		}
+		f.Close()
+	}
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"diff", oldPath}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("diff error = %v", err)
+	}
+	if !strings.Contains(out.String(), `"70" -> "75"`) {
+		t.Errorf("diff output missing changed cell: %s", out.String())
+	}
+
+	csvPath := filepath.Join(tempDir, "diff.csv")
+	out.Reset()
+	if err := runCommand(cfg, log, []string{"diff", "-format", "csv", "-o", csvPath, oldPath}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("diff -format csv error = %v", err)
+	}
+	if content, _ := os.ReadFile(csvPath); !strings.Contains(string(content), "changed,STU001,C") {
+		t.Errorf("diff CSV content = %q", content)
+	}
+
+	if err := runCommand(cfg, log, []string{"diff", "-format", "xlsx", oldPath}, strings.NewReader(""), &out); err == nil {
+		t.Error("diff -format xlsx expected error without -o")
+	}
+}
+
+// TestUndoCommand tests reverting the last run from the command line
+func TestUndoCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+	cfg.Excel = config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"C"},
+	}
+
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "001")
+	f.SetSheetRow("001", "A1", &[]interface{}{"Name", "Student ID", "Mark"})
+	f.SetSheetRow("001", "A2", &[]interface{}{"John Doe", "STU001", 70})
+	if err := f.SaveAs(cfg.Paths.MasterSheetPath); err != nil {
+		t.Fatalf("Failed to create master file: %v", err)
+	}
+	f.Close()
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"undo", "-yes"}, strings.NewReader(""), &out); err == nil {
+		t.Error("undo expected error when no run has been journaled")
+	}
+
+	writer := excel.NewWriter(&cfg.Excel)
+	writer.SetRunID("run-1")
+	students := []*models.StudentData{{StudentID: "STU001", Marks: map[string]float64{"C6": 85}}}
+	if _, err := writer.BatchUpdateMasterSheet(cfg.Paths.MasterSheetPath, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if _, err := writer.LastJournal().Save(journal.Dir(cfg.Paths.OutputFolder)); err != nil {
+		t.Fatalf("Failed to save journal: %v", err)
+	}
+
+	out.Reset()
+	if err := runCommand(cfg, log, []string{"undo", "-yes"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("undo error = %v\n%s", err, out.String())
+	}
+
+	f, _ = excelize.OpenFile(cfg.Paths.MasterSheetPath)
+	defer f.Close()
+	if value, _ := f.GetCellValue("001", "C2"); value != "70" {
+		t.Errorf("undo left C2 = %q, want 70", value)
+	}
+
+	// A run can only be undone once
+	if err := runCommand(cfg, log, []string{"undo", "-yes", "run-1"}, strings.NewReader(""), &out); err == nil {
+		t.Error("undo expected error for a run that was already undone")
+	}
+}
+
+// TestUnlockCommand tests reporting and removing a leftover lock
+func TestUnlockCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"unlock", "-yes"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("unlock without lock error = %v", err)
+	}
+	if !strings.Contains(out.String(), "not locked") {
+		t.Errorf("unlock output = %q", out.String())
+	}
+
+	if _, err := lock.Acquire(cfg.Paths.MasterSheetPath, "run abandoned", 0); err != nil {
+		t.Fatalf("Failed to take lock: %v", err)
+	}
+	if err := runCommand(cfg, log, []string{"clear-highlights"}, strings.NewReader(""), &out); !errors.Is(err, lock.ErrLocked) {
+		t.Errorf("clear-highlights error = %v, want ErrLocked", err)
+	}
+
+	out.Reset()
+	if err := runCommand(cfg, log, []string{"unlock"}, strings.NewReader("y\n"), &out); err != nil {
+		t.Fatalf("unlock error = %v", err)
+	}
+	if !strings.Contains(out.String(), "run abandoned") || !strings.Contains(out.String(), "Lock removed") {
+		t.Errorf("unlock output = %q", out.String())
+	}
+	if _, err := lock.Read(cfg.Paths.MasterSheetPath); err == nil {
+		t.Error("lock still present after unlock")
+	}
+}
+
+// TestWatchStudentFiles tests that watch mode consolidates new submissions with a log and summary per run
+func TestWatchStudentFiles(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+	root := filepath.Dir(cfg.Paths.MasterSheetPath)
+	cfg.Paths.StudentFilesFolder = filepath.Join(root, "students")
+	cfg.Excel = config.ExcelConfig{
+		StudentWorksheetName: "Grading Sheet",
+		MasterWorksheetName:  "001",
+		StudentIDCell:        "B2",
+		MarkCells:            []string{"C6"},
+		MasterColumns:        []string{"C"},
+	}
+	cfg.Processing = config.ProcessingConfig{MaxConcurrentFiles: 2, RetryAttempts: 1, SkipInvalidFiles: true}
+	cfg.Watch = config.WatchConfig{DebounceSeconds: 0.05, SettleSeconds: 0.05}
+
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "001")
+	f.SetSheetRow("001", "A1", &[]interface{}{"Name", "Student ID", "Mark"})
+	f.SetSheetRow("001", "A2", &[]interface{}{"John Doe", "STU001"})
+	f.SetSheetRow("001", "A3", &[]interface{}{"Jane Smith", "STU002"})
+	if err := f.SaveAs(cfg.Paths.MasterSheetPath); err != nil {
+		t.Fatalf("Failed to create master file: %v", err)
+	}
+	f.Close()
+
+	writeSubmission := func(studentID string, mark float64) string {
+		f := excelize.NewFile()
+		defer f.Close()
+		f.SetSheetName("Sheet1", "Grading Sheet")
+		f.SetCellValue("Grading Sheet", "B2", studentID)
+		f.SetCellValue("Grading Sheet", "C6", mark)
+		path := filepath.Join(cfg.Paths.StudentFilesFolder, studentID+".xlsx")
+		if err := f.SaveAs(path); err != nil {
+			t.Fatalf("Failed to create student file: %v", err)
+		}
+		return path
+	}
+	if err := os.MkdirAll(cfg.Paths.StudentFilesFolder, 0755); err != nil {
+		t.Fatalf("Failed to create student folder: %v", err)
+	}
+	writeSubmission("STU001", 70)
+
+	// waitForRuns returns the watch run summaries once there are n of them
+	watchDir := filepath.Join(cfg.Paths.OutputFolder, watchDirName)
+	waitForRuns := func(n int) []watchRun {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			matches, _ := filepath.Glob(filepath.Join(watchDir, "*.json"))
+			if len(matches) >= n {
+				var runs []watchRun
+				for _, path := range matches {
+					data, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatalf("Failed to read summary: %v", err)
+					}
+					var run watchRun
+					if err := json.Unmarshal(data, &run); err != nil {
+						t.Fatalf("Failed to parse summary %s: %v", path, err)
+					}
+					runs = append(runs, run)
+				}
+				return runs
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("watch did not record %d runs", n)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- watchStudentFiles(ctx, cfg, log, &out) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The initial run catches up with the existing submission
+	runs := waitForRuns(1)
+	if s := runs[0].Summary; s == nil || s.StudentsUpdated != 1 || runs[0].Error != "" {
+		t.Fatalf("initial run = %+v, want STU001 updated", runs[0])
+	}
+	if _, err := os.Stat(runs[0].LogPath); err != nil {
+		t.Errorf("initial run log missing: %v", err)
+	}
+
+	// A late submission triggers an incremental run for that file only
+	late := writeSubmission("STU002", 85)
+	runs = waitForRuns(2)
+	second := runs[1]
+	if len(second.Changed) != 1 || second.Changed[0] != late {
+		t.Errorf("second run changed = %v, want %s", second.Changed, late)
+	}
+	if s := second.Summary; s == nil || s.UnchangedFiles != 1 || s.StudentsUpdated != 1 {
+		t.Errorf("second run summary = %+v, want 1 unchanged and 1 updated", s)
+	}
+}
+
+// TestReadmitCommand tests that only quarantined files that now pass validation are re-admitted
+func TestReadmitCommand(t *testing.T) {
+	cfg, log := createCommandTestConfig(t)
+	cfg.Paths.StudentFilesFolder = filepath.Join(filepath.Dir(cfg.Paths.MasterSheetPath), "students")
+	cfg.Excel = config.ExcelConfig{StudentWorksheetName: "Grading Sheet", StudentIDCell: "B2", MarkCells: []string{"C6"}}
+	cfg.Quarantine.Mode = config.QuarantineMove
+
+	fixed := filepath.Join(cfg.Paths.StudentFilesFolder, "alice", "STU001.xlsx")
+	broken := filepath.Join(cfg.Paths.StudentFilesFolder, "bob", "broken.xlsx")
+	for _, path := range []string{fixed, broken} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("not a workbook"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	q := quarantine.New(cfg.QuarantineFolder(), cfg.Paths.StudentFilesFolder, config.QuarantineMove, "run-1")
+	for _, path := range []string{fixed, broken} {
+		if _, err := q.Add(path, &models.FileProcessingError{FilePath: path, Stage: "opening", Message: "failed to open Excel file"}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	// Fix one of them in the quarantine folder
+	f := excelize.NewFile()
+	f.SetSheetName("Sheet1", "Grading Sheet")
+	f.SetCellValue("Grading Sheet", "B2", "STU001")
+	f.SetCellValue("Grading Sheet", "C6", 70)
+	if err := f.SaveAs(filepath.Join(cfg.QuarantineFolder(), "alice", "STU001.xlsx")); err != nil {
+		t.Fatalf("Failed to fix file: %v", err)
+	}
+	f.Close()
+
+	var out bytes.Buffer
+	if err := runCommand(cfg, log, []string{"readmit", "missing.xlsx"}, strings.NewReader(""), &out); err == nil {
+		t.Error("readmit of a file not in quarantine succeeded")
+	}
+
+	out.Reset()
+	if err := runCommand(cfg, log, []string{"readmit"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("readmit error = %v", err)
+	}
+	for _, want := range []string{"Re-admitted alice/STU001.xlsx", "Still invalid: bob/broken.xlsx", "1 of 2 file(s) re-admitted"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("readmit output = %q, want %q", out.String(), want)
+		}
+	}
+	if _, err := os.Stat(fixed); err != nil {
+		t.Errorf("fixed file not back in the student folder: %v", err)
+	}
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Errorf("broken file re-admitted: %v", err)
+	}
+}
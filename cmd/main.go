@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -12,6 +13,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/schollz/progressbar/v3"
+	"mark-master-sheet/internal/checkpoint"
 	"mark-master-sheet/internal/config"
 	"mark-master-sheet/internal/logger"
 	"mark-master-sheet/internal/processor"
@@ -23,6 +26,10 @@ var (
 	dryRun     = flag.Bool("dry-run", false, "Run in dry-run mode (no actual changes)")
 	showStats  = flag.Bool("stats", false, "Show processing statistics and exit")
 	version    = flag.Bool("version", false, "Show version information")
+	overwrite  = flag.Bool("overwrite-protected", false, "Overwrite formula, locked and merged cells in the master sheet")
+	increment  = flag.Bool("incremental", false, "Only read new or changed files and only write marks that differ")
+	fullRun    = flag.Bool("full", false, "Read every file and rewrite every mark, even when incremental is configured")
+	resume     = flag.Bool("resume", false, "Continue an interrupted run, skipping the files it already read")
 )
 
 const (
@@ -32,6 +39,11 @@ const (
 )
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s", commandUsage)
+	}
 	flag.Parse()
 
 	// Show version and exit
@@ -55,6 +67,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Explicit override of the protected cell checks
+	if *overwrite {
+		cfg.Excel.OverwriteProtected = true
+	}
+
+	// Incremental processing, unless a full rebuild is forced
+	if *increment {
+		cfg.Processing.Incremental = true
+	}
+	if *fullRun {
+		cfg.Processing.Incremental = false
+	}
+
 	// Initialize logger
 	log, err := logger.NewLogger(&cfg.Logging, cfg.Paths.LogFolder)
 	if err != nil {
@@ -62,6 +87,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Run subcommand and exit if one was given
+	if flag.NArg() > 0 {
+		if err := runCommand(cfg, log, flag.Args(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Command failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	log.Info("=== Mark Master Sheet Consolidator Started ===")
 	log.WithField("version", appVersion).Info("Application version")
 	log.WithField("config_path", *configPath).Info("Configuration loaded")
@@ -72,6 +106,8 @@ func main() {
 
 	// Create processor
 	proc := processor.NewProcessor(cfg, log)
+	proc.SetEventHandler(newProgressHandler())
+	proc.SetResume(*resume)
 
 	// Show statistics and exit if requested
 	if *showStats {
@@ -120,6 +156,16 @@ func main() {
 	startTime := time.Now()
 
 	summary, err := proc.ProcessFiles(ctx, *dryRun)
+	if err != nil && summary != nil && summary.Cancelled &&
+		(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		// Report what was done before the interrupt or timeout
+		printSummary(summary, *dryRun)
+		if _, statErr := os.Stat(checkpoint.Path(cfg.Paths.OutputFolder)); statErr == nil && !*dryRun {
+			fmt.Println("Run again with -resume to continue without re-reading the files already read")
+		}
+		log.WithError(err).WithField("on_cancel", cfg.Processing.CancelPolicy()).Error("Processing cancelled")
+		os.Exit(1)
+	}
 	if err != nil {
 		log.WithError(err).Fatal("Processing failed")
 	}
@@ -132,6 +178,10 @@ func main() {
 	printSummary(summary, *dryRun)
 
 	// Exit with appropriate code
+	if summary.VerificationFailures > 0 {
+		log.WithField("mismatches", summary.VerificationFailures).Error("Saved master sheet does not match the written marks")
+		os.Exit(1)
+	}
 	if summary.FailedFiles > 0 {
 		log.Warn("Processing completed with errors")
 		os.Exit(1)
@@ -140,6 +190,39 @@ func main() {
 	log.Info("=== Mark Master Sheet Consolidator Completed Successfully ===")
 }
 
+// newProgressHandler returns an event handler drawing a console progress bar for a run
+func newProgressHandler() processor.EventHandler {
+	var bar *progressbar.ProgressBar
+	return func(event processor.Event) {
+		switch event.Type {
+		case processor.EventStarted:
+			if event.Total > 0 {
+				bar = progressbar.NewOptions(event.Total,
+					progressbar.OptionSetDescription("Processing files..."),
+					progressbar.OptionShowCount(),
+					progressbar.OptionShowIts(),
+					progressbar.OptionSetPredictTime(true),
+				)
+			}
+		case processor.EventFileDone:
+			if bar != nil {
+				bar.Set(event.Done)
+			}
+		case processor.EventWriting, processor.EventCompleted:
+			if bar == nil {
+				return
+			}
+			// Leave the bar where it stopped when files were cancelled
+			if event.Done < event.Total {
+				bar.Exit()
+			} else {
+				bar.Finish()
+			}
+			bar = nil
+		}
+	}
+}
+
 // printSummary prints a formatted summary to the console
 func printSummary(summary interface{}, dryRun bool) {
 	fmt.Println("\n=== Processing Summary ===")
@@ -152,17 +235,55 @@ func printSummary(summary interface{}, dryRun bool) {
 
 	// Type assertion to access summary fields
 	if s, ok := summary.(*models.ProcessingSummary); ok {
+		if s.RunID != "" {
+			fmt.Printf("Run ID: %s\n", s.RunID)
+		}
 		fmt.Printf("Total Files: %d\n", s.TotalFiles)
 		fmt.Printf("Successful: %d\n", s.SuccessfulFiles)
 		fmt.Printf("Failed: %d\n", s.FailedFiles)
 		fmt.Printf("Skipped: %d\n", s.SkippedFiles)
+		if s.UnchangedFiles > 0 {
+			fmt.Printf("Unchanged Since Last Run: %d\n", s.UnchangedFiles)
+		}
+		if s.QuarantinedFiles > 0 {
+			fmt.Printf("Quarantined: %d\n", s.QuarantinedFiles)
+		}
+		if s.ResumedFiles > 0 {
+			fmt.Printf("Resumed From Checkpoint: %d\n", s.ResumedFiles)
+		}
+		if s.Cancelled {
+			fmt.Printf("Cancelled: %d files not processed\n", s.CancelledFiles)
+		}
+		if len(s.SkippedPaths) > 0 {
+			fmt.Printf("Skipped Paths: %d\n", len(s.SkippedPaths))
+			for i, skipped := range s.SkippedPaths {
+				if i < 5 { // Show only first 5 skipped paths
+					fmt.Printf("  - %s (%s)\n", skipped.Path, skipped.Reason)
+				} else {
+					fmt.Printf("  ... and %d more skipped paths\n", len(s.SkippedPaths)-5)
+					break
+				}
+			}
+		}
 
 		if !dryRun {
 			fmt.Printf("Students Updated: %d\n", s.StudentsUpdated)
 			fmt.Printf("Students Not Found: %d\n", s.StudentsNotFound)
+			if s.StudentsAdded > 0 {
+				fmt.Printf("Students Added (review needed): %d\n", s.StudentsAdded)
+			}
+			if s.VerificationFailures > 0 {
+				fmt.Printf("Verification Mismatches: %d\n", s.VerificationFailures)
+			}
+			if s.FormulasRecalculated > 0 {
+				fmt.Printf("Formulas Recalculated: %d\n", s.FormulasRecalculated)
+			}
 		}
 
 		fmt.Printf("Duration: %v\n", s.TotalDuration)
+		if s.JournalPath != "" {
+			fmt.Printf("Journal: %s (revert with: undo %s)\n", s.JournalPath, s.RunID)
+		}
 
 		if len(s.Errors) > 0 {
 			fmt.Printf("\nErrors (%d):\n", len(s.Errors))
@@ -187,6 +308,16 @@ func printSummary(summary interface{}, dryRun bool) {
 				}
 			}
 		}
+
+		if len(s.Duplicates) > 0 {
+			fmt.Printf("\nPossible Duplicate Submissions (%d, check before releasing marks):\n", len(s.Duplicates))
+			for _, group := range s.Duplicates {
+				fmt.Printf("  - %s\n", group)
+				for _, file := range group.Files {
+					fmt.Printf("      %s\n", file)
+				}
+			}
+		}
 	}
 
 	fmt.Println("========================")
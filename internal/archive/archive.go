@@ -0,0 +1,156 @@
+// Package archive reads student submissions from ZIP archives, including nested archives,
+// without unpacking them to disk. Entries are addressed by archive-qualified paths such
+// as bulk.zip!/alice/grading.xlsx or bulk.zip!/alice.zip!/grading.xlsx.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Separator divides an archive path from the path of an entry inside it
+const Separator = "!/"
+
+// MaxEntrySize limits how much of a single entry is read into memory
+const MaxEntrySize int64 = 100 << 20
+
+// MaxNesting limits how many archives may be nested inside each other
+const MaxNesting = 8
+
+// Entry is a file inside an archive
+type Entry struct {
+	Path string // Archive-qualified path
+	Name string // Slash-separated path inside the archive
+	Size int64
+}
+
+// IsArchive reports whether a file name has a ZIP extension
+func IsArchive(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// IsArchivePath reports whether a path refers to an entry inside an archive
+func IsArchivePath(p string) bool {
+	return strings.Contains(p, Separator)
+}
+
+// Join appends an entry name to an archive path
+func Join(archivePath, name string) string {
+	return archivePath + Separator + name
+}
+
+// Split separates an archive-qualified path into the file system path of the
+// outermost archive and the entry names of each nesting level
+func Split(p string) (string, []string) {
+	parts := strings.Split(p, Separator)
+	return parts[0], parts[1:]
+}
+
+// Entries lists the files inside an archive, which may itself be an archive entry.
+// Folders are implied by the entry names and not listed.
+func Entries(archivePath string) ([]Entry, error) {
+	reader, closeFn, err := openZip(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	var entries []Entry
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(f.Name)), "/")
+		entries = append(entries, Entry{
+			Path: Join(archivePath, name),
+			Name: name,
+			Size: int64(f.UncompressedSize64),
+		})
+	}
+	return entries, nil
+}
+
+// ReadFile returns the contents of an archive-qualified entry
+func ReadFile(p string) ([]byte, error) {
+	outer, names := Split(p)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s is not an archive entry", p)
+	}
+
+	archivePath := Join(outer, strings.Join(names[:len(names)-1], Separator))
+	if len(names) == 1 {
+		archivePath = outer
+	}
+
+	reader, closeFn, err := openZip(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	return readEntry(reader, names[len(names)-1], p)
+}
+
+// openZip opens an archive from disk or, when nested, from the contents of its parent archive
+func openZip(archivePath string) (*zip.Reader, func(), error) {
+	outer, names := Split(archivePath)
+	if len(names) > MaxNesting {
+		return nil, nil, fmt.Errorf("archive %s is nested more than %d levels deep", archivePath, MaxNesting)
+	}
+
+	readCloser, err := zip.OpenReader(outer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open archive %s: %w", outer, err)
+	}
+	reader := &readCloser.Reader
+	current := outer
+
+	for _, name := range names {
+		current = Join(current, name)
+		data, err := readEntry(reader, name, current)
+		if err != nil {
+			readCloser.Close()
+			return nil, nil, err
+		}
+		if reader, err = zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+			readCloser.Close()
+			return nil, nil, fmt.Errorf("failed to open nested archive %s: %w", current, err)
+		}
+	}
+
+	return reader, func() { readCloser.Close() }, nil
+}
+
+// readEntry reads a named entry of an archive into memory, bounded by MaxEntrySize
+func readEntry(reader *zip.Reader, name, qualified string) ([]byte, error) {
+	for _, f := range reader.File {
+		if strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(f.Name)), "/") != name {
+			continue
+		}
+		if f.UncompressedSize64 > uint64(MaxEntrySize) {
+			return nil, fmt.Errorf("archive entry %s is larger than %d bytes", qualified, MaxEntrySize)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive entry %s: %w", qualified, err)
+		}
+		defer rc.Close()
+
+		// The declared size may be forged, so the read itself is bounded too
+		data, err := io.ReadAll(io.LimitReader(rc, MaxEntrySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive entry %s: %w", qualified, err)
+		}
+		if int64(len(data)) > MaxEntrySize {
+			return nil, fmt.Errorf("archive entry %s is larger than %d bytes", qualified, MaxEntrySize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("archive entry %s not found", qualified)
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildZip returns a ZIP archive holding the given files
+func buildZip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to finish archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestEntriesAndReadFile tests listing and reading entries of nested archives
+func TestEntriesAndReadFile(t *testing.T) {
+	inner := buildZip(t, map[string][]byte{"grading.xlsx": []byte("inner")})
+	outer := buildZip(t, map[string][]byte{
+		"alice/grading.xlsx": []byte("alice"),
+		"bob.zip":            inner,
+		"folder/":            nil,
+	})
+	bulk := filepath.Join(t.TempDir(), "bulk.zip")
+	if err := os.WriteFile(bulk, outer, 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	entries, err := Entries(bulk)
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	names := make(map[string]string)
+	for _, e := range entries {
+		names[e.Name] = e.Path
+	}
+	if len(entries) != 2 || names["alice/grading.xlsx"] != bulk+"!/alice/grading.xlsx" {
+		t.Errorf("Entries() = %+v", entries)
+	}
+
+	nested, err := Entries(names["bob.zip"])
+	if err != nil || len(nested) != 1 || nested[0].Path != bulk+"!/bob.zip!/grading.xlsx" {
+		t.Fatalf("Entries(nested) = %+v, %v", nested, err)
+	}
+
+	tests := map[string]string{
+		bulk + "!/alice/grading.xlsx":    "alice",
+		bulk + "!/bob.zip!/grading.xlsx": "inner",
+	}
+	for p, want := range tests {
+		data, err := ReadFile(p)
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(%s) = %q, %v, want %q", p, data, err, want)
+		}
+	}
+
+	if _, err := ReadFile(bulk + "!/missing.xlsx"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("ReadFile(missing) error = %v", err)
+	}
+}
+
+// TestSplit tests separating archive-qualified paths
+func TestSplit(t *testing.T) {
+	outer, names := Split("in/bulk.zip!/alice.zip!/grading.xlsx")
+	if outer != "in/bulk.zip" || len(names) != 2 || names[0] != "alice.zip" || names[1] != "grading.xlsx" {
+		t.Errorf("Split() = %q, %q", outer, names)
+	}
+	if !IsArchivePath("bulk.zip!/a.xlsx") || IsArchivePath("folder/a.xlsx") {
+		t.Error("IsArchivePath() misclassified a path")
+	}
+}
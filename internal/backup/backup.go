@@ -0,0 +1,361 @@
+// Package backup manages timestamped backups of the master sheet for the Mark Master Sheet Consolidator.
+// It handles backup creation, listing, retention pruning and restoring a backup over the master.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/fileutil"
+)
+
+// timestampLayout is the timestamp format embedded in backup file names
+const timestampLayout = "20060102_150405"
+
+// RestoreRunID is the run ID recorded for safety backups taken before a restore
+const RestoreRunID = "restore"
+
+// copyFile copies a file; tests replace it to damage the copy a restore makes
+var copyFile = fileutil.CopyFile
+
+// Info describes a single backup file
+type Info struct {
+	Path      string    `json:"path"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PrunedBackup describes a backup removed by a retention rule
+type PrunedBackup struct {
+	Info   Info   `json:"info"`
+	Reason string `json:"reason"`
+}
+
+// Create creates a timestamped backup of the given file in backupDir.
+// The copy is synced to disk, verified against the source checksum and
+// recorded in the backup manifest under the given run ID. A corrupt manifest
+// is set aside and a new one started. Manifest problems do not undo the backup:
+// its path is returned with an error for which ManifestOnly reports true.
+func Create(sourcePath, backupDir, runID string) (string, error) {
+	// Ensure backup directory exists
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	now := time.Now()
+	backupPath := uniqueBackupPath(sourcePath, backupDir, now)
+	sourceSum, size, err := fileutil.CopyFile(sourcePath, backupPath)
+	if err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to copy master sheet to backup: %w", err)
+	}
+
+	// Verify the written copy against the data read from the source
+	backupSum, backupSize, err := fileutil.HashFile(backupPath)
+	if err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to verify backup: %w", err)
+	}
+	if backupSize != size || backupSum != sourceSum {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("backup verification failed: checksum of %s does not match source", backupPath)
+	}
+
+	// The backup is safe on disk, so a manifest problem no longer stops the run
+	manifest, err := LoadManifest(backupDir)
+	var setAside error
+	if errors.Is(err, ErrManifestCorrupt) {
+		corruptPath, renameErr := setAsideManifest(backupDir, now)
+		if renameErr != nil {
+			return backupPath, fmt.Errorf("%w: %v; %v", ErrManifestNotUpdated, err, renameErr)
+		}
+		manifest = &Manifest{}
+		setAside = fmt.Errorf("%w; set aside as %s and started a new manifest", err, filepath.Base(corruptPath))
+	} else if err != nil {
+		return backupPath, fmt.Errorf("%w: %v", ErrManifestNotUpdated, err)
+	}
+
+	manifest.Entries = append(manifest.Entries, ManifestEntry{
+		RunID:        runID,
+		SourcePath:   sourcePath,
+		BackupFile:   filepath.Base(backupPath),
+		Size:         size,
+		SourceSHA256: sourceSum,
+		BackupSHA256: backupSum,
+		CreatedAt:    time.Now(),
+	})
+	if err := manifest.Save(backupDir); err != nil {
+		return backupPath, fmt.Errorf("%w: %v", ErrManifestNotUpdated, err)
+	}
+
+	return backupPath, setAside
+}
+
+// ManifestOnly reports whether an error from Create, Prune or Restore concerns only the
+// manifest, the backup, pruning or restore itself having succeeded
+func ManifestOnly(err error) bool {
+	return errors.Is(err, ErrManifestCorrupt) || errors.Is(err, ErrManifestUnreadable) || errors.Is(err, ErrManifestNotUpdated)
+}
+
+// List returns the backups of the given master sheet, newest first
+func List(backupDir, masterSheetPath string) ([]Info, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	prefix, ext := backupNameParts(masterSheetPath)
+
+	var backups []Info
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		createdAt, ok := parseBackupName(name, prefix, ext)
+		if !ok {
+			continue
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			continue // File removed while listing
+		}
+
+		backups = append(backups, Info{
+			Path:      filepath.Join(backupDir, name),
+			Name:      name,
+			Size:      fileInfo.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].Name > backups[j].Name
+		}
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// Find resolves a backup by file name or path within the backups of the master sheet
+func Find(backupDir, masterSheetPath, nameOrPath string) (Info, error) {
+	backups, err := List(backupDir, masterSheetPath)
+	if err != nil {
+		return Info{}, err
+	}
+
+	name := filepath.Base(nameOrPath)
+	for _, b := range backups {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+
+	return Info{}, fmt.Errorf("backup %s not found in %s", nameOrPath, backupDir)
+}
+
+// Prune removes backups of the master sheet that violate the retention rules.
+// The most recent backup is always kept. A manifest that cannot be read does not
+// stop pruning; it is left as it is and reported with an error for which
+// ManifestOnly reports true.
+func Prune(backupDir, masterSheetPath string, retention config.BackupConfig) ([]PrunedBackup, error) {
+	if !retention.HasRetention() {
+		return nil, nil
+	}
+
+	backups, err := List(backupDir, masterSheetPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, manifestErr := LoadManifest(backupDir)
+
+	var pruned []PrunedBackup
+	removed := make(map[string]bool)
+	var totalSize int64
+	maxTotalSize := int64(retention.MaxTotalSizeMB) * 1024 * 1024
+	cutoff := time.Now().AddDate(0, 0, -retention.MaxAgeDays)
+
+	for i, b := range backups {
+		totalSize += b.Size
+		if i == 0 {
+			continue // Never remove the newest backup
+		}
+
+		var reason string
+		switch {
+		case retention.KeepLast > 0 && i >= retention.KeepLast:
+			reason = fmt.Sprintf("exceeds keep_last (%d)", retention.KeepLast)
+		case retention.MaxAgeDays > 0 && b.CreatedAt.Before(cutoff):
+			reason = fmt.Sprintf("older than max_age_days (%d)", retention.MaxAgeDays)
+		case maxTotalSize > 0 && totalSize > maxTotalSize:
+			reason = fmt.Sprintf("exceeds max_total_size_mb (%d)", retention.MaxTotalSizeMB)
+		default:
+			continue
+		}
+
+		if err := os.Remove(b.Path); err != nil {
+			err = fmt.Errorf("failed to remove backup %s: %w", b.Name, err)
+			return pruned, saveAfterPrune(manifest, manifestErr, backupDir, removed, err)
+		}
+		totalSize -= b.Size
+		removed[b.Name] = true
+		pruned = append(pruned, PrunedBackup{Info: b, Reason: reason})
+	}
+
+	return pruned, saveAfterPrune(manifest, manifestErr, backupDir, removed, nil)
+}
+
+// saveAfterPrune drops pruned backups from the manifest and saves it, keeping the first error.
+// A manifest that could not be loaded is left untouched and its error returned.
+func saveAfterPrune(manifest *Manifest, manifestErr error, backupDir string, removed map[string]bool, pruneErr error) error {
+	if pruneErr == nil && manifestErr != nil {
+		pruneErr = fmt.Errorf("%w; pruned backups not removed from the manifest", manifestErr)
+	}
+	if len(removed) == 0 || manifestErr != nil {
+		return pruneErr
+	}
+
+	manifest.remove(removed)
+	if err := manifest.Save(backupDir); err != nil && pruneErr == nil {
+		return err
+	}
+	return pruneErr
+}
+
+// Restore copies a backup over the master sheet. Backups recorded in the manifest
+// are verified before use, and the backup is copied next to the master sheet and
+// checked again before it replaces the master, so a failed copy leaves it intact. The current master sheet is backed up first and the
+// path of that safety backup is returned. A manifest that cannot be read leaves the
+// backup unverified, and a safety backup that could not be recorded in the manifest
+// is kept; both are reported, after the restore, with an error for which
+// ManifestOnly reports true.
+func Restore(backupPath, masterSheetPath, backupDir string) (string, error) {
+	if _, err := os.Stat(backupPath); err != nil {
+		return "", fmt.Errorf("backup file not accessible: %w", err)
+	}
+
+	var warnings []error
+	manifest, err := LoadManifest(filepath.Dir(backupPath))
+	if err != nil {
+		warnings = append(warnings, fmt.Errorf("%w; %s restored without verification", err, filepath.Base(backupPath)))
+		manifest = &Manifest{}
+	}
+	entry, verified := manifest.Lookup(filepath.Base(backupPath))
+	if verified {
+		if result := verifyEntry(filepath.Dir(backupPath), entry); !result.OK {
+			return "", fmt.Errorf("backup %s failed verification: %s", entry.BackupFile, result.Problem)
+		}
+	}
+
+	var safetyBackup string
+	if _, err := os.Stat(masterSheetPath); err == nil {
+		safetyBackup, err = Create(masterSheetPath, backupDir, RestoreRunID)
+		if err != nil && !ManifestOnly(err) {
+			return "", fmt.Errorf("failed to back up current master sheet: %w", err)
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("safety backup %s: %w", filepath.Base(safetyBackup), err))
+		}
+	}
+
+	tempPath := masterSheetPath + ".restore.tmp"
+	if err := restoreCopy(backupPath, tempPath, entry, verified); err != nil {
+		os.Remove(tempPath)
+		return safetyBackup, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	if err := os.Rename(tempPath, masterSheetPath); err != nil {
+		os.Remove(tempPath)
+		return safetyBackup, fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	return safetyBackup, errors.Join(warnings...)
+}
+
+// restoreCopy copies a backup to tempPath next to the master sheet and, when the backup
+// is in the manifest, checks the copy against its recorded checksum
+func restoreCopy(backupPath, tempPath string, entry ManifestEntry, verified bool) error {
+	if _, _, err := copyFile(backupPath, tempPath); err != nil {
+		return err
+	}
+	if !verified {
+		return nil
+	}
+	sum, _, err := fileutil.HashFile(tempPath)
+	if err != nil {
+		return err
+	}
+	if sum != entry.BackupSHA256 {
+		return fmt.Errorf("copy of %s does not match the manifest checksum", entry.BackupFile)
+	}
+	return nil
+}
+
+// FormatSize formats a byte count in a human readable form
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// backupNameParts returns the file name prefix and extension used for backups of a file
+func backupNameParts(sourcePath string) (string, string) {
+	originalName := filepath.Base(sourcePath)
+	ext := filepath.Ext(originalName)
+	nameWithoutExt := originalName[:len(originalName)-len(ext)]
+	return nameWithoutExt + "_backup_", ext
+}
+
+// uniqueBackupPath generates a backup path that does not collide with an existing file
+func uniqueBackupPath(sourcePath, backupDir string, now time.Time) string {
+	prefix, ext := backupNameParts(sourcePath)
+	timestamp := now.Format(timestampLayout)
+
+	backupPath := filepath.Join(backupDir, prefix+timestamp+ext)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+			return backupPath
+		}
+		backupPath = filepath.Join(backupDir, fmt.Sprintf("%s%s_%d%s", prefix, timestamp, n, ext))
+	}
+}
+
+// parseBackupName extracts the creation time from a backup file name
+func parseBackupName(name, prefix, ext string) (time.Time, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	if len(stamp) < len(timestampLayout) {
+		return time.Time{}, false
+	}
+
+	createdAt, err := time.ParseInLocation(timestampLayout, stamp[:len(timestampLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return createdAt, true
+}
@@ -0,0 +1,212 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mark-master-sheet/internal/config"
+)
+
+// TestCreateAndList tests backup creation and listing
+func TestCreateAndList(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "original")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	first, err := Create(masterPath, backupDir, "test-run")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	second, err := Create(masterPath, backupDir, "test-run")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if first == second {
+		t.Error("Create() should not overwrite a backup created in the same second")
+	}
+
+	// Unrelated files must be ignored
+	os.WriteFile(filepath.Join(backupDir, "other_backup_20240101_120000.xlsx"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(backupDir, "notes.txt"), []byte("x"), 0644)
+
+	backups, err := List(backupDir, masterPath)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("List() returned %d backups, want 2", len(backups))
+	}
+	if backups[0].Path != second {
+		t.Errorf("List() newest = %s, want %s", backups[0].Path, second)
+	}
+	if backups[0].Size != int64(len("original")) {
+		t.Errorf("List() size = %d, want %d", backups[0].Size, len("original"))
+	}
+}
+
+// TestListMissingDirectory tests listing when no backup directory exists
+func TestListMissingDirectory(t *testing.T) {
+	backups, err := List(filepath.Join(t.TempDir(), "missing"), "master.xlsx")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("List() returned %d backups, want 0", len(backups))
+	}
+}
+
+// TestPrune tests the retention rules
+func TestPrune(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		retention  config.BackupConfig
+		sizes      []int
+		ages       []time.Duration
+		wantRemain int
+	}{
+		{
+			name:       "no retention keeps everything",
+			retention:  config.BackupConfig{},
+			sizes:      []int{10, 10, 10},
+			ages:       []time.Duration{0, time.Hour, 2 * time.Hour},
+			wantRemain: 3,
+		},
+		{
+			name:       "keep last",
+			retention:  config.BackupConfig{KeepLast: 2},
+			sizes:      []int{10, 10, 10, 10},
+			ages:       []time.Duration{0, time.Hour, 2 * time.Hour, 3 * time.Hour},
+			wantRemain: 2,
+		},
+		{
+			name:       "max age",
+			retention:  config.BackupConfig{MaxAgeDays: 7},
+			sizes:      []int{10, 10, 10},
+			ages:       []time.Duration{0, 24 * time.Hour, 10 * 24 * time.Hour},
+			wantRemain: 2,
+		},
+		{
+			name:       "max age keeps newest backup",
+			retention:  config.BackupConfig{MaxAgeDays: 1},
+			sizes:      []int{10, 10},
+			ages:       []time.Duration{5 * 24 * time.Hour, 6 * 24 * time.Hour},
+			wantRemain: 1,
+		},
+		{
+			name:       "max total size",
+			retention:  config.BackupConfig{MaxTotalSizeMB: 1},
+			sizes:      []int{600 * 1024, 600 * 1024, 600 * 1024},
+			ages:       []time.Duration{0, time.Hour, 2 * time.Hour},
+			wantRemain: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			masterPath := filepath.Join(tempDir, "master.xlsx")
+			backupDir := filepath.Join(tempDir, "backups")
+			os.MkdirAll(backupDir, 0755)
+
+			for i, age := range tt.ages {
+				name := "master_backup_" + now.Add(-age).Format(timestampLayout) + ".xlsx"
+				data := make([]byte, tt.sizes[i])
+				if err := os.WriteFile(filepath.Join(backupDir, name), data, 0644); err != nil {
+					t.Fatalf("Failed to create backup: %v", err)
+				}
+			}
+
+			pruned, err := Prune(backupDir, masterPath, tt.retention)
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+
+			remaining, _ := List(backupDir, masterPath)
+			if len(remaining) != tt.wantRemain {
+				t.Errorf("Prune() left %d backups, want %d", len(remaining), tt.wantRemain)
+			}
+			if len(pruned) != len(tt.ages)-tt.wantRemain {
+				t.Errorf("Prune() reported %d removals, want %d", len(pruned), len(tt.ages)-tt.wantRemain)
+			}
+			for _, p := range pruned {
+				if p.Reason == "" {
+					t.Errorf("Prune() removal of %s has no reason", p.Info.Name)
+				}
+			}
+		})
+	}
+}
+
+// TestRestore tests restoring a backup over the master sheet
+func TestRestore(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "version 1")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	backupPath, err := Create(masterPath, backupDir, "test-run")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := os.WriteFile(masterPath, []byte("version 2"), 0644); err != nil {
+		t.Fatalf("Failed to modify master: %v", err)
+	}
+
+	safetyBackup, err := Restore(backupPath, masterPath, backupDir)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	restored, _ := os.ReadFile(masterPath)
+	if string(restored) != "version 1" {
+		t.Errorf("Restore() master content = %q, want %q", restored, "version 1")
+	}
+
+	saved, err := os.ReadFile(safetyBackup)
+	if err != nil {
+		t.Fatalf("Restore() safety backup not readable: %v", err)
+	}
+	if string(saved) != "version 2" {
+		t.Errorf("Restore() safety backup content = %q, want %q", saved, "version 2")
+	}
+
+	if _, err := Restore(filepath.Join(backupDir, "missing.xlsx"), masterPath, backupDir); err == nil {
+		t.Error("Restore() expected error for missing backup")
+	}
+}
+
+// TestFind tests resolving a backup by name
+func TestFind(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "data")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	backupPath, err := Create(masterPath, backupDir, "test-run")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info, err := Find(backupDir, masterPath, filepath.Base(backupPath))
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if info.Path != backupPath {
+		t.Errorf("Find() path = %s, want %s", info.Path, backupPath)
+	}
+
+	if _, err := Find(backupDir, masterPath, "missing.xlsx"); err == nil {
+		t.Error("Find() expected error for unknown backup")
+	}
+}
+
+func createTestMaster(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "master.xlsx")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create master file: %v", err)
+	}
+	return path
+}
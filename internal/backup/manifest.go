@@ -0,0 +1,153 @@
+// Package backup manages timestamped backups of the master sheet for the Mark Master Sheet Consolidator.
+// This file contains the backup manifest and integrity verification.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mark-master-sheet/internal/fileutil"
+)
+
+// ManifestFileName is the name of the manifest file kept in the backup directory
+const ManifestFileName = "manifest.json"
+
+// ErrManifestCorrupt is returned when the manifest file cannot be parsed
+var ErrManifestCorrupt = errors.New("backup manifest is corrupt")
+
+// ErrManifestUnreadable is returned when the manifest file exists but cannot be read
+var ErrManifestUnreadable = errors.New("failed to read backup manifest")
+
+// ErrManifestNotUpdated is returned by Create when the backup was made but not recorded in the manifest
+var ErrManifestNotUpdated = errors.New("backup manifest not updated")
+
+// ManifestEntry records the provenance and checksum of a single backup
+type ManifestEntry struct {
+	RunID        string    `json:"run_id"`
+	SourcePath   string    `json:"source_path"`
+	BackupFile   string    `json:"backup_file"`
+	Size         int64     `json:"size"`
+	SourceSHA256 string    `json:"source_sha256"`
+	BackupSHA256 string    `json:"backup_sha256"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Manifest lists the backups created in a backup directory
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// VerifyResult is the outcome of re-checking a single manifest entry
+type VerifyResult struct {
+	Entry   ManifestEntry `json:"entry"`
+	OK      bool          `json:"ok"`
+	Problem string        `json:"problem,omitempty"`
+}
+
+// LoadManifest reads the manifest from the backup directory.
+// A missing manifest is returned as an empty manifest.
+func LoadManifest(backupDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(backupDir, ManifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Manifest{}, nil
+		}
+		return nil, fmt.Errorf("%w: %w", ErrManifestUnreadable, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestCorrupt, err)
+	}
+
+	return &manifest, nil
+}
+
+// setAsideManifest renames a corrupt manifest next to itself, so that a new one can be started
+// without losing what it recorded, and returns the new path
+func setAsideManifest(backupDir string, now time.Time) (string, error) {
+	manifestPath := filepath.Join(backupDir, ManifestFileName)
+	corruptPath := fmt.Sprintf("%s.corrupt_%s", manifestPath, now.Format(timestampLayout))
+	if err := os.Rename(manifestPath, corruptPath); err != nil {
+		return "", fmt.Errorf("failed to set aside corrupt backup manifest: %w", err)
+	}
+	return corruptPath, nil
+}
+
+// Save writes the manifest to the backup directory, replacing it atomically
+func (m *Manifest) Save(backupDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+
+	manifestPath := filepath.Join(backupDir, ManifestFileName)
+	tempPath := manifestPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := os.Rename(tempPath, manifestPath); err != nil {
+		return fmt.Errorf("failed to replace backup manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup returns the manifest entry for the given backup file name
+func (m *Manifest) Lookup(backupFile string) (ManifestEntry, bool) {
+	for _, entry := range m.Entries {
+		if entry.BackupFile == backupFile {
+			return entry, true
+		}
+	}
+	return ManifestEntry{}, false
+}
+
+// remove drops the entries for the given backup file names
+func (m *Manifest) remove(backupFiles map[string]bool) {
+	kept := m.Entries[:0]
+	for _, entry := range m.Entries {
+		if !backupFiles[entry.BackupFile] {
+			kept = append(kept, entry)
+		}
+	}
+	m.Entries = kept
+}
+
+// Verify re-checks every backup listed in the manifest against its recorded size and checksum
+func Verify(backupDir string) ([]VerifyResult, error) {
+	manifest, err := LoadManifest(backupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]VerifyResult, 0, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		results = append(results, verifyEntry(backupDir, entry))
+	}
+
+	return results, nil
+}
+
+// verifyEntry checks a single backup file against its manifest entry
+func verifyEntry(backupDir string, entry ManifestEntry) VerifyResult {
+	result := VerifyResult{Entry: entry}
+
+	sum, size, err := fileutil.HashFile(filepath.Join(backupDir, entry.BackupFile))
+	switch {
+	case err != nil:
+		result.Problem = fmt.Sprintf("backup file unreadable: %v", err)
+	case size != entry.Size:
+		result.Problem = fmt.Sprintf("size mismatch: expected %d bytes, found %d", entry.Size, size)
+	case sum != entry.BackupSHA256:
+		result.Problem = "checksum mismatch"
+	default:
+		result.OK = true
+	}
+
+	return result
+}
@@ -0,0 +1,224 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/fileutil"
+)
+
+// TestCreateRecordsManifest tests that backups are recorded with checksums
+func TestCreateRecordsManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "abc")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	backupPath, err := Create(masterPath, backupDir, "run-1")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	manifest, err := LoadManifest(backupDir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	entry, ok := manifest.Lookup(filepath.Base(backupPath))
+	if !ok {
+		t.Fatal("Create() did not record the backup in the manifest")
+	}
+
+	wantSum := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if entry.RunID != "run-1" {
+		t.Errorf("manifest run ID = %s, want run-1", entry.RunID)
+	}
+	if entry.SourcePath != masterPath {
+		t.Errorf("manifest source path = %s, want %s", entry.SourcePath, masterPath)
+	}
+	if entry.Size != 3 {
+		t.Errorf("manifest size = %d, want 3", entry.Size)
+	}
+	if entry.SourceSHA256 != wantSum || entry.BackupSHA256 != wantSum {
+		t.Errorf("manifest checksums = %s/%s, want %s", entry.SourceSHA256, entry.BackupSHA256, wantSum)
+	}
+}
+
+// TestCreateCorruptManifest tests that a corrupt manifest is set aside instead of blocking the backup
+func TestCreateCorruptManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "abc")
+	backupDir := filepath.Join(tempDir, "backups")
+	os.MkdirAll(backupDir, 0755)
+	os.WriteFile(filepath.Join(backupDir, ManifestFileName), []byte("{not json"), 0644)
+
+	backupPath, err := Create(masterPath, backupDir, "run-1")
+	if !errors.Is(err, ErrManifestCorrupt) || !ManifestOnly(err) {
+		t.Errorf("Create() error = %v, want %v", err, ErrManifestCorrupt)
+	}
+	if data, readErr := os.ReadFile(backupPath); readErr != nil || string(data) != "abc" {
+		t.Fatalf("backup = %q, %v, want a copy of the master sheet", data, readErr)
+	}
+
+	manifest, err := LoadManifest(backupDir)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if _, ok := manifest.Lookup(filepath.Base(backupPath)); !ok || len(manifest.Entries) != 1 {
+		t.Errorf("new manifest = %+v, want only the new backup", manifest.Entries)
+	}
+	corrupt, _ := filepath.Glob(filepath.Join(backupDir, ManifestFileName+".corrupt_*"))
+	if len(corrupt) != 1 {
+		t.Fatalf("set aside manifests = %v, want 1", corrupt)
+	}
+	if data, _ := os.ReadFile(corrupt[0]); string(data) != "{not json" {
+		t.Errorf("set aside manifest = %q, want the corrupt content", data)
+	}
+}
+
+// TestVerify tests re-checking backups against the manifest
+func TestVerify(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "original")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	intact, _ := Create(masterPath, backupDir, "run-1")
+	corrupted, _ := Create(masterPath, backupDir, "run-2")
+	missing, _ := Create(masterPath, backupDir, "run-3")
+
+	os.WriteFile(corrupted, []byte("tampered"), 0644)
+	os.Remove(missing)
+
+	results, err := Verify(backupDir)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Verify() returned %d results, want 3", len(results))
+	}
+
+	status := make(map[string]bool)
+	for _, result := range results {
+		status[result.Entry.BackupFile] = result.OK
+		if !result.OK && result.Problem == "" {
+			t.Errorf("Verify() failure for %s has no problem description", result.Entry.BackupFile)
+		}
+	}
+
+	if !status[filepath.Base(intact)] {
+		t.Error("Verify() should pass an intact backup")
+	}
+	if status[filepath.Base(corrupted)] {
+		t.Error("Verify() should fail a modified backup")
+	}
+	if status[filepath.Base(missing)] {
+		t.Error("Verify() should fail a missing backup")
+	}
+}
+
+// TestRestoreRefusesCorruptedBackup tests that restores verify the backup first
+func TestRestoreRefusesCorruptedBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "original")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	backupPath, _ := Create(masterPath, backupDir, "run-1")
+	os.WriteFile(backupPath, []byte("tampered"), 0644)
+	os.WriteFile(masterPath, []byte("current"), 0644)
+
+	if _, err := Restore(backupPath, masterPath, backupDir); err == nil {
+		t.Fatal("Restore() expected error for corrupted backup")
+	}
+
+	content, _ := os.ReadFile(masterPath)
+	if string(content) != "current" {
+		t.Errorf("Restore() changed master despite failed verification: %q", content)
+	}
+}
+
+// TestRestoreDamagedCopy tests that a copy failing the checksum does not replace the master
+func TestRestoreDamagedCopy(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "original")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	backupPath, _ := Create(masterPath, backupDir, "run-1")
+	os.WriteFile(masterPath, []byte("current"), 0644)
+
+	copyFile = func(src, dst string) (string, int64, error) {
+		return "", 0, os.WriteFile(dst, []byte("orig"), 0644)
+	}
+	defer func() { copyFile = fileutil.CopyFile }()
+
+	if _, err := Restore(backupPath, masterPath, backupDir); err == nil {
+		t.Fatal("Restore() expected error for a damaged copy")
+	}
+	if content, _ := os.ReadFile(masterPath); string(content) != "current" {
+		t.Errorf("Restore() replaced master with a damaged copy: %q", content)
+	}
+	if _, err := os.Stat(masterPath + ".restore.tmp"); !os.IsNotExist(err) {
+		t.Errorf("Restore() left its temporary copy behind: %v", err)
+	}
+}
+
+// TestPruneUpdatesManifest tests that pruned backups are dropped from the manifest
+func TestPruneUpdatesManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "original")
+	backupDir := filepath.Join(tempDir, "backups")
+
+	for i := 0; i < 3; i++ {
+		if _, err := Create(masterPath, backupDir, "run"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if _, err := Prune(backupDir, masterPath, config.BackupConfig{KeepLast: 1}); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	manifest, _ := LoadManifest(backupDir)
+	if len(manifest.Entries) != 1 {
+		t.Errorf("manifest has %d entries after prune, want 1", len(manifest.Entries))
+	}
+}
+
+// TestCorruptManifestRestoreAndPrune tests that a corrupt manifest neither blocks a restore nor pruning
+func TestCorruptManifestRestoreAndPrune(t *testing.T) {
+	tempDir := t.TempDir()
+	masterPath := createTestMaster(t, tempDir, "version 1")
+	backupDir := filepath.Join(tempDir, "backups")
+	manifestPath := filepath.Join(backupDir, ManifestFileName)
+
+	backupPath, err := Create(masterPath, backupDir, "run-1")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	os.WriteFile(masterPath, []byte("version 2"), 0644)
+	os.WriteFile(manifestPath, []byte("{not json"), 0644)
+
+	safetyBackup, err := Restore(backupPath, masterPath, backupDir)
+	if !ManifestOnly(err) {
+		t.Fatalf("Restore() error = %v, want a manifest warning", err)
+	}
+	if content, _ := os.ReadFile(masterPath); string(content) != "version 1" {
+		t.Errorf("master = %q after Restore(), want the backup", content)
+	}
+	if content, _ := os.ReadFile(safetyBackup); string(content) != "version 2" {
+		t.Errorf("safety backup = %q, want the previous master", content)
+	}
+
+	os.WriteFile(manifestPath, []byte("{not json"), 0644)
+	pruned, err := Prune(backupDir, masterPath, config.BackupConfig{KeepLast: 1})
+	if !ManifestOnly(err) {
+		t.Errorf("Prune() error = %v, want a manifest warning", err)
+	}
+	if len(pruned) != 1 {
+		t.Errorf("Prune() removed %d backups, want 1", len(pruned))
+	}
+	if content, _ := os.ReadFile(manifestPath); string(content) != "{not json" {
+		t.Errorf("manifest = %q after Prune(), want it left untouched", content)
+	}
+}
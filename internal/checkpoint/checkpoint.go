@@ -0,0 +1,253 @@
+// Package checkpoint records the student data read by a run of the Mark Master Sheet
+// Consolidator as it goes, so that a run interrupted before writing the master sheet can
+// be resumed without reading the same files again.
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// FileName is the name of the checkpoint file inside the output folder
+const FileName = "checkpoint.jsonl"
+
+// Version is the format version of the checkpoint file
+const Version = 1
+
+// Errors returned by Resume
+var (
+	ErrNoCheckpoint  = errors.New("no interrupted run to resume")
+	ErrConfigChanged = errors.New("configuration changed since the interrupted run")
+	ErrMasterChanged = errors.New("master sheet changed since the interrupted run")
+)
+
+// Header is the first line of a checkpoint file and identifies the run it belongs to
+type Header struct {
+	Version         int       `json:"version"`
+	RunID           string    `json:"run_id"`
+	StartedAt       time.Time `json:"started_at"`
+	ConfigHash      string    `json:"config_hash"`
+	MasterSheetPath string    `json:"master_sheet_path"`
+	MasterHash      string    `json:"master_hash"`
+}
+
+// Checkpoint is an open checkpoint file. After the header, every line holds the
+// student data of one file, so records are appended without rewriting the file.
+type Checkpoint struct {
+	Header
+	path      string
+	file      *os.File
+	completed map[string]*models.StudentData
+}
+
+// Path returns the checkpoint file path for an output folder
+func Path(outputFolder string) string {
+	return filepath.Join(outputFolder, FileName)
+}
+
+// ConfigHash returns a hash of the complete configuration, including command-line overrides
+func ConfigHash(cfg *config.Config) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MasterHash returns the SHA-256 hash of the master sheet content
+func MasterHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open master sheet: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash master sheet: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Create starts a new checkpoint file, replacing the checkpoint of any earlier run
+func Create(path string, header Header) (*Checkpoint, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	header.Version = Version
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint header: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+
+	return &Checkpoint{
+		Header:    header,
+		path:      path,
+		file:      f,
+		completed: make(map[string]*models.StudentData),
+	}, nil
+}
+
+// Load reads a checkpoint file without opening it for recording. A missing file is
+// returned as a nil checkpoint. A last line cut short by an interruption is ignored.
+func Load(path string) (*Checkpoint, error) {
+	c, _, err := load(path)
+	return c, err
+}
+
+// Resume reopens the checkpoint of an interrupted run for recording. It refuses to resume
+// when the configuration or the master sheet content differ from those the run started with.
+func Resume(path, configHash, masterHash string) (*Checkpoint, error) {
+	c, valid, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNoCheckpoint
+	}
+	if c.ConfigHash != configHash {
+		return nil, fmt.Errorf("cannot resume run %s: %w", c.RunID, ErrConfigChanged)
+	}
+	if c.MasterHash != masterHash {
+		return nil, fmt.Errorf("cannot resume run %s: %w", c.RunID, ErrMasterChanged)
+	}
+
+	// Drop a partly written last line before appending to the file
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to repair checkpoint file: %w", err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	c.file = f
+	return c, nil
+}
+
+// load parses a checkpoint file, returning the length of its complete lines
+func load(path string) (*Checkpoint, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	c := &Checkpoint{path: path, completed: make(map[string]*models.StudentData)}
+	var valid int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 0; scanner.Scan(); line++ {
+		text := scanner.Bytes()
+		complete := valid+int64(len(text)) < int64(len(data))
+		if !complete {
+			break
+		}
+
+		if line == 0 {
+			if err := json.Unmarshal(text, &c.Header); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse checkpoint file %s: %w", path, err)
+			}
+			if c.Version != Version {
+				return nil, 0, fmt.Errorf("unsupported checkpoint version %d in %s", c.Version, path)
+			}
+		} else {
+			var student models.StudentData
+			if err := json.Unmarshal(text, &student); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse checkpoint file %s, line %d: %w", path, line+1, err)
+			}
+			c.completed[student.FilePath] = &student
+		}
+		valid += int64(len(text)) + 1
+	}
+	if valid == 0 {
+		return nil, 0, fmt.Errorf("checkpoint file %s has no header", path)
+	}
+
+	return c, valid, nil
+}
+
+// Completed returns the student data recorded for a file, or nil
+func (c *Checkpoint) Completed(path string) *models.StudentData {
+	if c == nil {
+		return nil
+	}
+	return c.completed[path]
+}
+
+// Len returns the number of files recorded before the checkpoint was loaded
+func (c *Checkpoint) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.completed)
+}
+
+// Recording reports whether the checkpoint is open for recording
+func (c *Checkpoint) Recording() bool {
+	return c != nil && c.file != nil
+}
+
+// Record appends the student data of a file read successfully. Files recorded are not
+// added to Completed, which callers may read concurrently.
+func (c *Checkpoint) Record(student *models.StudentData) error {
+	line, err := json.Marshal(student)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint record: %w", err)
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the checkpoint file, keeping it for a later resume
+func (c *Checkpoint) Close() error {
+	if c == nil || c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Remove closes and deletes the checkpoint file once its run has finished
+func (c *Checkpoint) Remove() error {
+	if c == nil {
+		return nil
+	}
+	c.Close()
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint file: %w", err)
+	}
+	return nil
+}
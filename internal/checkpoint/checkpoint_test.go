@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mark-master-sheet/pkg/models"
+)
+
+// TestResume tests reopening an interrupted checkpoint, including one cut off mid-line
+func TestResume(t *testing.T) {
+	path := Path(t.TempDir())
+	header := Header{RunID: "run-1", ConfigHash: "config", MasterHash: "master"}
+
+	c, err := Create(path, header)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, id := range []string{"STU001", "STU002"} {
+		student := &models.StudentData{StudentID: id, FilePath: id + ".xlsx", Marks: map[string]float64{"C6": 7.5}}
+		if err := c.Record(student); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	c.Close()
+
+	// An interruption while writing leaves half a line behind
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open checkpoint: %v", err)
+	}
+	f.WriteString(`{"student_id":"STU003","file_pa`)
+	f.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		config  string
+		master  string
+		wantErr error
+	}{
+		{name: "missing", path: filepath.Join(t.TempDir(), FileName), config: "config", master: "master", wantErr: ErrNoCheckpoint},
+		{name: "config changed", path: path, config: "other", master: "master", wantErr: ErrConfigChanged},
+		{name: "master changed", path: path, config: "config", master: "other", wantErr: ErrMasterChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Resume(tt.path, tt.config, tt.master); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resume() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	resumed, err := Resume(path, "config", "master")
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if resumed.RunID != "run-1" || resumed.Len() != 2 || resumed.Completed("STU002.xlsx").Marks["C6"] != 7.5 {
+		t.Fatalf("Resume() = run %s with %d files, want run-1 with the 2 complete records", resumed.RunID, resumed.Len())
+	}
+	if resumed.Completed("STU003.xlsx") != nil {
+		t.Error("Completed() returned the record cut off mid-line")
+	}
+
+	// Records appended after resuming follow the last complete line
+	if err := resumed.Record(&models.StudentData{StudentID: "STU003", FilePath: "STU003.xlsx"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	resumed.Close()
+	loaded, err := Load(path)
+	if err != nil || loaded.Len() != 3 {
+		t.Fatalf("Load() = %v, %v, want 3 records", loaded, err)
+	}
+
+	if err := loaded.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if missing, err := Load(path); missing != nil || err != nil {
+		t.Errorf("Load() after Remove() = %v, %v, want nil", missing, err)
+	}
+}
@@ -5,16 +5,28 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
+// hexColorPattern matches RGB hex colors with an optional leading '#'
+var hexColorPattern = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
 // Config represents the application configuration
 type Config struct {
 	Paths      PathsConfig      `toml:"paths"`
 	Excel      ExcelConfig      `toml:"excel_settings"`
 	Processing ProcessingConfig `toml:"processing"`
+	Discovery  DiscoveryConfig  `toml:"discovery"`
+	Backup     BackupConfig     `toml:"backup"`
+	Watch      WatchConfig      `toml:"watch"`
+	Quarantine QuarantineConfig `toml:"quarantine"`
 	Logging    LoggingConfig    `toml:"logging"`
 }
 
@@ -29,20 +41,328 @@ type PathsConfig struct {
 
 // ExcelConfig contains Excel-specific settings
 type ExcelConfig struct {
-	StudentWorksheetName string   `toml:"student_worksheet_name"`
-	MasterWorksheetName  string   `toml:"master_worksheet_name"`
-	StudentIDCell        string   `toml:"student_id_cell"`
-	MarkCells            []string `toml:"mark_cells"`
-	MasterColumns        []string `toml:"master_columns"`
+	StudentWorksheetName string         `toml:"student_worksheet_name"`
+	MasterWorksheetName  string         `toml:"master_worksheet_name"`
+	StudentIDCell        string         `toml:"student_id_cell"`
+	MarkCells            []string       `toml:"mark_cells"`
+	MasterColumns        []string       `toml:"master_columns"`
+	HighlightUpdates     bool           `toml:"highlight_updates"`
+	HighlightColor       string         `toml:"highlight_color"`
+	CommentUpdates       bool           `toml:"comment_updates"`
+	AuditSheet           bool           `toml:"audit_sheet"`
+	AuditSheetName       string         `toml:"audit_sheet_name"`
+	AuditSheetPassword   string         `toml:"audit_sheet_password"`
+	StudentNameCell      string         `toml:"student_name_cell"`
+	AppendUnmatched      bool           `toml:"append_unmatched_students"`
+	TemplateRow          int            `toml:"template_row"`
+	MasterNameColumn     string         `toml:"master_name_column"`
+	OverwriteProtected   bool           `toml:"overwrite_protected_cells"`
+	RecalculateFormulas  bool           `toml:"recalculate_formulas"`
+	Derived              DerivedConfig  `toml:"derived"`
+	Rounding             RoundingConfig `toml:"rounding"`
+}
+
+// Derived column modes
+const (
+	DerivedModeFormula = "formula"
+	DerivedModeValue   = "value"
+)
+
+// DerivedConfig describes computed total and grade columns in the master sheet.
+// Weights are keyed by master column and must reference mapped criteria; with no
+// weights every mapped column counts once.
+type DerivedConfig struct {
+	Mode         string             `toml:"mode"`
+	TotalColumn  string             `toml:"total_column"`
+	Weights      map[string]float64 `toml:"weights"`
+	GradeColumn  string             `toml:"grade_column"`
+	GradeBands   []GradeBand        `toml:"grade_bands"`
+	DefaultGrade string             `toml:"default_grade"`
+}
+
+// GradeBand assigns a grade to totals at or above Min
+type GradeBand struct {
+	Grade string  `toml:"grade"`
+	Min   float64 `toml:"min"`
+}
+
+// Enabled reports whether any derived column is configured
+func (d DerivedConfig) Enabled() bool {
+	return d.TotalColumn != ""
+}
+
+// UsesFormulas reports whether derived columns are written as Excel formulas
+func (d DerivedConfig) UsesFormulas() bool {
+	return d.Mode == "" || d.Mode == DerivedModeFormula
+}
+
+// SortedBands returns the grade bands ordered from the highest minimum down
+func (d DerivedConfig) SortedBands() []GradeBand {
+	bands := append([]GradeBand(nil), d.GradeBands...)
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].Min > bands[j].Min })
+	return bands
+}
+
+// Grade returns the grade for a total
+func (d DerivedConfig) Grade(total float64) string {
+	for _, band := range d.SortedBands() {
+		if total >= band.Min {
+			return band.Grade
+		}
+	}
+	return d.DefaultGrade
+}
+
+// WeightFor returns the weight of a mapped master column and whether it counts towards the total
+func (d DerivedConfig) WeightFor(column string) (float64, bool) {
+	if len(d.Weights) == 0 {
+		return 1, true
+	}
+	for key, weight := range d.Weights {
+		if strings.EqualFold(key, column) {
+			return weight, true
+		}
+	}
+	return 0, false
+}
+
+// validate checks the derived columns against the mapped master columns
+func (d DerivedConfig) validate(masterColumns []string) error {
+	if d.Mode != "" && d.Mode != DerivedModeFormula && d.Mode != DerivedModeValue {
+		return fmt.Errorf("derived mode must be %q or %q, got %q", DerivedModeFormula, DerivedModeValue, d.Mode)
+	}
+	if !d.Enabled() {
+		if d.GradeColumn != "" || len(d.Weights) > 0 {
+			return fmt.Errorf("derived total_column is required for weights and grades")
+		}
+		return nil
+	}
+
+	mapped := make(map[string]bool, len(masterColumns))
+	for _, column := range masterColumns {
+		mapped[strings.ToUpper(column)] = true
+	}
+
+	for key := range d.Weights {
+		if !mapped[strings.ToUpper(key)] {
+			return fmt.Errorf("derived weight %q does not reference a mapped master column", key)
+		}
+	}
+
+	for _, column := range []string{d.TotalColumn, d.GradeColumn} {
+		if column != "" && mapped[strings.ToUpper(column)] {
+			return fmt.Errorf("derived column %s is also a mapped mark column", column)
+		}
+	}
+	if d.GradeColumn != "" && strings.EqualFold(d.GradeColumn, d.TotalColumn) {
+		return fmt.Errorf("derived grade_column and total_column must differ")
+	}
+	if d.GradeColumn == "" && len(d.GradeBands) > 0 {
+		return fmt.Errorf("derived grade_bands require a grade_column")
+	}
+
+	seen := make(map[string]bool)
+	for _, band := range d.GradeBands {
+		if band.Grade == "" {
+			return fmt.Errorf("derived grade band with min %g has no grade", band.Min)
+		}
+		if seen[band.Grade] {
+			return fmt.Errorf("derived grade %q is listed twice", band.Grade)
+		}
+		seen[band.Grade] = true
+	}
+
+	return nil
 }
 
 // ProcessingConfig contains processing-related settings
 type ProcessingConfig struct {
-	MaxConcurrentFiles int  `toml:"max_concurrent_files"`
-	BackupEnabled      bool `toml:"backup_enabled"`
-	SkipInvalidFiles   bool `toml:"skip_invalid_files"`
-	TimeoutSeconds     int  `toml:"timeout_seconds"`
-	RetryAttempts      int  `toml:"retry_attempts"`
+	MaxConcurrentFiles int    `toml:"max_concurrent_files"`
+	BackupEnabled      bool   `toml:"backup_enabled"`
+	SkipInvalidFiles   bool   `toml:"skip_invalid_files"`
+	TimeoutSeconds     int    `toml:"timeout_seconds"`
+	RetryAttempts      int    `toml:"retry_attempts"`
+	LockStaleMinutes   int    `toml:"lock_stale_minutes"`
+	OnCancel           string `toml:"on_cancel"`
+	Incremental        bool   `toml:"incremental"`
+
+	RetryBackoffSeconds    float64 `toml:"retry_backoff_seconds"`
+	RetryMaxBackoffSeconds float64 `toml:"retry_max_backoff_seconds"`
+	RetryJitter            float64 `toml:"retry_jitter"`
+	FileTimeoutSeconds     float64 `toml:"file_timeout_seconds"`
+}
+
+// DefaultFileTimeout is how long reading a single student file may take
+const DefaultFileTimeout = time.Minute
+
+// FileTimeout returns how long reading a single student file may take before it is abandoned
+func (p ProcessingConfig) FileTimeout() time.Duration {
+	if p.FileTimeoutSeconds <= 0 {
+		return DefaultFileTimeout
+	}
+	return time.Duration(p.FileTimeoutSeconds * float64(time.Second))
+}
+
+// Default retry backoff, doubled after every failed attempt up to the maximum
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+// RetryBackoff returns the wait before retrying after the given failed attempt (1-based),
+// without jitter: the initial backoff doubled for every earlier attempt, capped at the maximum
+func (p ProcessingConfig) RetryBackoff(attempt int) time.Duration {
+	initial := DefaultRetryBackoff
+	if p.RetryBackoffSeconds > 0 {
+		initial = time.Duration(p.RetryBackoffSeconds * float64(time.Second))
+	}
+	maximum := DefaultRetryMaxBackoff
+	if p.RetryMaxBackoffSeconds > 0 {
+		maximum = time.Duration(p.RetryMaxBackoffSeconds * float64(time.Second))
+	}
+
+	backoff := initial
+	for i := 1; i < attempt && backoff < maximum; i++ {
+		backoff *= 2
+	}
+	if backoff > maximum {
+		return maximum
+	}
+	return backoff
+}
+
+// Policies for the results of a cancelled run
+const (
+	OnCancelDiscard = "discard"
+	OnCancelCommit  = "commit"
+)
+
+// CancelPolicy returns what happens to the files read before a run is cancelled,
+// defaulting to discarding them without touching the master sheet
+func (p ProcessingConfig) CancelPolicy() string {
+	if p.OnCancel == "" {
+		return OnCancelDiscard
+	}
+	return p.OnCancel
+}
+
+// LockStaleAfter returns how old a master sheet lock held by another host must be to count as abandoned.
+// Zero means the lock package default.
+func (p ProcessingConfig) LockStaleAfter() time.Duration {
+	return time.Duration(p.LockStaleMinutes) * time.Minute
+}
+
+// Symlink policies for file discovery
+const (
+	SymlinksFiles  = "files"
+	SymlinksFollow = "follow"
+	SymlinksSkip   = "skip"
+)
+
+// DiscoveryConfig controls which files in the student files folder are processed.
+// Patterns without a slash match file and folder names at any level; patterns with
+// a slash match the path relative to the student files folder and may use "**".
+type DiscoveryConfig struct {
+	Include  []string `toml:"include"`
+	Exclude  []string `toml:"exclude"`
+	MaxDepth int      `toml:"max_depth"`
+	Symlinks string   `toml:"symlinks"`
+}
+
+// SymlinkPolicy returns the configured symlink policy, defaulting to following links to files only
+func (d DiscoveryConfig) SymlinkPolicy() string {
+	if d.Symlinks == "" {
+		return SymlinksFiles
+	}
+	return d.Symlinks
+}
+
+// validate checks the discovery patterns, depth and symlink policy
+func (d DiscoveryConfig) validate() error {
+	for _, pattern := range append(append([]string(nil), d.Include...), d.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid discovery pattern %q: %w", pattern, err)
+		}
+	}
+	if d.MaxDepth < 0 {
+		return fmt.Errorf("max_depth cannot be negative")
+	}
+	switch d.SymlinkPolicy() {
+	case SymlinksFiles, SymlinksFollow, SymlinksSkip:
+	default:
+		return fmt.Errorf("symlinks must be %q, %q or %q, got %q", SymlinksFiles, SymlinksFollow, SymlinksSkip, d.Symlinks)
+	}
+	return nil
+}
+
+// BackupConfig contains backup retention settings.
+// A zero value for any limit disables that rule.
+type BackupConfig struct {
+	KeepLast       int `toml:"keep_last"`
+	MaxAgeDays     int `toml:"max_age_days"`
+	MaxTotalSizeMB int `toml:"max_total_size_mb"`
+}
+
+// HasRetention reports whether any retention rule is configured
+func (b BackupConfig) HasRetention() bool {
+	return b.KeepLast > 0 || b.MaxAgeDays > 0 || b.MaxTotalSizeMB > 0
+}
+
+// Default watch mode intervals
+const (
+	DefaultWatchDebounce = 5 * time.Second
+	DefaultWatchSettle   = 2 * time.Second
+)
+
+// WatchConfig contains the timing of watch mode. Zero values use the defaults.
+type WatchConfig struct {
+	DebounceSeconds float64 `toml:"debounce_seconds"`
+	SettleSeconds   float64 `toml:"settle_seconds"`
+}
+
+// Debounce returns how long the student files folder must be quiet before a run starts
+func (w WatchConfig) Debounce() time.Duration {
+	if w.DebounceSeconds <= 0 {
+		return DefaultWatchDebounce
+	}
+	return time.Duration(w.DebounceSeconds * float64(time.Second))
+}
+
+// Settle returns how long changed files must keep their size and time before they are read
+func (w WatchConfig) Settle() time.Duration {
+	if w.SettleSeconds <= 0 {
+		return DefaultWatchSettle
+	}
+	return time.Duration(w.SettleSeconds * float64(time.Second))
+}
+
+// What happens to student files that fail validation
+const (
+	QuarantineOff  = "off"
+	QuarantineMove = "move"
+	QuarantineCopy = "copy"
+)
+
+// QuarantineConfig controls setting aside student files that fail validation
+type QuarantineConfig struct {
+	Mode   string `toml:"mode"`
+	Folder string `toml:"folder"`
+}
+
+// Action returns what happens to failing files, defaulting to leaving them in place
+func (q QuarantineConfig) Action() string {
+	if q.Mode == "" {
+		return QuarantineOff
+	}
+	return q.Mode
+}
+
+// QuarantineFolder returns the quarantine folder, defaulting to "quarantine" in the output folder
+func (c *Config) QuarantineFolder() string {
+	if c.Quarantine.Folder == "" {
+		return filepath.Join(c.Paths.OutputFolder, "quarantine")
+	}
+	return c.Quarantine.Folder
 }
 
 // LoggingConfig contains logging settings
@@ -100,6 +420,22 @@ func (c *Config) Validate() error {
 	if len(c.Excel.MarkCells) == 0 {
 		return fmt.Errorf("mark_cells cannot be empty")
 	}
+	if c.Excel.HighlightColor != "" && !hexColorPattern.MatchString(c.Excel.HighlightColor) {
+		return fmt.Errorf("highlight_color must be a hex color such as \"FFF2CC\", got %q", c.Excel.HighlightColor)
+	}
+	if c.Excel.AuditSheet && c.Excel.AuditSheetName != "" && c.Excel.AuditSheetName == c.Excel.MasterWorksheetName {
+		return fmt.Errorf("audit_sheet_name must differ from master_worksheet_name")
+	}
+	if c.Excel.TemplateRow < 0 {
+		return fmt.Errorf("template_row cannot be negative")
+	}
+	if err := c.Excel.Derived.validate(c.Excel.MasterColumns); err != nil {
+		return err
+	}
+	roundedColumns := append([]string{c.Excel.Derived.TotalColumn}, c.Excel.MasterColumns...)
+	if err := c.Excel.Rounding.validate(roundedColumns); err != nil {
+		return err
+	}
 
 	// Validate processing settings
 	if c.Processing.MaxConcurrentFiles <= 0 {
@@ -108,6 +444,51 @@ func (c *Config) Validate() error {
 	if c.Processing.TimeoutSeconds <= 0 {
 		return fmt.Errorf("timeout_seconds must be greater than 0")
 	}
+	if c.Processing.LockStaleMinutes < 0 {
+		return fmt.Errorf("lock_stale_minutes cannot be negative")
+	}
+	switch c.Processing.CancelPolicy() {
+	case OnCancelDiscard, OnCancelCommit:
+	default:
+		return fmt.Errorf("on_cancel must be %q or %q, got %q", OnCancelDiscard, OnCancelCommit, c.Processing.OnCancel)
+	}
+	if c.Processing.RetryBackoffSeconds < 0 || c.Processing.RetryMaxBackoffSeconds < 0 {
+		return fmt.Errorf("retry_backoff_seconds and retry_max_backoff_seconds cannot be negative")
+	}
+	if c.Processing.RetryJitter < 0 || c.Processing.RetryJitter > 1 {
+		return fmt.Errorf("retry_jitter must be between 0 and 1, got %v", c.Processing.RetryJitter)
+	}
+	if c.Processing.FileTimeoutSeconds < 0 {
+		return fmt.Errorf("file_timeout_seconds cannot be negative")
+	}
+
+	// Validate quarantine settings
+	switch c.Quarantine.Action() {
+	case QuarantineOff, QuarantineMove, QuarantineCopy:
+	default:
+		return fmt.Errorf("quarantine mode must be %q, %q or %q, got %q", QuarantineOff, QuarantineMove, QuarantineCopy, c.Quarantine.Mode)
+	}
+
+	// Validate watch settings
+	if c.Watch.DebounceSeconds < 0 || c.Watch.SettleSeconds < 0 {
+		return fmt.Errorf("debounce_seconds and settle_seconds cannot be negative")
+	}
+
+	// Validate discovery settings
+	if err := c.Discovery.validate(); err != nil {
+		return err
+	}
+
+	// Validate backup retention settings
+	if c.Backup.KeepLast < 0 {
+		return fmt.Errorf("keep_last cannot be negative")
+	}
+	if c.Backup.MaxAgeDays < 0 {
+		return fmt.Errorf("max_age_days cannot be negative")
+	}
+	if c.Backup.MaxTotalSizeMB < 0 {
+		return fmt.Errorf("max_total_size_mb cannot be negative")
+	}
 
 	return nil
 }
@@ -141,6 +522,13 @@ func (c *Config) ResolvePaths() error {
 		return fmt.Errorf("failed to resolve backup_folder: %w", err)
 	}
 
+	// Resolve quarantine folder
+	if c.Quarantine.Folder != "" {
+		if c.Quarantine.Folder, err = filepath.Abs(c.Quarantine.Folder); err != nil {
+			return fmt.Errorf("failed to resolve quarantine folder: %w", err)
+		}
+	}
+
 	return nil
 }
 
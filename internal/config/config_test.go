@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -88,6 +89,234 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative backup retention",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6", "C7"},
+					MasterColumns: []string{"I", "J"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+				Backup: BackupConfig{
+					KeepLast: -1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid highlight color",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:      []string{"C6", "C7"},
+					MasterColumns:  []string{"I", "J"},
+					HighlightColor: "yellow",
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown symlink policy",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+				Discovery: DiscoveryConfig{Symlinks: "always"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown cancel policy",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+					OnCancel:           "keep",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative watch debounce",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+				Watch: WatchConfig{DebounceSeconds: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retry jitter above one",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+					RetryJitter:        1.5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown quarantine mode",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+				Quarantine: QuarantineConfig{Mode: "delete"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative file timeout",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6"},
+					MasterColumns: []string{"I"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+					FileTimeoutSeconds: -1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "derived weight on unmapped column",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6", "C7"},
+					MasterColumns: []string{"I", "J"},
+					Derived: DerivedConfig{
+						TotalColumn: "W",
+						Weights:     map[string]float64{"I": 0.5, "K": 0.5},
+					},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "derived total overwrites mark column",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6", "C7"},
+					MasterColumns: []string{"I", "J"},
+					Derived:       DerivedConfig{TotalColumn: "j"},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "derived grade bands without grade column",
+			config: Config{
+				Paths: PathsConfig{
+					StudentFilesFolder: "./students",
+					MasterSheetPath:    "./master.xlsx",
+					OutputFolder:       "./output",
+				},
+				Excel: ExcelConfig{
+					MarkCells:     []string{"C6", "C7"},
+					MasterColumns: []string{"I", "J"},
+					Derived: DerivedConfig{
+						TotalColumn: "W",
+						GradeBands:  []GradeBand{{Grade: "A", Min: 70}},
+					},
+				},
+				Processing: ProcessingConfig{
+					MaxConcurrentFiles: 5,
+					TimeoutSeconds:     300,
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -231,3 +460,52 @@ func TestConfig_ResolvePaths(t *testing.T) {
 		t.Error("OutputFolder should be absolute after ResolvePaths()")
 	}
 }
+
+// TestDerivedConfig_Grade tests mapping totals to grade bands
+func TestDerivedConfig_Grade(t *testing.T) {
+	d := DerivedConfig{
+		GradeBands:   []GradeBand{{Grade: "C", Min: 50}, {Grade: "A", Min: 70}, {Grade: "B", Min: 60}},
+		DefaultGrade: "F",
+	}
+
+	tests := []struct {
+		total float64
+		want  string
+	}{
+		{85, "A"},
+		{70, "A"},
+		{69.99, "B"},
+		{50, "C"},
+		{12, "F"},
+	}
+
+	for _, tt := range tests {
+		if got := d.Grade(tt.total); got != tt.want {
+			t.Errorf("Grade(%v) = %q, want %q", tt.total, got, tt.want)
+		}
+	}
+}
+
+// TestProcessingConfig_RetryBackoff tests the exponential retry backoff and its cap
+func TestProcessingConfig_RetryBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ProcessingConfig
+		attempt int
+		want    time.Duration
+	}{
+		{"default first", ProcessingConfig{}, 1, time.Second},
+		{"default third", ProcessingConfig{}, 3, 4 * time.Second},
+		{"default capped", ProcessingConfig{}, 10, 30 * time.Second},
+		{"configured", ProcessingConfig{RetryBackoffSeconds: 0.25}, 3, time.Second},
+		{"configured cap", ProcessingConfig{RetryBackoffSeconds: 2, RetryMaxBackoffSeconds: 5}, 3, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.RetryBackoff(tt.attempt); got != tt.want {
+				t.Errorf("RetryBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
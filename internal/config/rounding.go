@@ -0,0 +1,154 @@
+// Package config provides configuration management for the Mark Master Sheet Consolidator.
+// This file contains the numeric precision and rounding policy for values written to the master sheet.
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding modes
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundFloor    = "floor"
+	RoundCeil     = "ceil"
+)
+
+// DefaultPrecision is the number of decimal places written when no precision is configured
+const DefaultPrecision = 2
+
+// maxPrecision bounds configured precisions to what a float64 mark can meaningfully hold
+const maxPrecision = 10
+
+// RoundingConfig describes how numbers are rounded before they are written to the master sheet.
+// Columns overrides the default per master column, including the derived total column.
+type RoundingConfig struct {
+	Mode      string                  `toml:"mode"`
+	Precision *int                    `toml:"precision"`
+	Columns   map[string]RoundingRule `toml:"columns"`
+}
+
+// RoundingRule is the precision and rounding mode applied to a single column.
+// Unset fields fall back to the defaults of the RoundingConfig.
+type RoundingRule struct {
+	Mode      string `toml:"mode"`
+	Precision *int   `toml:"precision"`
+}
+
+// For returns the effective rule for a master column, with mode and precision always set
+func (r RoundingConfig) For(column string) RoundingRule {
+	mode, precision := r.Mode, DefaultPrecision
+	if mode == "" {
+		mode = RoundHalfEven
+	}
+	if r.Precision != nil {
+		precision = *r.Precision
+	}
+
+	for key, rule := range r.Columns {
+		if !strings.EqualFold(key, column) {
+			continue
+		}
+		if rule.Mode != "" {
+			mode = rule.Mode
+		}
+		if rule.Precision != nil {
+			precision = *rule.Precision
+		}
+	}
+
+	return RoundingRule{Mode: mode, Precision: &precision}
+}
+
+// Places returns the rule's precision, or DefaultPrecision if unset
+func (r RoundingRule) Places() int {
+	if r.Precision == nil {
+		return DefaultPrecision
+	}
+	return *r.Precision
+}
+
+// Apply rounds a value according to the rule. Rounding is done on the shortest
+// decimal representation of the value, so 0.15 rounds half-up to 0.2 rather
+// than down because of its binary approximation.
+func (r RoundingRule) Apply(value float64) float64 {
+	exact, ok := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	if !ok {
+		return value // NaN or infinity
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(r.Places())), nil)
+	exact.Mul(exact, new(big.Rat).SetInt(scale))
+
+	// Euclidean division gives the floor for a positive denominator
+	num, den := exact.Num(), exact.Denom()
+	quotient, remainder := new(big.Int).DivMod(num, den, new(big.Int))
+
+	if remainder.Sign() != 0 {
+		half := new(big.Int).Lsh(remainder, 1).Cmp(den)
+		switch r.Mode {
+		case RoundFloor:
+		case RoundCeil:
+			quotient.Add(quotient, big.NewInt(1))
+		case RoundHalfUp:
+			// Ties round away from zero, matching Excel's ROUND
+			if half > 0 || (half == 0 && num.Sign() > 0) {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		default:
+			if half > 0 || (half == 0 && quotient.Bit(0) == 1) {
+				quotient.Add(quotient, big.NewInt(1))
+			}
+		}
+	}
+
+	rounded, _ := new(big.Rat).SetFrac(quotient, scale).Float64()
+	return rounded
+}
+
+// Equal reports whether two values are equal once rounded by the rule
+func (r RoundingRule) Equal(a, b float64) bool {
+	return r.Apply(a) == r.Apply(b)
+}
+
+// validate checks the rounding modes, precisions and column keys
+func (r RoundingConfig) validate(columns []string) error {
+	if err := (RoundingRule{Mode: r.Mode, Precision: r.Precision}).validate("rounding"); err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if column != "" {
+			known[strings.ToUpper(column)] = true
+		}
+	}
+
+	for key, rule := range r.Columns {
+		if !known[strings.ToUpper(key)] {
+			return fmt.Errorf("rounding column %q is neither a mapped master column nor a derived column", key)
+		}
+		if err := rule.validate("rounding column " + key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks a single rule, naming it in errors
+func (r RoundingRule) validate(name string) error {
+	switch r.Mode {
+	case "", RoundHalfUp, RoundHalfEven, RoundFloor, RoundCeil:
+	default:
+		return fmt.Errorf("%s mode must be one of %s, %s, %s or %s, got %q",
+			name, RoundHalfUp, RoundHalfEven, RoundFloor, RoundCeil, r.Mode)
+	}
+	if r.Precision != nil && (*r.Precision < 0 || *r.Precision > maxPrecision) {
+		return fmt.Errorf("%s precision must be between 0 and %d, got %d", name, maxPrecision, *r.Precision)
+	}
+	return nil
+}
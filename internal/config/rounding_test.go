@@ -0,0 +1,94 @@
+package config
+
+import "testing"
+
+func intPtr(v int) *int { return &v }
+
+// TestRoundingRule_Apply tests each rounding mode at different precisions
+func TestRoundingRule_Apply(t *testing.T) {
+	tests := []struct {
+		mode      string
+		precision int
+		value     float64
+		want      float64
+	}{
+		{RoundHalfUp, 1, 0.15, 0.2},
+		{RoundHalfUp, 1, 2.25, 2.3},
+		{RoundHalfUp, 0, 72.5, 73},
+		{RoundHalfUp, 0, -2.5, -3},
+		{RoundHalfEven, 1, 2.25, 2.2},
+		{RoundHalfEven, 1, 2.35, 2.4},
+		{RoundHalfEven, 0, 72.5, 72},
+		{RoundHalfEven, 2, 85.555, 85.56},
+		{RoundFloor, 1, 7.99, 7.9},
+		{RoundFloor, 0, -1.2, -2},
+		{RoundCeil, 1, 7.01, 7.1},
+		{RoundCeil, 0, 7, 7},
+		{RoundHalfUp, 1, 7.3, 7.3},
+	}
+
+	for _, tt := range tests {
+		rule := RoundingRule{Mode: tt.mode, Precision: intPtr(tt.precision)}
+		if got := rule.Apply(tt.value); got != tt.want {
+			t.Errorf("%s/%d Apply(%v) = %v, want %v", tt.mode, tt.precision, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestRoundingConfig_For tests resolving column overrides against the defaults
+func TestRoundingConfig_For(t *testing.T) {
+	r := RoundingConfig{
+		Mode:      RoundHalfUp,
+		Precision: intPtr(1),
+		Columns: map[string]RoundingRule{
+			"w": {Precision: intPtr(0)},
+			"J": {Mode: RoundFloor},
+		},
+	}
+
+	tests := []struct {
+		column    string
+		mode      string
+		precision int
+	}{
+		{"I", RoundHalfUp, 1},
+		{"W", RoundHalfUp, 0},
+		{"J", RoundFloor, 1},
+	}
+	for _, tt := range tests {
+		rule := r.For(tt.column)
+		if rule.Mode != tt.mode || rule.Places() != tt.precision {
+			t.Errorf("For(%s) = %s/%d, want %s/%d", tt.column, rule.Mode, rule.Places(), tt.mode, tt.precision)
+		}
+	}
+
+	defaults := RoundingConfig{}.For("I")
+	if defaults.Mode != RoundHalfEven || defaults.Places() != DefaultPrecision {
+		t.Errorf("default rule = %s/%d, want %s/%d", defaults.Mode, defaults.Places(), RoundHalfEven, DefaultPrecision)
+	}
+}
+
+// TestRoundingConfig_Validate tests rejecting unknown modes, precisions and columns
+func TestRoundingConfig_Validate(t *testing.T) {
+	columns := []string{"W", "I", "J"}
+	tests := []struct {
+		name    string
+		config  RoundingConfig
+		wantErr bool
+	}{
+		{"empty", RoundingConfig{}, false},
+		{"valid", RoundingConfig{Mode: RoundHalfUp, Precision: intPtr(1), Columns: map[string]RoundingRule{"w": {Precision: intPtr(0)}}}, false},
+		{"unknown mode", RoundingConfig{Mode: "bankers"}, true},
+		{"negative precision", RoundingConfig{Precision: intPtr(-1)}, true},
+		{"unmapped column", RoundingConfig{Columns: map[string]RoundingRule{"Z": {Mode: RoundCeil}}}, true},
+		{"bad column mode", RoundingConfig{Columns: map[string]RoundingRule{"I": {Mode: "up"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(columns); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
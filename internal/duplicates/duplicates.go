@@ -0,0 +1,143 @@
+// Package duplicates finds student submissions that look copied from each other: byte-identical
+// files, and different students with identical marks for every criterion.
+package duplicates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mark-master-sheet/internal/archive"
+	"mark-master-sheet/internal/fileutil"
+	"mark-master-sheet/pkg/models"
+)
+
+// MinMarks is how many criteria must have a non-zero mark before identical marks are flagged,
+// since students who were not marked or scored zero throughout match by coincidence
+const MinMarks = 2
+
+// Submission is a student file read by a run
+type Submission struct {
+	Path      string
+	StudentID string
+	Marks     map[string]float64
+	SHA256    string // content hash, computed by Find when empty
+}
+
+// Find returns the groups of byte-identical files, kept apart by whether they name different
+// students or the same one, and of different students with identical mark vectors, sorted by kind and first student. Files that cannot be hashed are left out
+// of the content comparison and reported through onError.
+func Find(submissions []Submission, onError func(path string, err error)) []models.DuplicateGroup {
+	byContent := make(map[string][]Submission)
+	byMarks := make(map[string][]Submission)
+
+	for _, s := range submissions {
+		hash := s.SHA256
+		if hash == "" {
+			var err error
+			if hash, err = hashFile(s.Path); err != nil {
+				onError(s.Path, err)
+			}
+		}
+		if hash != "" {
+			byContent[hash] = append(byContent[hash], s)
+		}
+		if key, ok := marksKey(s.Marks); ok {
+			byMarks[key] = append(byMarks[key], s)
+		}
+	}
+
+	var groups []models.DuplicateGroup
+	for hash, members := range byContent {
+		switch {
+		case distinctStudents(members) > 1:
+			groups = append(groups, newGroup(models.DuplicateIdenticalFile, hash, members))
+		case len(members) > 1:
+			groups = append(groups, newGroup(models.DuplicateRepeatedFile, hash, members))
+		}
+	}
+	for key, members := range byMarks {
+		if distinctStudents(members) > 1 {
+			groups = append(groups, newGroup(models.DuplicateIdenticalMarks, key, members))
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Kind != groups[j].Kind {
+			return groups[i].Kind < groups[j].Kind
+		}
+		return groups[i].Students[0] < groups[j].Students[0]
+	})
+	return groups
+}
+
+// marksKey returns the hash of a mark vector, ordered by cell, or false when too few
+// criteria have a non-zero mark to be telling
+func marksKey(marks map[string]float64) (string, bool) {
+	cells := make([]string, 0, len(marks))
+	scored := 0
+	for cell, mark := range marks {
+		cells = append(cells, cell)
+		if mark > 0 {
+			scored++
+		}
+	}
+	if scored < MinMarks {
+		return "", false
+	}
+	sort.Strings(cells)
+
+	var b strings.Builder
+	for _, cell := range cells {
+		fmt.Fprintf(&b, "%s=%s;", cell, strconv.FormatFloat(marks[cell], 'g', -1, 64))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// newGroup builds a duplicate group with its students and files sorted
+func newGroup(kind, hash string, members []Submission) models.DuplicateGroup {
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].StudentID != members[j].StudentID {
+			return members[i].StudentID < members[j].StudentID
+		}
+		return members[i].Path < members[j].Path
+	})
+
+	group := models.DuplicateGroup{Kind: kind, Hash: hash}
+	for _, m := range members {
+		group.Students = append(group.Students, m.StudentID)
+		group.Files = append(group.Files, m.Path)
+	}
+	if kind == models.DuplicateIdenticalMarks {
+		group.Marks = members[0].Marks
+	}
+	return group
+}
+
+// distinctStudents counts the different student IDs, ignoring case
+func distinctStudents(members []Submission) int {
+	seen := make(map[string]bool)
+	for _, m := range members {
+		seen[strings.ToUpper(m.StudentID)] = true
+	}
+	return len(seen)
+}
+
+// hashFile returns the content hash of a file on disk or inside an archive
+func hashFile(path string) (string, error) {
+	if archive.IsArchivePath(path) {
+		data, err := archive.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		hash, _, err := fileutil.HashReader(bytes.NewReader(data))
+		return hash, err
+	}
+	hash, _, err := fileutil.HashFile(path)
+	return hash, err
+}
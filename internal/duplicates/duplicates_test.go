@@ -0,0 +1,63 @@
+package duplicates
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mark-master-sheet/pkg/models"
+)
+
+// TestFind tests grouping byte-identical files, apart from one student's repeated files, and
+// identical mark vectors of different students
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		return path
+	}
+
+	marks := map[string]float64{"C6": 7, "C7": 8.5, "C8": -1}
+	submissions := []Submission{
+		// The same file handed in by different students
+		{Path: write("alice.xlsx", "same"), StudentID: "STU001", Marks: map[string]float64{"C6": 1, "C7": 2}},
+		{Path: write("grace.xlsx", "same"), StudentID: "STU008", Marks: map[string]float64{"C6": 1, "C7": 2}},
+		// The same file handed in twice by one student
+		{Path: write("henry.xlsx", "henry"), StudentID: "STU009", Marks: map[string]float64{"C6": 6}},
+		{Path: write("henry-copy.xlsx", "henry"), StudentID: "stu009", Marks: map[string]float64{"C6": 6}},
+		// Different students with the same marks
+		{Path: write("bob.xlsx", "bob"), StudentID: "STU003", Marks: marks},
+		{Path: write("carol.xlsx", "carol"), StudentID: "STU002", Marks: marks},
+		// One student's resubmission with unchanged marks
+		{Path: write("dave.xlsx", "dave"), StudentID: "STU004", Marks: map[string]float64{"C6": 3, "C7": 4}},
+		{Path: write("dave2.xlsx", "dave2"), StudentID: "stu004", Marks: map[string]float64{"C6": 3, "C7": 4}},
+		// Too few marks to be telling
+		{Path: write("erin.xlsx", "erin"), StudentID: "STU005", Marks: map[string]float64{"C6": 5, "C7": 0}},
+		{Path: write("frank.xlsx", "frank"), StudentID: "STU006", Marks: map[string]float64{"C6": 5, "C7": 0}},
+	}
+
+	var failed []string
+	submissions = append(submissions, Submission{Path: filepath.Join(dir, "gone.xlsx"), StudentID: "STU007"})
+	groups := Find(submissions, func(path string, err error) { failed = append(failed, filepath.Base(path)) })
+
+	want := []models.DuplicateGroup{
+		{Kind: models.DuplicateIdenticalFile, Students: []string{"STU001", "STU008"}, Files: []string{filepath.Join(dir, "alice.xlsx"), filepath.Join(dir, "grace.xlsx")}},
+		{Kind: models.DuplicateIdenticalMarks, Students: []string{"STU001", "STU008"}, Files: []string{filepath.Join(dir, "alice.xlsx"), filepath.Join(dir, "grace.xlsx")}, Marks: map[string]float64{"C6": 1, "C7": 2}},
+		{Kind: models.DuplicateIdenticalMarks, Students: []string{"STU002", "STU003"}, Files: []string{filepath.Join(dir, "carol.xlsx"), filepath.Join(dir, "bob.xlsx")}, Marks: marks},
+		{Kind: models.DuplicateRepeatedFile, Students: []string{"STU009", "stu009"}, Files: []string{filepath.Join(dir, "henry.xlsx"), filepath.Join(dir, "henry-copy.xlsx")}},
+	}
+	for i := range groups {
+		groups[i].Hash = ""
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("Find() = %+v, want %+v", groups, want)
+	}
+	if !reflect.DeepEqual(failed, []string{"gone.xlsx"}) {
+		t.Errorf("hash failures = %v, want gone.xlsx", failed)
+	}
+}
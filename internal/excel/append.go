@@ -0,0 +1,200 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains appending rows for students missing from the master sheet.
+package excel
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/pkg/models"
+)
+
+// DefaultNameColumn is the master sheet column holding student names when none is configured
+const DefaultNameColumn = "A"
+
+// reviewColor is the fill marking appended rows that need review
+const reviewColor = "FFC7CE"
+
+// cellRefPattern matches A1-style cell references such as I5, $I5 or I$5
+var cellRefPattern = regexp.MustCompile(`\$?[A-Za-z]{1,3}\$?[0-9]+`)
+
+// nameColumn returns the configured master name column or the default
+func (w *Writer) nameColumn() string {
+	if w.config.MasterNameColumn != "" {
+		return strings.ToUpper(w.config.MasterNameColumn)
+	}
+	return DefaultNameColumn
+}
+
+// appendStudentRow adds a row for a student missing from the master sheet. Styles
+// and formulas are copied from the template row, except formulas in the mark columns
+// the run writes, the ID and name are filled in and the new row is marked for review.
+// The row is recorded in the journal so that undo removes it. It returns the new row number.
+func (w *Writer) appendStudentRow(file *excelize.File, studentData *models.StudentData) (int, error) {
+	sheet := w.config.MasterWorksheetName
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read master sheet rows: %w", err)
+	}
+
+	templateRow := w.config.TemplateRow
+	if templateRow == 0 {
+		templateRow = lastStudentRow(rows)
+	}
+	if templateRow < 1 || templateRow > len(rows) {
+		return 0, fmt.Errorf("no template row available to copy for new student %s", studentData.StudentID)
+	}
+	newRow := len(rows) + 1
+	if w.journal != nil {
+		w.journal.RecordAppendedRow(journal.AppendedRow{
+			Sheet:      sheet,
+			Row:        newRow,
+			StudentID:  studentData.StudentID,
+			SourceFile: studentData.FilePath,
+		})
+	}
+
+	// Copy styles and formulas across every column in use
+	width := len(rows[templateRow-1])
+	if len(rows[0]) > width {
+		width = len(rows[0])
+	}
+	for _, column := range append([]string{masterIDColumn, w.nameColumn()}, w.config.MasterColumns...) {
+		if index, err := excelize.ColumnNameToNumber(column); err == nil && index > width {
+			width = index
+		}
+	}
+
+	// Formulas in the mark columns are not copied, since the target guard refuses to overwrite them
+	markColumns := make(map[string]bool, len(w.config.MasterColumns))
+	for _, column := range w.config.MasterColumns {
+		markColumns[strings.ToUpper(column)] = true
+	}
+
+	for col := 1; col <= width; col++ {
+		from, _ := excelize.CoordinatesToCellName(col, templateRow)
+		to, _ := excelize.CoordinatesToCellName(col, newRow)
+
+		styleID, err := file.GetCellStyle(sheet, from)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read style of template cell %s: %w", from, err)
+		}
+		if styleID != 0 {
+			if err := file.SetCellStyle(sheet, to, to, styleID); err != nil {
+				return 0, fmt.Errorf("failed to copy style to cell %s: %w", to, err)
+			}
+		}
+
+		if column, _ := excelize.ColumnNumberToName(col); markColumns[column] {
+			continue
+		}
+		formula, err := file.GetCellFormula(sheet, from)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read formula of template cell %s: %w", from, err)
+		}
+		if formula != "" {
+			if err := file.SetCellFormula(sheet, to, shiftFormulaRow(formula, templateRow, newRow)); err != nil {
+				return 0, fmt.Errorf("failed to copy formula to cell %s: %w", to, err)
+			}
+		}
+	}
+
+	if height, err := file.GetRowHeight(sheet, templateRow); err == nil {
+		file.SetRowHeight(sheet, newRow, height)
+	}
+
+	// Fill in the identity columns through the journal so undo clears them
+	if err := w.setText(file, studentData, masterIDColumn, newRow, studentData.StudentID); err != nil {
+		return 0, fmt.Errorf("failed to write student ID: %w", err)
+	}
+	reviewCells := []string{fmt.Sprintf("%s%d", masterIDColumn, newRow)}
+	if studentData.StudentName != "" {
+		if err := w.setText(file, studentData, w.nameColumn(), newRow, studentData.StudentName); err != nil {
+			return 0, fmt.Errorf("failed to write student name: %w", err)
+		}
+		reviewCells = append(reviewCells, fmt.Sprintf("%s%d", w.nameColumn(), newRow))
+	}
+
+	// Mark the row for review
+	review := newFillStyles(file, reviewColor)
+	for _, cell := range reviewCells {
+		if err := review.apply(sheet, cell); err != nil {
+			return 0, err
+		}
+	}
+	text := fmt.Sprintf("New student added from %s - please review", filepath.Base(studentData.FilePath))
+	if w.runID != "" {
+		text += fmt.Sprintf(" (run %s)", w.runID)
+	}
+	if err := file.AddComment(sheet, excelize.Comment{Cell: reviewCells[0], Author: commentAuthor, Text: text}); err != nil {
+		return 0, fmt.Errorf("failed to mark new row for review: %w", err)
+	}
+
+	return newRow, nil
+}
+
+// lastStudentRow returns the 1-based number of the last row holding a valid student ID, or 0
+func lastStudentRow(rows [][]string) int {
+	for i := len(rows) - 1; i >= 0; i-- {
+		student := &models.StudentData{StudentID: cellAt(rows[i], masterIDColumnIndex)}
+		if student.IsValidStudentID() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// shiftFormulaRow moves the relative row references of a formula copied from row from to
+// row to by the same offset, the way Excel adjusts a formula when a row is copied: I5+J4
+// copied from row 5 to row 9 becomes I9+J8. Absolute rows ($5) and text in quotes are left
+// alone, and references moved above the first row become #REF! as in Excel.
+func shiftFormulaRow(formula string, from, to int) string {
+	var b strings.Builder
+	last := 0
+	offset := to - from
+
+	for _, loc := range cellRefPattern.FindAllStringIndex(formula, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && isNameChar(formula[start-1]) {
+			continue // Part of a longer name
+		}
+		if end < len(formula) && (isNameChar(formula[end]) || formula[end] == '(') {
+			continue // Function name such as LOG10(
+		}
+		if strings.Count(formula[:start], `"`)%2 == 1 {
+			continue // Inside a text literal
+		}
+
+		ref := formula[start:end]
+		digits := strings.IndexAny(ref, "0123456789")
+		if ref[digits-1] == '$' {
+			continue
+		}
+		row, err := strconv.Atoi(ref[digits:])
+		if err != nil {
+			continue
+		}
+
+		b.WriteString(formula[last:start])
+		if row+offset < 1 {
+			b.WriteString("#REF!")
+		} else {
+			b.WriteString(ref[:digits])
+			b.WriteString(strconv.Itoa(row + offset))
+		}
+		last = end
+	}
+
+	b.WriteString(formula[last:])
+	return b.String()
+}
+
+// isNameChar reports whether c can be part of a name or number adjacent to a reference
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
@@ -0,0 +1,185 @@
+package excel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestShiftFormulaRow tests adjusting copied formulas to the new row
+func TestShiftFormulaRow(t *testing.T) {
+	tests := []struct {
+		formula string
+		want    string
+	}{
+		{"SUM(I3:K3)", "SUM(I7:K7)"},
+		{"I3*$L$1", "I7*$L$1"},
+		{"I$3+J3", "I$3+J7"},
+		{"LOG10(I3)", "LOG10(I7)"},
+		{"'001'!I3+I2", "'001'!I7+I6"},
+		{"I3+J2", "I7+J6"},
+		{"SUM(C2:C3)", "SUM(C6:C7)"},
+		{"SUM(C$2:C3)", "SUM(C$2:C7)"},
+		{`IF(I3>0,"Q1",I3)`, `IF(I7>0,"Q1",I7)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			if got := shiftFormulaRow(tt.formula, 3, 7); got != tt.want {
+				t.Errorf("shiftFormulaRow(%q) = %q, want %q", tt.formula, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBatchUpdateAppendsUnmatched tests adding missing students from the template row
+func TestBatchUpdateAppendsUnmatched(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellFormula("001", "L3", "SUM(I3:K3)")
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	f.SetCellStyle("001", "L3", "L3", bold)
+	f.Save()
+	f.Close()
+
+	cfg := &config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7", "C8"},
+		MasterColumns:       []string{"I", "J", "K"},
+		AppendUnmatched:     true,
+	}
+	writer := NewWriter(cfg)
+
+	students := []*models.StudentData{{
+		StudentID:   "STU099",
+		StudentName: "Late Student",
+		FilePath:    "stu099.xlsx",
+		Marks:       map[string]float64{"C6": 50, "C7": 60, "C8": 70},
+	}}
+	summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if summary.StudentsAdded != 1 || summary.StudentsNotFound != 0 || summary.StudentsUpdated != 1 {
+		t.Errorf("summary added/not found/updated = %d/%d/%d, want 1/0/1",
+			summary.StudentsAdded, summary.StudentsNotFound, summary.StudentsUpdated)
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	defer f.Close()
+
+	for cell, want := range map[string]string{"A4": "Late Student", "B4": "STU099", "I4": "50", "K4": "70"} {
+		if got, _ := f.GetCellValue("001", cell); got != want {
+			t.Errorf("new row %s = %q, want %q", cell, got, want)
+		}
+	}
+	if formula, _ := f.GetCellFormula("001", "L4"); formula != "SUM(I4:K4)" {
+		t.Errorf("new row formula = %q, want SUM(I4:K4)", formula)
+	}
+	if styleID, _ := f.GetCellStyle("001", "L4"); styleID != bold {
+		t.Errorf("new row style = %d, want template style %d", styleID, bold)
+	}
+
+	styleID, _ := f.GetCellStyle("001", "B4")
+	style, _ := f.GetStyle(styleID)
+	if !hasSolidFill(style, reviewColor) {
+		t.Errorf("new row ID cell fill = %+v, want review color", style.Fill)
+	}
+	comments, _ := f.GetComments("001")
+	if len(comments) != 1 || comments[0].Cell != "B4" || !strings.Contains(comments[0].Text, "review") {
+		t.Errorf("new row comments = %+v, want review note on B4", comments)
+	}
+
+	// Without the option unmatched students are only counted
+	cfg.AppendUnmatched = false
+	students[0].StudentID = "STU100"
+	summary, err = writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if summary.StudentsAdded != 0 || summary.StudentsNotFound != 1 {
+		t.Errorf("summary added/not found = %d/%d, want 0/1", summary.StudentsAdded, summary.StudentsNotFound)
+	}
+}
+
+// TestUndoRemovesAppendedRow tests that undo removes an added row unless it changed since the run
+func TestUndoRemovesAppendedRow(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellFormula("001", "J3", "I3*2")
+	f.SetCellFormula("001", "L3", "SUM(I3:K3)")
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7", "C8"},
+		MasterColumns:       []string{"I", "J", "K"},
+		AppendUnmatched:     true,
+	})
+	student := &models.StudentData{
+		StudentID:   "STU099",
+		StudentName: "Late Student",
+		FilePath:    "stu099.xlsx",
+		Marks:       map[string]float64{"C6": 50, "C7": 60, "C8": 70},
+	}
+
+	// A formula in a template mark column is not copied, so it does not block the first write
+	summary, err := writer.BatchUpdateMasterSheet(testFile, []*models.StudentData{student})
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if summary.StudentsUpdated != 1 || len(summary.Errors) != 0 {
+		t.Fatalf("summary updated = %d, errors = %v, want the new student updated", summary.StudentsUpdated, summary.Errors)
+	}
+	j := writer.LastJournal()
+	if len(j.AppendedRows) != 1 || j.AppendedRows[0].Row != 4 {
+		t.Fatalf("journal appended rows = %+v, want row 4", j.AppendedRows)
+	}
+
+	result, err := writer.UndoJournal(testFile, j)
+	if err != nil {
+		t.Fatalf("UndoJournal() error = %v", err)
+	}
+	if result.RemovedRows != 1 || len(result.KeptRows) != 0 {
+		t.Errorf("undo removed/kept rows = %d/%v, want 1/none", result.RemovedRows, result.KeptRows)
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	rows, _ := f.GetRows("001")
+	formula, _ := f.GetCellFormula("001", "L4")
+	comments, _ := f.GetComments("001")
+	f.Close()
+	if len(rows) != 3 || formula != "" || len(comments) != 0 {
+		t.Errorf("after undo rows = %d, L4 formula = %q, comments = %+v, want the added row gone", len(rows), formula, comments)
+	}
+
+	// A row edited by hand after the run is kept
+	if _, err := writer.BatchUpdateMasterSheet(testFile, []*models.StudentData{student}); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	f, _ = excelize.OpenFile(testFile)
+	f.SetCellValue("001", "I4", 55)
+	f.Save()
+	f.Close()
+
+	result, err = writer.UndoJournal(testFile, writer.LastJournal())
+	if err != nil {
+		t.Fatalf("UndoJournal() error = %v", err)
+	}
+	if result.RemovedRows != 0 || len(result.KeptRows) != 1 || len(result.Conflicts) != 1 {
+		t.Errorf("undo removed/kept rows = %d/%v with %d conflict(s), want the edited row kept",
+			result.RemovedRows, result.KeptRows, len(result.Conflicts))
+	}
+	f, _ = excelize.OpenFile(testFile)
+	defer f.Close()
+	if got, _ := f.GetCellValue("001", "I4"); got != "55" {
+		t.Errorf("kept row I4 = %q, want the hand edit 55", got)
+	}
+}
@@ -0,0 +1,90 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the audit worksheet recording the provenance of every mark.
+package excel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/fileutil"
+	"mark-master-sheet/internal/journal"
+)
+
+// DefaultAuditSheetName is the audit worksheet name used when none is configured
+const DefaultAuditSheetName = "Audit"
+
+// auditHeaders are the column titles of the audit worksheet
+var auditHeaders = []interface{}{
+	"Run ID", "Timestamp", "Operator", "Student ID", "Column", "Old Value", "New Value", "Source File",
+}
+
+// auditSheetName returns the configured audit worksheet name or the default
+func (w *Writer) auditSheetName() string {
+	if w.config.AuditSheetName != "" {
+		return w.config.AuditSheetName
+	}
+	return DefaultAuditSheetName
+}
+
+// appendAudit appends one audit row per journal entry to the audit worksheet,
+// creating the sheet if it is missing, and (re-)protects it against edits
+func (w *Writer) appendAudit(file *excelize.File, runID string, timestamp time.Time, entries []journal.Entry) error {
+	if !w.config.AuditSheet || len(entries) == 0 {
+		return nil
+	}
+
+	sheet := w.auditSheetName()
+	index, err := file.GetSheetIndex(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to look up audit worksheet: %w", err)
+	}
+
+	nextRow := 1
+	if index == -1 {
+		if _, err := file.NewSheet(sheet); err != nil {
+			return fmt.Errorf("failed to create audit worksheet: %w", err)
+		}
+	} else {
+		rows, err := file.GetRows(sheet)
+		if err != nil {
+			return fmt.Errorf("failed to read audit worksheet: %w", err)
+		}
+		nextRow = len(rows) + 1
+	}
+
+	if nextRow == 1 {
+		if err := file.SetSheetRow(sheet, "A1", &auditHeaders); err != nil {
+			return fmt.Errorf("failed to write audit header: %w", err)
+		}
+		nextRow = 2
+	}
+
+	operator := fileutil.CurrentUser()
+	stamp := timestamp.Format("2006-01-02 15:04:05")
+	for _, entry := range entries {
+		row := []interface{}{
+			runID, stamp, operator, entry.StudentID, entry.Column,
+			entry.OldValue, entry.NewValue, entry.SourceFile,
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, nextRow)
+		if err := file.SetSheetRow(sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write audit row: %w", err)
+		}
+		nextRow++
+	}
+
+	protection := &excelize.SheetProtectionOptions{
+		SelectLockedCells:   true,
+		SelectUnlockedCells: true,
+	}
+	if w.config.AuditSheetPassword != "" {
+		protection.AlgorithmName = "SHA-512"
+		protection.Password = w.config.AuditSheetPassword
+	}
+	if err := file.ProtectSheet(sheet, protection); err != nil {
+		return fmt.Errorf("failed to protect audit worksheet: %w", err)
+	}
+
+	return nil
+}
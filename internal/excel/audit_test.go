@@ -0,0 +1,70 @@
+package excel
+
+import (
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestBatchUpdateAuditSheet tests that every write is appended to a protected audit sheet
+func TestBatchUpdateAuditSheet(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7"},
+		MasterColumns:       []string{"I", "J"},
+		AuditSheet:          true,
+		AuditSheetPassword:  "secret",
+	})
+
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		FilePath:  "stu001.xlsx",
+		Marks:     map[string]float64{"C6": 85.5, "C7": 92},
+	}}
+
+	writer.SetRunID("run-1")
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	// A second run appends below the first
+	students[0].Marks["C6"] = 90
+	writer.SetRunID("run-2")
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	f, err := excelize.OpenFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(DefaultAuditSheetName)
+	if err != nil {
+		t.Fatalf("audit worksheet missing: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("audit worksheet has %d rows, want header + 4", len(rows))
+	}
+	if rows[0][0] != "Run ID" || rows[0][7] != "Source File" {
+		t.Errorf("audit header = %v", rows[0])
+	}
+
+	last := rows[3]
+	if last[0] != "run-2" || last[3] != "STU001" || last[4] != "I" || last[5] != "85.50" || last[6] != "90.00" || last[7] != "stu001.xlsx" {
+		t.Errorf("audit row = %v, want run-2 change of I from 85.5 to 90", last)
+	}
+	if last[2] == "" {
+		t.Error("audit row should record the operator")
+	}
+
+	if err := f.UnprotectSheet(DefaultAuditSheetName, "wrong"); err != excelize.ErrUnprotectSheetPassword {
+		t.Errorf("audit worksheet should be password protected, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the derived total and grade columns of the master sheet.
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// writeDerived populates the configured total and grade columns for a student row,
+// either as Excel formulas or as values computed from the row's current marks
+func (w *Writer) writeDerived(file *excelize.File, studentData *models.StudentData, row int) error {
+	derived := w.config.Derived
+	if !derived.Enabled() {
+		return nil
+	}
+
+	if derived.UsesFormulas() {
+		totalFormula := w.totalFormula(row)
+		if err := w.writeCell(file, studentData, strings.ToUpper(derived.TotalColumn), row, cellContent{formula: totalFormula}, func(sheet, cell string) error {
+			return file.SetCellFormula(sheet, cell, totalFormula)
+		}); err != nil {
+			return fmt.Errorf("failed to write total formula: %w", err)
+		}
+
+		if derived.GradeColumn != "" {
+			totalCell := fmt.Sprintf("%s%d", strings.ToUpper(derived.TotalColumn), row)
+			gradeFormula := w.gradeFormula(totalCell)
+			if err := w.writeCell(file, studentData, strings.ToUpper(derived.GradeColumn), row, cellContent{formula: gradeFormula}, func(sheet, cell string) error {
+				return file.SetCellFormula(sheet, cell, gradeFormula)
+			}); err != nil {
+				return fmt.Errorf("failed to write grade formula: %w", err)
+			}
+		}
+		return nil
+	}
+
+	total, err := w.computeTotal(file, row)
+	if err != nil {
+		return err
+	}
+	if err := w.setMark(file, studentData, strings.ToUpper(derived.TotalColumn), row, total); err != nil {
+		return fmt.Errorf("failed to write total: %w", err)
+	}
+
+	if derived.GradeColumn != "" {
+		if err := w.setText(file, studentData, strings.ToUpper(derived.GradeColumn), row, derived.Grade(total)); err != nil {
+			return fmt.Errorf("failed to write grade: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// totalFormula builds the weighted total formula for a row, e.g. I5*0.2+J5*0.3
+func (w *Writer) totalFormula(row int) string {
+	var terms []string
+	for _, column := range w.config.MasterColumns {
+		weight, ok := w.config.Derived.WeightFor(column)
+		if !ok {
+			continue
+		}
+		term := fmt.Sprintf("%s%d", strings.ToUpper(column), row)
+		if weight != 1 {
+			term += "*" + strconv.FormatFloat(weight, 'f', -1, 64)
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return "0"
+	}
+	return w.roundFormula(strings.Join(terms, "+"), w.config.Derived.TotalColumn)
+}
+
+// roundFormula wraps a formula in the Excel rounding matching the column's rounding rule, the
+// same rule computed totals are rounded with. Excel's ROUNDDOWN and ROUNDUP work toward and away
+// from zero, so floor and ceil switch between them on the sign of the value.
+func (w *Writer) roundFormula(formula, column string) string {
+	rule := w.config.Rounding.For(column)
+	places := rule.Places()
+	switch rule.Mode {
+	case config.RoundHalfUp:
+		return fmt.Sprintf("ROUND(%s,%d)", formula, places)
+	case config.RoundFloor:
+		return fmt.Sprintf("IF((%s)<0,ROUNDUP(%s,%d),ROUNDDOWN(%s,%d))", formula, formula, places, formula, places)
+	case config.RoundCeil:
+		return fmt.Sprintf("IF((%s)<0,ROUNDDOWN(%s,%d),ROUNDUP(%s,%d))", formula, formula, places, formula, places)
+	default:
+		// Excel has no banker's rounding: a tie, within a tolerance for binary approximations
+		// such as 2.675, goes to the even neighbour of the magnitude, anything else to ROUND
+		scaled := fmt.Sprintf("ABS(%s)*10^%d", formula, places)
+		return fmt.Sprintf("IF(ABS(MOD(%s,1)-0.5)<1E-9,ROUND(%s/2,0)*2/10^%d*SIGN(%s),ROUND(%s,%d))",
+			scaled, scaled, places, formula, formula, places)
+	}
+}
+
+// gradeFormula builds a nested IF formula mapping the total cell to a grade band
+func (w *Writer) gradeFormula(totalCell string) string {
+	formula := strconv.Quote(w.config.Derived.DefaultGrade)
+	bands := w.config.Derived.SortedBands()
+	for i := len(bands) - 1; i >= 0; i-- {
+		formula = fmt.Sprintf("IF(%s>=%s,%s,%s)", totalCell,
+			strconv.FormatFloat(bands[i].Min, 'f', -1, 64), strconv.Quote(bands[i].Grade), formula)
+	}
+	return formula
+}
+
+// computeTotal calculates the weighted total of a row from the marks currently in the master sheet.
+// Blank mark cells count as zero, matching the formula behaviour.
+func (w *Writer) computeTotal(file *excelize.File, row int) (float64, error) {
+	total := 0.0
+	for _, column := range w.config.MasterColumns {
+		weight, ok := w.config.Derived.WeightFor(column)
+		if !ok {
+			continue
+		}
+
+		cell := fmt.Sprintf("%s%d", strings.ToUpper(column), row)
+		value, err := file.GetCellValue(w.config.MasterWorksheetName, cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return 0, fmt.Errorf("failed to read mark in cell %s: %w", cell, err)
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		mark, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cell %s holds non-numeric mark %q", cell, value)
+		}
+		total += mark * weight
+	}
+	return total, nil
+}
@@ -0,0 +1,208 @@
+package excel
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestDerivedFormulas tests building total and grade formulas
+func TestDerivedFormulas(t *testing.T) {
+	writer := NewWriter(&config.ExcelConfig{
+		MasterColumns: []string{"I", "J", "K"},
+		Derived: config.DerivedConfig{
+			TotalColumn:  "W",
+			Weights:      map[string]float64{"i": 0.2, "J": 0.3, "K": 1},
+			GradeColumn:  "X",
+			GradeBands:   []config.GradeBand{{Grade: "B", Min: 60}, {Grade: "A", Min: 70}},
+			DefaultGrade: "F",
+		},
+		Rounding: config.RoundingConfig{Mode: config.RoundHalfUp},
+	})
+
+	if got, want := writer.totalFormula(5), "ROUND(I5*0.2+J5*0.3+K5,2)"; got != want {
+		t.Errorf("totalFormula() = %q, want %q", got, want)
+	}
+	if got, want := writer.gradeFormula("W5"), `IF(W5>=70,"A",IF(W5>=60,"B","F"))`; got != want {
+		t.Errorf("gradeFormula() = %q, want %q", got, want)
+	}
+}
+
+// TestBatchUpdateWritesDerivedColumns tests the total and grade columns in both modes
+func TestBatchUpdateWritesDerivedColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		wantTotal   string
+		wantGrade   string
+		wantFormula string
+	}{
+		{name: "formula", mode: config.DerivedModeFormula, wantFormula: "ROUND(I2*0.5+J2*0.5,2)"},
+		{name: "value", mode: config.DerivedModeValue, wantTotal: "75", wantGrade: "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := createTestMasterFileForWriter(t)
+			cfg := &config.ExcelConfig{
+				MasterWorksheetName: "001",
+				MarkCells:           []string{"C6", "C7"},
+				MasterColumns:       []string{"I", "J"},
+				Derived: config.DerivedConfig{
+					Mode:         tt.mode,
+					TotalColumn:  "W",
+					Weights:      map[string]float64{"I": 0.5, "J": 0.5},
+					GradeColumn:  "X",
+					GradeBands:   []config.GradeBand{{Grade: "A", Min: 70}},
+					DefaultGrade: "F",
+				},
+				Rounding: config.RoundingConfig{Mode: config.RoundHalfUp},
+			}
+			writer := NewWriter(cfg)
+
+			students := []*models.StudentData{{
+				StudentID: "STU001",
+				FilePath:  "stu001.xlsx",
+				Marks:     map[string]float64{"C6": 70, "C7": 80},
+			}}
+			summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+			if err != nil {
+				t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+			}
+			if len(summary.Errors) > 0 {
+				t.Fatalf("BatchUpdateMasterSheet() errors = %v", summary.Errors)
+			}
+
+			f, err := excelize.OpenFile(testFile)
+			if err != nil {
+				t.Fatalf("Failed to open master: %v", err)
+			}
+			defer f.Close()
+
+			if tt.wantFormula != "" {
+				if formula, _ := f.GetCellFormula("001", "W2"); formula != tt.wantFormula {
+					t.Errorf("total formula = %q, want %q", formula, tt.wantFormula)
+				}
+				if formula, _ := f.GetCellFormula("001", "X2"); formula != `IF(W2>=70,"A","F")` {
+					t.Errorf("grade formula = %q", formula)
+				}
+			} else {
+				if total, _ := f.GetCellValue("001", "W2"); total != tt.wantTotal {
+					t.Errorf("total = %q, want %q", total, tt.wantTotal)
+				}
+				if grade, _ := f.GetCellValue("001", "X2"); grade != tt.wantGrade {
+					t.Errorf("grade = %q, want %q", grade, tt.wantGrade)
+				}
+			}
+
+			if got := len(writer.LastJournal().Entries); got != 4 {
+				t.Errorf("journal entries = %d, want 4 (2 marks + total + grade)", got)
+			}
+		})
+	}
+}
+
+// TestBatchUpdateAppliesRounding tests per-column precision for marks and totals
+func TestBatchUpdateAppliesRounding(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	one, zero := 1, 0
+	cfg := &config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7"},
+		MasterColumns:       []string{"I", "J"},
+		Derived:             config.DerivedConfig{Mode: config.DerivedModeValue, TotalColumn: "W"},
+		Rounding: config.RoundingConfig{
+			Mode:      config.RoundHalfUp,
+			Precision: &one,
+			Columns:   map[string]config.RoundingRule{"W": {Precision: &zero}},
+		},
+	}
+	writer := NewWriter(cfg)
+
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		FilePath:  "stu001.xlsx",
+		Marks:     map[string]float64{"C6": 36.25, "C7": 36.2},
+	}}
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	f, err := excelize.OpenFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	defer f.Close()
+
+	// 36.3 + 36.2 = 72.5 rounds half-up to 73
+	for cell, want := range map[string]string{"I2": "36.3", "J2": "36.2", "W2": "73"} {
+		if got, _ := f.GetCellValue("001", cell, excelize.Options{RawCellValue: true}); got != want {
+			t.Errorf("%s = %q, want %q", cell, got, want)
+		}
+	}
+}
+
+// TestRoundFormula tests that the Excel rounding of totals gives the same result as the value
+// rounding for each mode, including ties, binary approximations and negative values
+func TestRoundFormula(t *testing.T) {
+	values := []float64{72.5, 73.5, -72.5, -73.5, 2.675, -2.675, 2.665, 85.555, 0.125, -0.125, 10.004, -10.004, 36.3 + 36.2, 0}
+	modes := []string{config.RoundHalfUp, config.RoundHalfEven, config.RoundFloor, config.RoundCeil}
+
+	for _, mode := range modes {
+		for _, places := range []int{0, 2} {
+			precision := places
+			cfg := &config.ExcelConfig{
+				MasterColumns: []string{"I"},
+				Derived:       config.DerivedConfig{TotalColumn: "W"},
+				Rounding:      config.RoundingConfig{Columns: map[string]config.RoundingRule{"W": {Mode: mode, Precision: &precision}}},
+			}
+			rule := cfg.Rounding.For("W")
+
+			f := excelize.NewFile()
+			for i, value := range values {
+				row := i + 1
+				f.SetCellValue("Sheet1", fmt.Sprintf("I%d", row), value)
+				f.SetCellFormula("Sheet1", fmt.Sprintf("W%d", row), NewWriter(cfg).totalFormula(row))
+			}
+
+			for i, value := range values {
+				got, err := f.CalcCellValue("Sheet1", fmt.Sprintf("W%d", i+1))
+				if err != nil {
+					t.Fatalf("%s/%d CalcCellValue(%v) error = %v", mode, places, value, err)
+				}
+				gotValue, err := strconv.ParseFloat(got, 64)
+				if err != nil {
+					t.Fatalf("%s/%d formula of %v = %q, not a number", mode, places, value, got)
+				}
+				want := rule.Apply(value)
+				if (mode == config.RoundFloor || mode == config.RoundCeil) && want == value {
+					// excelize's ROUNDDOWN and ROUNDUP divide by a binary 0.01 and move values
+					// already at the precision, such as 72.5, by one step; Excel does not
+					continue
+				}
+				if math.Abs(gotValue-want) > 1e-9 {
+					t.Errorf("%s/%d formula rounds %v to %v, value rounding gives %v", mode, places, value, gotValue, want)
+				}
+			}
+			f.Close()
+		}
+	}
+}
+
+// TestRoundFormulaDefault tests that totals are rounded by the default rule when the column has none
+func TestRoundFormulaDefault(t *testing.T) {
+	writer := NewWriter(&config.ExcelConfig{
+		MasterColumns: []string{"I"},
+		Derived:       config.DerivedConfig{TotalColumn: "W"},
+		Rounding:      config.RoundingConfig{Mode: config.RoundHalfUp},
+	})
+	if got, want := writer.totalFormula(2), "ROUND(I2,2)"; got != want {
+		t.Errorf("totalFormula() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,370 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the comparison of two master sheet versions by student ID.
+package excel
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/pkg/models"
+)
+
+// Master sheet layout and diff workbook styling
+const (
+	masterIDColumn      = "B" // Student IDs live in column B of the master sheet
+	masterIDColumnIndex = 1
+	commentAuthor       = "Mark Master Sheet Consolidator"
+	diffChangedColor    = "FFF2CC"
+	diffAddedColor      = "E2EFDA"
+	diffSummarySheet    = "Diff Summary"
+)
+
+// DiffStudent identifies a student row present in only one of the compared workbooks
+type DiffStudent struct {
+	StudentID string `json:"student_id"`
+	Row       int    `json:"row"`
+}
+
+// CellDiff describes a cell whose value differs between the compared workbooks
+type CellDiff struct {
+	StudentID string `json:"student_id"`
+	Column    string `json:"column"`
+	Header    string `json:"header,omitempty"`
+	OldRow    int    `json:"old_row"`
+	NewRow    int    `json:"new_row"`
+	OldValue  string `json:"old_value"`
+	NewValue  string `json:"new_value"`
+}
+
+// MasterDiff is the result of comparing two master workbooks by student ID
+type MasterDiff struct {
+	OldPath   string        `json:"old_path"`
+	NewPath   string        `json:"new_path"`
+	Worksheet string        `json:"worksheet"`
+	Added     []DiffStudent `json:"added,omitempty"`
+	Removed   []DiffStudent `json:"removed,omitempty"`
+	Changed   []CellDiff    `json:"changed,omitempty"`
+	Warnings  []string      `json:"warnings,omitempty"`
+}
+
+// masterSnapshot holds the student rows of one master workbook keyed by student ID
+type masterSnapshot struct {
+	rows    map[string][]string
+	rowNums map[string]int
+	order   []string
+	headers []string
+}
+
+// studentID returns the student ID as written in the workbook for a lookup key
+func (s *masterSnapshot) studentID(key string) string {
+	return cellAt(s.rows[key], masterIDColumnIndex)
+}
+
+// HasChanges reports whether the compared workbooks differ
+func (d *MasterDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// DiffMasterSheets compares the master worksheet of two workbooks by student ID
+func (r *Reader) DiffMasterSheets(oldPath, newPath string) (*MasterDiff, error) {
+	diff := &MasterDiff{
+		OldPath:   oldPath,
+		NewPath:   newPath,
+		Worksheet: r.config.MasterWorksheetName,
+	}
+
+	oldSnap, err := r.loadMasterSnapshot(oldPath, diff)
+	if err != nil {
+		return nil, err
+	}
+	newSnap, err := r.loadMasterSnapshot(newPath, diff)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range oldSnap.order {
+		if _, ok := newSnap.rows[id]; !ok {
+			diff.Removed = append(diff.Removed, DiffStudent{StudentID: oldSnap.studentID(id), Row: oldSnap.rowNums[id]})
+		}
+	}
+
+	for _, id := range newSnap.order {
+		oldRow, ok := oldSnap.rows[id]
+		if !ok {
+			diff.Added = append(diff.Added, DiffStudent{StudentID: newSnap.studentID(id), Row: newSnap.rowNums[id]})
+			continue
+		}
+
+		newRow := newSnap.rows[id]
+		width := len(oldRow)
+		if len(newRow) > width {
+			width = len(newRow)
+		}
+
+		for col := 0; col < width; col++ {
+			if col == masterIDColumnIndex {
+				continue // IDs are matched case-insensitively
+			}
+			oldValue, newValue := cellAt(oldRow, col), cellAt(newRow, col)
+			column, _ := excelize.ColumnNumberToName(col + 1)
+			if r.roundedEqual(column, oldValue, newValue) {
+				continue
+			}
+
+			header := cellAt(newSnap.headers, col)
+			if header == "" {
+				header = cellAt(oldSnap.headers, col)
+			}
+
+			diff.Changed = append(diff.Changed, CellDiff{
+				StudentID: newSnap.studentID(id),
+				Column:    column,
+				Header:    header,
+				OldRow:    oldSnap.rowNums[id],
+				NewRow:    newSnap.rowNums[id],
+				OldValue:  oldValue,
+				NewValue:  newValue,
+			})
+		}
+	}
+
+	return diff, nil
+}
+
+// loadMasterSnapshot reads the student rows of a master workbook
+func (r *Reader) loadMasterSnapshot(path string, diff *MasterDiff) (*masterSnapshot, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	rows, err := file.GetRows(r.config.MasterWorksheetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worksheet '%s' in %s: %w", r.config.MasterWorksheetName, path, err)
+	}
+
+	snap := &masterSnapshot{
+		rows:    make(map[string][]string),
+		rowNums: make(map[string]int),
+	}
+
+	for rowIndex, row := range rows {
+		id := cellAt(row, masterIDColumnIndex)
+		student := &models.StudentData{StudentID: id}
+		if !student.IsValidStudentID() {
+			// Remember the last non-student row before the data as the header row
+			if len(snap.order) == 0 && id != "" {
+				snap.headers = row
+			}
+			continue
+		}
+
+		key := strings.ToLower(id)
+		if _, exists := snap.rows[key]; exists {
+			diff.Warnings = append(diff.Warnings,
+				fmt.Sprintf("Duplicate student ID %s in %s (row %d ignored)", id, path, rowIndex+1))
+			continue
+		}
+
+		snap.rows[key] = row
+		snap.rowNums[key] = rowIndex + 1
+		snap.order = append(snap.order, key)
+	}
+
+	return snap, nil
+}
+
+// WriteText writes a human readable diff report
+func (d *MasterDiff) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Comparing worksheet '%s'\n", d.Worksheet)
+	fmt.Fprintf(w, "  old: %s\n", d.OldPath)
+	fmt.Fprintf(w, "  new: %s\n\n", d.NewPath)
+
+	if !d.HasChanges() {
+		fmt.Fprintln(w, "No differences found")
+	}
+
+	if len(d.Added) > 0 {
+		fmt.Fprintf(w, "Added students (%d):\n", len(d.Added))
+		for _, s := range d.Added {
+			fmt.Fprintf(w, "  + %s (row %d)\n", s.StudentID, s.Row)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(w, "Removed students (%d):\n", len(d.Removed))
+		for _, s := range d.Removed {
+			fmt.Fprintf(w, "  - %s (row %d)\n", s.StudentID, s.Row)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(d.Changed) > 0 {
+		fmt.Fprintf(w, "Changed cells (%d):\n", len(d.Changed))
+		for _, c := range d.Changed {
+			label := c.Column
+			if c.Header != "" {
+				label = fmt.Sprintf("%s (%s)", c.Column, c.Header)
+			}
+			fmt.Fprintf(w, "  ~ %s %s: %q -> %q\n", c.StudentID, label, c.OldValue, c.NewValue)
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, warning := range d.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+
+	_, err := fmt.Fprintf(w, "Summary: %d added, %d removed, %d changed cells\n",
+		len(d.Added), len(d.Removed), len(d.Changed))
+	return err
+}
+
+// WriteCSV writes the diff as CSV with one line per difference
+func (d *MasterDiff) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	records := [][]string{{"change", "student_id", "column", "header", "old_row", "new_row", "old_value", "new_value"}}
+	for _, s := range d.Added {
+		records = append(records, []string{"added", s.StudentID, "", "", "", strconv.Itoa(s.Row), "", ""})
+	}
+	for _, s := range d.Removed {
+		records = append(records, []string{"removed", s.StudentID, "", "", strconv.Itoa(s.Row), "", "", ""})
+	}
+	for _, c := range d.Changed {
+		records = append(records, []string{"changed", c.StudentID, c.Column, c.Header,
+			strconv.Itoa(c.OldRow), strconv.Itoa(c.NewRow), c.OldValue, c.NewValue})
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write diff CSV: %w", err)
+	}
+	return nil
+}
+
+// SaveWorkbook saves a copy of the new workbook with changed cells and added
+// students highlighted, plus a summary sheet listing every difference
+func (d *MasterDiff) SaveWorkbook(outputPath string) error {
+	file, err := excelize.OpenFile(d.NewPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", d.NewPath, err)
+	}
+	defer file.Close()
+
+	changed := newFillStyles(file, diffChangedColor)
+	for _, c := range d.Changed {
+		cell := fmt.Sprintf("%s%d", c.Column, c.NewRow)
+		if err := changed.apply(d.Worksheet, cell); err != nil {
+			return err
+		}
+		if err := file.AddComment(d.Worksheet, excelize.Comment{
+			Cell:   cell,
+			Author: commentAuthor,
+			Text:   fmt.Sprintf("Previous value: %s", c.OldValue),
+		}); err != nil {
+			return fmt.Errorf("failed to annotate cell %s: %w", cell, err)
+		}
+	}
+
+	added := newFillStyles(file, diffAddedColor)
+	for _, s := range d.Added {
+		cell := fmt.Sprintf("%s%d", masterIDColumn, s.Row)
+		if err := added.apply(d.Worksheet, cell); err != nil {
+			return err
+		}
+	}
+
+	if err := d.writeSummarySheet(file); err != nil {
+		return err
+	}
+
+	if err := file.SaveAs(outputPath); err != nil {
+		return fmt.Errorf("failed to save diff workbook: %w", err)
+	}
+	return nil
+}
+
+// writeSummarySheet adds a sheet listing all differences to the diff workbook
+func (d *MasterDiff) writeSummarySheet(file *excelize.File) error {
+	if index, _ := file.GetSheetIndex(diffSummarySheet); index != -1 {
+		if err := file.DeleteSheet(diffSummarySheet); err != nil {
+			return fmt.Errorf("failed to replace diff summary sheet: %w", err)
+		}
+	}
+	if _, err := file.NewSheet(diffSummarySheet); err != nil {
+		return fmt.Errorf("failed to create diff summary sheet: %w", err)
+	}
+
+	rows := [][]interface{}{
+		{"Change", "Student ID", "Column", "Header", "Old Row", "New Row", "Old Value", "New Value"},
+	}
+	for _, s := range d.Added {
+		rows = append(rows, []interface{}{"added", s.StudentID, "", "", "", s.Row, "", ""})
+	}
+	for _, s := range d.Removed {
+		rows = append(rows, []interface{}{"removed", s.StudentID, "", "", s.Row, "", "", ""})
+	}
+	for _, c := range d.Changed {
+		rows = append(rows, []interface{}{"changed", c.StudentID, c.Column, c.Header, c.OldRow, c.NewRow, c.OldValue, c.NewValue})
+	}
+
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := file.SetSheetRow(diffSummarySheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write diff summary: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// cellAt returns the trimmed value at the given column index, or "" if the row is shorter
+func cellAt(row []string, index int) string {
+	if index < len(row) {
+		return strings.TrimSpace(row[index])
+	}
+	return ""
+}
+
+// roundedEqual compares two cell values of a column. Numbers in mark and total
+// columns that round to the same value under the column's rounding rule are equal.
+func (r *Reader) roundedEqual(column, a, b string) bool {
+	if valuesEqual(a, b) {
+		return true
+	}
+	if !r.isRoundedColumn(column) {
+		return false
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && r.config.Rounding.For(column).Equal(fa, fb)
+}
+
+// isRoundedColumn reports whether the tool writes rounded numbers to a master column
+func (r *Reader) isRoundedColumn(column string) bool {
+	if strings.EqualFold(column, r.config.Derived.TotalColumn) {
+		return true
+	}
+	for _, mapped := range r.config.MasterColumns {
+		if strings.EqualFold(mapped, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesEqual compares two cell values, treating numerically equal values as equal
+func valuesEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
@@ -0,0 +1,187 @@
+package excel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+)
+
+// TestDiffMasterSheets tests comparing two master sheets by student ID
+func TestDiffMasterSheets(t *testing.T) {
+	tempDir := t.TempDir()
+	oldPath := createDiffTestMaster(t, tempDir, "old.xlsx", [][]interface{}{
+		{"John Doe", "STU001", 80, 70},
+		{"Jane Smith", "STU002", 90, 60},
+		{"Bob Brown", "STU003", 50, 55},
+	})
+	newPath := createDiffTestMaster(t, tempDir, "new.xlsx", [][]interface{}{
+		{"Jane Smith", "stu002", 90, 65},
+		{"John Doe", "STU001", 80.0, 70},
+		{"Amy Adams", "STU004", 75, 75},
+	})
+
+	reader := NewReader(&config.ExcelConfig{MasterWorksheetName: "001"})
+	diff, err := reader.DiffMasterSheets(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("DiffMasterSheets() error = %v", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].StudentID != "STU004" || diff.Added[0].Row != 4 {
+		t.Errorf("DiffMasterSheets() added = %+v, want STU004 at row 4", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].StudentID != "STU003" {
+		t.Errorf("DiffMasterSheets() removed = %+v, want STU003", diff.Removed)
+	}
+
+	// Row reordering and numerically equal values must not count as changes
+	if len(diff.Changed) != 1 {
+		t.Fatalf("DiffMasterSheets() changed = %+v, want 1 change", diff.Changed)
+	}
+	change := diff.Changed[0]
+	if change.StudentID != "stu002" || change.Column != "D" || change.Header != "Mark 2" {
+		t.Errorf("DiffMasterSheets() change = %+v, want stu002 column D (Mark 2)", change)
+	}
+	if change.OldValue != "60" || change.NewValue != "65" || change.OldRow != 3 || change.NewRow != 2 {
+		t.Errorf("DiffMasterSheets() change values = %+v", change)
+	}
+}
+
+// TestDiffMasterSheetsIdentical tests that identical sheets have no differences
+func TestDiffMasterSheetsIdentical(t *testing.T) {
+	tempDir := t.TempDir()
+	rows := [][]interface{}{{"John Doe", "STU001", 80, 70}}
+	oldPath := createDiffTestMaster(t, tempDir, "old.xlsx", rows)
+	newPath := createDiffTestMaster(t, tempDir, "new.xlsx", rows)
+
+	reader := NewReader(&config.ExcelConfig{MasterWorksheetName: "001"})
+	diff, err := reader.DiffMasterSheets(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("DiffMasterSheets() error = %v", err)
+	}
+	if diff.HasChanges() {
+		t.Errorf("DiffMasterSheets() reported changes for identical sheets: %+v", diff)
+	}
+
+	var out bytes.Buffer
+	if err := diff.WriteText(&out); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "No differences found") {
+		t.Errorf("WriteText() output = %q", out.String())
+	}
+
+	if _, err := reader.DiffMasterSheets(oldPath, filepath.Join(tempDir, "missing.xlsx")); err == nil {
+		t.Error("DiffMasterSheets() expected error for missing file")
+	}
+}
+
+// TestDiffMasterSheetsRounding tests ignoring differences below a mark column's precision
+func TestDiffMasterSheetsRounding(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := createDiffTestMaster(t, dir, "old.xlsx", [][]interface{}{{"John Doe", "STU001", 85.5, 70.12}})
+	newPath := createDiffTestMaster(t, dir, "new.xlsx", [][]interface{}{{"John Doe", "STU001", 85.54, 70.14}})
+
+	one := 1
+	reader := NewReader(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MasterColumns:       []string{"C"},
+		Rounding:            config.RoundingConfig{Mode: config.RoundHalfUp, Precision: &one},
+	})
+	diff, err := reader.DiffMasterSheets(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("DiffMasterSheets() error = %v", err)
+	}
+
+	// Column C rounds to 85.5 on both sides; the unmapped column D is compared exactly
+	if len(diff.Changed) != 1 || diff.Changed[0].Column != "D" {
+		t.Errorf("Changed = %+v, want only column D", diff.Changed)
+	}
+}
+
+// TestMasterDiffOutputs tests the CSV and workbook outputs
+func TestMasterDiffOutputs(t *testing.T) {
+	tempDir := t.TempDir()
+	oldPath := createDiffTestMaster(t, tempDir, "old.xlsx", [][]interface{}{
+		{"John Doe", "STU001", 80, 70},
+		{"Bob Brown", "STU003", 50, 55},
+	})
+	newPath := createDiffTestMaster(t, tempDir, "new.xlsx", [][]interface{}{
+		{"John Doe", "STU001", 85, 70},
+		{"Amy Adams", "STU004", 75, 75},
+	})
+
+	reader := NewReader(&config.ExcelConfig{MasterWorksheetName: "001"})
+	diff, err := reader.DiffMasterSheets(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("DiffMasterSheets() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := diff.WriteCSV(&out); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("WriteCSV() produced invalid CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("WriteCSV() wrote %d records, want 4", len(records))
+	}
+	if records[3][0] != "changed" || records[3][6] != "80" || records[3][7] != "85" {
+		t.Errorf("WriteCSV() change record = %v", records[3])
+	}
+
+	outputPath := filepath.Join(tempDir, "diff.xlsx")
+	if err := diff.SaveWorkbook(outputPath); err != nil {
+		t.Fatalf("SaveWorkbook() error = %v", err)
+	}
+
+	f, err := excelize.OpenFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open diff workbook: %v", err)
+	}
+	defer f.Close()
+
+	styleID, _ := f.GetCellStyle("001", "C2")
+	style, _ := f.GetStyle(styleID)
+	if len(style.Fill.Color) == 0 || style.Fill.Color[0] != diffChangedColor {
+		t.Errorf("SaveWorkbook() changed cell fill = %+v", style.Fill)
+	}
+
+	comments, _ := f.GetComments("001")
+	if len(comments) != 1 || !strings.Contains(comments[0].Text, "80") {
+		t.Errorf("SaveWorkbook() comments = %+v, want previous value note", comments)
+	}
+
+	rows, err := f.GetRows(diffSummarySheet)
+	if err != nil {
+		t.Fatalf("SaveWorkbook() missing summary sheet: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Errorf("SaveWorkbook() summary has %d rows, want 4", len(rows))
+	}
+}
+
+func createDiffTestMaster(t *testing.T, dir, name string, students [][]interface{}) string {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	f.SetSheetName("Sheet1", "001")
+	f.SetSheetRow("001", "A1", &[]interface{}{"Name", "Student ID", "Mark 1", "Mark 2"})
+	for i, student := range students {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow("001", cell, &student)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("Failed to create test master file: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,170 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the checks that keep the writer away from formula, locked and merged master cells.
+package excel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// BlockedCell describes a master sheet cell the writer refuses to overwrite
+type BlockedCell struct {
+	Cell   string `json:"cell"`
+	Reason string `json:"reason"`
+}
+
+func (b BlockedCell) String() string {
+	return fmt.Sprintf("%s (%s)", b.Cell, b.Reason)
+}
+
+// cellRange is a merged area in zero-based bounds
+type cellRange struct {
+	ref                                string
+	startCol, startRow, endCol, endRow int
+}
+
+// contains reports whether a cell lies inside the range
+func (r cellRange) contains(col, row int) bool {
+	return col >= r.startCol && col <= r.endCol && row >= r.startRow && row <= r.endRow
+}
+
+// targetGuard inspects master cells before they are overwritten
+type targetGuard struct {
+	file      *excelize.File
+	sheet     string
+	protected bool
+	merged    []cellRange
+}
+
+// newTargetGuard prepares the formula, protection and merge checks for the master worksheet
+func (w *Writer) newTargetGuard(file *excelize.File) (*targetGuard, error) {
+	guard := &targetGuard{file: file, sheet: w.config.MasterWorksheetName}
+
+	protected, err := isSheetProtected(file, guard.sheet)
+	if err != nil {
+		return nil, err
+	}
+	guard.protected = protected
+
+	mergeCells, err := file.GetMergeCells(guard.sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merged cells: %w", err)
+	}
+	for _, merge := range mergeCells {
+		startCol, startRow, err := excelize.CellNameToCoordinates(merge.GetStartAxis())
+		if err != nil {
+			continue
+		}
+		endCol, endRow, err := excelize.CellNameToCoordinates(merge.GetEndAxis())
+		if err != nil {
+			continue
+		}
+		guard.merged = append(guard.merged, cellRange{
+			ref:      merge.GetStartAxis() + ":" + merge.GetEndAxis(),
+			startCol: startCol, startRow: startRow, endCol: endCol, endRow: endRow,
+		})
+	}
+
+	return guard, nil
+}
+
+// check returns the reasons a cell must not be overwritten, if any
+func (g *targetGuard) check(cell string) ([]string, error) {
+	var reasons []string
+
+	formula, err := g.file.GetCellFormula(g.sheet, cell)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read formula of cell %s: %w", cell, err)
+	}
+	if formula != "" {
+		reasons = append(reasons, "contains formula ="+formula)
+	}
+
+	if g.protected {
+		locked, err := isCellLocked(g.file, g.sheet, cell)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			reasons = append(reasons, "locked on protected sheet")
+		}
+	}
+
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		return nil, err
+	}
+	for _, merged := range g.merged {
+		if merged.contains(col, row) {
+			reasons = append(reasons, "part of merged range "+merged.ref)
+			break
+		}
+	}
+
+	return reasons, nil
+}
+
+// blockedCells checks the target cells of a student row, returning those that must not be overwritten
+func (g *targetGuard) blockedCells(columns []string, row int) ([]BlockedCell, error) {
+	var blocked []BlockedCell
+	for _, column := range columns {
+		cell := fmt.Sprintf("%s%d", column, row)
+		reasons, err := g.check(cell)
+		if err != nil {
+			return nil, err
+		}
+		if len(reasons) > 0 {
+			blocked = append(blocked, BlockedCell{Cell: cell, Reason: strings.Join(reasons, ", ")})
+		}
+	}
+	return blocked, nil
+}
+
+// isSheetProtected reports whether a worksheet of a workbook saved on disk has sheet protection
+// enabled. excelize has no getter, so it attempts an unprotect with a random password:
+// unprotected sheets report ErrUnprotectSheet, protected ones a password mismatch. A password
+// that happens to match a legacy hash removes the protection, so the attempt is made on a
+// separate copy of the workbook opened from its file, never on the workbook being written.
+func isSheetProtected(file *excelize.File, sheet string) (bool, error) {
+	if file.Path == "" {
+		return false, fmt.Errorf("failed to check protection of worksheet '%s': workbook not opened from a file", sheet)
+	}
+	copied, err := excelize.OpenFile(file.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check protection of worksheet '%s': %w", sheet, err)
+	}
+	defer copied.Close()
+
+	probe := make([]byte, 16)
+	if _, err := rand.Read(probe); err != nil {
+		return false, fmt.Errorf("failed to check sheet protection: %w", err)
+	}
+
+	err = copied.UnprotectSheet(sheet, hex.EncodeToString(probe))
+	switch {
+	case errors.Is(err, excelize.ErrUnprotectSheet):
+		return false, nil
+	case errors.Is(err, excelize.ErrUnprotectSheetPassword), err == nil:
+		return true, nil
+	default:
+		return false, fmt.Errorf("failed to check protection of worksheet '%s': %w", sheet, err)
+	}
+}
+
+// isCellLocked reports whether a cell is locked; cells are locked unless their style unlocks them
+func isCellLocked(file *excelize.File, sheet, cell string) (bool, error) {
+	styleID, err := file.GetCellStyle(sheet, cell)
+	if err != nil {
+		return false, fmt.Errorf("failed to read style of cell %s: %w", cell, err)
+	}
+	style, err := file.GetStyle(styleID)
+	if err != nil {
+		return false, fmt.Errorf("failed to read style of cell %s: %w", cell, err)
+	}
+	return style.Protection == nil || style.Protection.Locked, nil
+}
@@ -0,0 +1,123 @@
+package excel
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestBatchUpdateBlocksProtectedCells tests refusing to overwrite formula, locked and merged cells
+func TestBatchUpdateBlocksProtectedCells(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *excelize.File)
+		wantReason string
+	}{
+		{
+			name:       "formula",
+			setup:      func(f *excelize.File) { f.SetCellFormula("001", "J2", "I2*2") },
+			wantReason: "J2 (contains formula =I2*2)",
+		},
+		{
+			name:       "locked on protected sheet",
+			setup:      func(f *excelize.File) { f.ProtectSheet("001", &excelize.SheetProtectionOptions{Password: "secret"}) },
+			wantReason: "I2 (locked on protected sheet)",
+		},
+		{
+			name:       "merged",
+			setup:      func(f *excelize.File) { f.MergeCell("001", "I2", "I3") },
+			wantReason: "I2 (part of merged range I2:I3)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, override := range []bool{false, true} {
+				testFile := createTestMasterFileForWriter(t)
+				f, _ := excelize.OpenFile(testFile)
+				tt.setup(f)
+				f.Save()
+				f.Close()
+
+				writer := NewWriter(&config.ExcelConfig{
+					MasterWorksheetName: "001",
+					MarkCells:           []string{"C6", "C7"},
+					MasterColumns:       []string{"I", "J"},
+					OverwriteProtected:  override,
+				})
+				students := []*models.StudentData{{
+					StudentID: "STU001",
+					FilePath:  "stu001.xlsx",
+					Marks:     map[string]float64{"C6": 70, "C7": 80},
+				}}
+
+				summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+				if err != nil {
+					t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+				}
+
+				messages := summary.Errors
+				wantUpdated := 0
+				if override {
+					messages = summary.Warnings
+					wantUpdated = 1
+				}
+				if len(messages) != 1 || !strings.Contains(messages[0], tt.wantReason) {
+					t.Errorf("override=%v messages = %v, want one containing %q", override, messages, tt.wantReason)
+				}
+				if summary.StudentsUpdated != wantUpdated {
+					t.Errorf("override=%v StudentsUpdated = %d, want %d", override, summary.StudentsUpdated, wantUpdated)
+				}
+
+				f, _ = excelize.OpenFile(testFile)
+				got, _ := f.GetCellValue("001", "I2")
+				f.Close()
+				if (got == "70") != override {
+					t.Errorf("override=%v I2 = %q", override, got)
+				}
+			}
+		})
+	}
+}
+
+// TestIsSheetProtected tests detecting protection without removing it
+func TestIsSheetProtected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "protection.xlsx")
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	if protected, err := isSheetProtected(f, "Sheet1"); err != nil || protected {
+		t.Errorf("isSheetProtected() = %v, %v for unprotected sheet", protected, err)
+	}
+
+	f.ProtectSheet("Sheet1", &excelize.SheetProtectionOptions{Password: "secret"})
+	if err := f.Save(); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if protected, err := isSheetProtected(f, "Sheet1"); err != nil || !protected {
+			t.Errorf("isSheetProtected() = %v, %v for protected sheet", protected, err)
+		}
+	}
+	if err := f.UnprotectSheet("Sheet1", "wrong"); !errors.Is(err, excelize.ErrUnprotectSheetPassword) {
+		t.Errorf("UnprotectSheet() after the check = %v, want the workbook still protected", err)
+	}
+
+	unlocked, _ := f.NewStyle(&excelize.Style{Protection: &excelize.Protection{Locked: false}})
+	f.SetCellStyle("Sheet1", "A1", "A1", unlocked)
+	if locked, _ := isCellLocked(f, "Sheet1", "A1"); locked {
+		t.Error("isCellLocked() = true for unlocked style")
+	}
+	if locked, _ := isCellLocked(f, "Sheet1", "B1"); !locked {
+		t.Error("isCellLocked() = false for default style")
+	}
+}
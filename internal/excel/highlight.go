@@ -0,0 +1,190 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains highlighting and annotation of cells updated by a run.
+package excel
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/journal"
+)
+
+// DefaultHighlightColor is the fill used for updated cells when no color is configured
+const DefaultHighlightColor = "FFF2CC"
+
+// updateMarker highlights and annotates the cells written during one batch update
+type updateMarker struct {
+	file      *excelize.File
+	sheet     string
+	runID     string
+	fill      *fillStyles
+	comments  map[string]excelize.Comment
+	timestamp time.Time
+}
+
+// highlightColor returns the configured highlight color or the default
+func (w *Writer) highlightColor() string {
+	if w.config.HighlightColor != "" {
+		return w.config.HighlightColor
+	}
+	return DefaultHighlightColor
+}
+
+// newUpdateMarker prepares marking of updated cells, or returns nil when
+// neither highlighting nor comments are enabled
+func (w *Writer) newUpdateMarker(file *excelize.File) (*updateMarker, error) {
+	if !w.config.HighlightUpdates && !w.config.CommentUpdates {
+		return nil, nil
+	}
+
+	marker := &updateMarker{
+		file:      file,
+		sheet:     w.config.MasterWorksheetName,
+		runID:     w.runID,
+		timestamp: time.Now(),
+	}
+
+	if w.config.HighlightUpdates {
+		marker.fill = newFillStyles(file, w.highlightColor())
+	}
+
+	if w.config.CommentUpdates {
+		existing, err := file.GetComments(marker.sheet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read comments: %w", err)
+		}
+		marker.comments = make(map[string]excelize.Comment, len(existing))
+		for _, comment := range existing {
+			marker.comments[comment.Cell] = comment
+		}
+	}
+
+	return marker, nil
+}
+
+// mark highlights a written cell and attaches a comment naming its source file.
+// Comments written by someone else are left in place rather than replaced.
+func (m *updateMarker) mark(cell, sourceFile string) error {
+	if m.fill != nil {
+		if err := m.fill.apply(m.sheet, cell); err != nil {
+			return err
+		}
+	}
+
+	if m.comments == nil {
+		return nil
+	}
+
+	if existing, ok := m.comments[cell]; ok {
+		if existing.Author != commentAuthor {
+			return nil
+		}
+		if err := m.file.DeleteComment(m.sheet, cell); err != nil {
+			return fmt.Errorf("failed to replace comment on cell %s: %w", cell, err)
+		}
+	}
+
+	text := fmt.Sprintf("Updated from %s\n%s", filepath.Base(sourceFile), m.timestamp.Format("2006-01-02 15:04:05"))
+	if m.runID != "" {
+		text += fmt.Sprintf(" (run %s)", m.runID)
+	}
+
+	comment := excelize.Comment{Cell: cell, Author: commentAuthor, Text: text}
+	if err := m.file.AddComment(m.sheet, comment); err != nil {
+		return fmt.Errorf("failed to add comment to cell %s: %w", cell, err)
+	}
+	m.comments[cell] = comment
+
+	return nil
+}
+
+// recordHighlight notes in the journal that the cell just written was highlighted, so that
+// ClearHighlights can later tell the tool's fills from the fills users applied themselves
+func (w *Writer) recordHighlight(m *updateMarker, cell string) {
+	if w.journal == nil || m.fill == nil || len(w.journal.Entries) == 0 {
+		return
+	}
+	if last := &w.journal.Entries[len(w.journal.Entries)-1]; last.Cell == cell {
+		last.Highlight = m.fill.color
+	}
+}
+
+// ClearHighlights removes the highlight fill from the cells the journaled runs highlighted in
+// the master sheet, and the tool's comments from the master worksheet, keeping every other
+// part of the cell styles. A cell is only cleared while it still has the fill its run applied,
+// so fills users applied themselves are kept. It returns the number of cells cleared and
+// comments removed.
+func (w *Writer) ClearHighlights(masterSheetPath string, journals []*journal.Journal) (int, int, error) {
+	masterFile, err := excelize.OpenFile(masterSheetPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open master sheet: %w", err)
+	}
+	defer masterFile.Close()
+
+	clear := newFillStyles(masterFile, "")
+	seen := make(map[string]bool)
+	cleared := 0
+
+	for _, j := range journals {
+		if !journal.SameFile(j.MasterSheetPath, masterSheetPath) {
+			continue
+		}
+		for _, entry := range j.Entries {
+			key := entry.Sheet + "!" + entry.Cell
+			if entry.Highlight == "" || seen[key] {
+				continue
+			}
+
+			styleID, err := masterFile.GetCellStyle(entry.Sheet, entry.Cell)
+			if err != nil || styleID == 0 {
+				continue
+			}
+			style, err := masterFile.GetStyle(styleID)
+			if err != nil || !hasSolidFill(style, entry.Highlight) {
+				continue
+			}
+
+			if err := clear.apply(entry.Sheet, entry.Cell); err != nil {
+				return cleared, 0, err
+			}
+			seen[key] = true
+			cleared++
+		}
+	}
+
+	sheet := w.config.MasterWorksheetName
+	comments, err := masterFile.GetComments(sheet)
+	if err != nil {
+		return cleared, 0, fmt.Errorf("failed to read comments: %w", err)
+	}
+	removed := 0
+	for _, comment := range comments {
+		if comment.Author != commentAuthor {
+			continue
+		}
+		if err := masterFile.DeleteComment(sheet, comment.Cell); err != nil {
+			return cleared, removed, fmt.Errorf("failed to remove comment from cell %s: %w", comment.Cell, err)
+		}
+		removed++
+	}
+
+	if cleared > 0 || removed > 0 {
+		if err := masterFile.Save(); err != nil {
+			return cleared, removed, fmt.Errorf("failed to save master sheet: %w", err)
+		}
+	}
+
+	return cleared, removed, nil
+}
+
+// hasSolidFill reports whether a style has a solid fill of the given color
+func hasSolidFill(style *excelize.Style, color string) bool {
+	if style.Fill.Type != "pattern" || style.Fill.Pattern != 1 || len(style.Fill.Color) == 0 {
+		return false
+	}
+	return strings.HasSuffix(strings.ToUpper(style.Fill.Color[0]), color)
+}
@@ -0,0 +1,119 @@
+package excel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestBatchUpdateHighlights tests highlighting and annotating updated cells
+func TestBatchUpdateHighlights(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	// Give I2 a number format that must survive the highlight
+	f, _ := excelize.OpenFile(testFile)
+	numFmt, _ := f.NewStyle(&excelize.Style{NumFmt: 2})
+	f.SetCellStyle("001", "I2", "I2", numFmt)
+	f.AddComment("001", excelize.Comment{Cell: "J2", Author: "Module Leader", Text: "Check late penalty"})
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7"},
+		MasterColumns:       []string{"I", "J"},
+		HighlightUpdates:    true,
+		HighlightColor:      "#ffeb9c",
+		CommentUpdates:      true,
+	})
+	writer.SetRunID("run-1")
+
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		FilePath:  "/submissions/stu001.xlsx",
+		Marks:     map[string]float64{"C6": 85.5, "C7": 92},
+	}}
+	summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if len(summary.Warnings) != 0 {
+		t.Errorf("BatchUpdateMasterSheet() warnings = %v", summary.Warnings)
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	styleID, _ := f.GetCellStyle("001", "I2")
+	style, _ := f.GetStyle(styleID)
+	if !hasSolidFill(style, "FFEB9C") {
+		t.Errorf("updated cell fill = %+v, want FFEB9C", style.Fill)
+	}
+	if style.NumFmt != 2 {
+		t.Errorf("updated cell number format = %d, want 2", style.NumFmt)
+	}
+
+	comments, _ := f.GetComments("001")
+	byCell := make(map[string]excelize.Comment)
+	for _, c := range comments {
+		byCell[c.Cell] = c
+	}
+	if c := byCell["I2"]; c.Author != commentAuthor || !strings.Contains(c.Text, "stu001.xlsx") || !strings.Contains(c.Text, "run-1") {
+		t.Errorf("I2 comment = %+v, want source file and run ID", c)
+	}
+	if c := byCell["J2"]; c.Author != "Module Leader" {
+		t.Errorf("J2 comment = %+v, existing comment should be kept", c)
+	}
+	f.Close()
+
+	first := writer.LastJournal()
+
+	// A later run highlights in another color, and the module leader fills a cell the same color
+	writer.config.HighlightColor = "C6EFCE"
+	writer.config.CommentUpdates = false
+	students = []*models.StudentData{{
+		StudentID: "STU002",
+		FilePath:  "/submissions/stu002.xlsx",
+		Marks:     map[string]float64{"C6": 70},
+	}}
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	f, _ = excelize.OpenFile(testFile)
+	userFill, _ := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFEB9C"}}})
+	f.SetCellStyle("001", "K2", "K2", userFill)
+	f.Save()
+	f.Close()
+
+	cleared, removed, err := writer.ClearHighlights(testFile, []*journal.Journal{writer.LastJournal(), first})
+	if err != nil {
+		t.Fatalf("ClearHighlights() error = %v", err)
+	}
+	if cleared != 3 || removed != 1 {
+		t.Errorf("ClearHighlights() = %d cells, %d comments, want 3 and 1", cleared, removed)
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	defer f.Close()
+	styleID, _ = f.GetCellStyle("001", "I2")
+	style, _ = f.GetStyle(styleID)
+	if hasSolidFill(style, "FFEB9C") || style.NumFmt != 2 {
+		t.Errorf("cleared cell style = %+v, want no fill and number format 2", style)
+	}
+	styleID, _ = f.GetCellStyle("001", "I3")
+	style, _ = f.GetStyle(styleID)
+	if hasSolidFill(style, "C6EFCE") {
+		t.Errorf("cell highlighted in the earlier color = %+v, want no fill", style.Fill)
+	}
+	styleID, _ = f.GetCellStyle("001", "K2")
+	style, _ = f.GetStyle(styleID)
+	if !hasSolidFill(style, "FFEB9C") {
+		t.Errorf("cell filled by the module leader = %+v, want its fill kept", style.Fill)
+	}
+	if comments, _ := f.GetComments("001"); len(comments) != 1 {
+		t.Errorf("ClearHighlights() left %d comments, want only the module leader's", len(comments))
+	}
+}
@@ -3,6 +3,7 @@
 package excel
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/archive"
 	"mark-master-sheet/internal/config"
 	"mark-master-sheet/pkg/models"
 )
@@ -26,6 +28,19 @@ func NewReader(cfg *config.ExcelConfig) *Reader {
 	}
 }
 
+// openWorkbook opens an Excel file from disk or, for archive-qualified paths, from memory
+func openWorkbook(filePath string) (*excelize.File, error) {
+	if !archive.IsArchivePath(filePath) {
+		return excelize.OpenFile(filePath)
+	}
+
+	data, err := archive.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return excelize.OpenReader(bytes.NewReader(data))
+}
+
 // ReadStudentData reads student data from an Excel file
 func (r *Reader) ReadStudentData(filePath string) (*models.StudentData, error) {
 	// Check file extension
@@ -39,7 +54,7 @@ func (r *Reader) ReadStudentData(filePath string) (*models.StudentData, error) {
 	}
 
 	// Open the Excel file
-	file, err := excelize.OpenFile(filePath)
+	file, err := openWorkbook(filePath)
 	if err != nil {
 		return nil, &models.FileProcessingError{
 			FilePath: filePath,
@@ -112,6 +127,20 @@ func (r *Reader) ReadStudentData(filePath string) (*models.StudentData, error) {
 		}
 	}
 
+	// Read the student name if configured
+	if r.config.StudentNameCell != "" {
+		name, err := file.GetCellValue(r.config.StudentWorksheetName, r.config.StudentNameCell)
+		if err != nil {
+			return nil, &models.FileProcessingError{
+				FilePath: filePath,
+				Stage:    "student_name_reading",
+				Message:  fmt.Sprintf("failed to read student name from cell %s", r.config.StudentNameCell),
+				Cause:    err,
+			}
+		}
+		studentData.StudentName = strings.TrimSpace(name)
+	}
+
 	// Read marks from specified cells
 	for _, cell := range r.config.MarkCells {
 		markValue, err := file.GetCellValue(r.config.StudentWorksheetName, cell)
@@ -0,0 +1,267 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the recalculation of formulas that depend on cells written by a run.
+package excel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/journal"
+)
+
+// formulaRefPattern matches cell, range and whole-column references with an optional sheet prefix,
+// such as I5, $I$5, I5:K5, I:I, Sheet2!I5 or 'Year 1'!I5:K5
+var formulaRefPattern = regexp.MustCompile(
+	`(?:('(?:[^']|'')+'|[A-Za-z0-9_.]+)!)?(\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3})`)
+
+// stringLiteralPattern matches string literals inside formulas
+var stringLiteralPattern = regexp.MustCompile(`"(?:[^"]|"")*"`)
+
+// RecalcResult reports the outcome of recalculating dependent formulas
+type RecalcResult struct {
+	Recalculated int      `json:"recalculated"`
+	Errors       []string `json:"errors,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+// formulaRef is a referenced area in one-based bounds on a sheet
+type formulaRef struct {
+	sheet                              string
+	startCol, startRow, endCol, endRow int
+}
+
+// covers reports whether the reference includes a cell
+func (r formulaRef) covers(sheet string, col, row int) bool {
+	return strings.EqualFold(r.sheet, sheet) &&
+		col >= r.startCol && col <= r.endCol && row >= r.startRow && row <= r.endRow
+}
+
+// formulaCell is a formula found in the workbook together with its references
+type formulaCell struct {
+	sheet    string
+	cell     string
+	col, row int
+	formula  string
+	refs     []formulaRef
+}
+
+// recalculateFormulas evaluates every formula that depends, directly or through other
+// formulas, on the written cells and stores the fresh results as cached values.
+// excelize can only cache numeric results of formulas, so changed text results are
+// reported as warnings and left for Excel to recalculate.
+func (w *Writer) recalculateFormulas(file *excelize.File, written []journal.Entry) (*RecalcResult, error) {
+	result := &RecalcResult{}
+	if len(written) == 0 {
+		return result, nil
+	}
+
+	formulas, err := scanFormulas(file)
+	if err != nil {
+		return result, err
+	}
+	dependents := dependentFormulas(formulas, written)
+
+	// Evaluate everything before writing, since writing a value can strip a shared formula group
+	values := make([]string, len(dependents))
+	evaluated := make([]bool, len(dependents))
+	for i, fc := range dependents {
+		value, err := file.CalcCellValue(fc.sheet, fc.cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			result.Errors = append(result.Errors,
+				fmt.Sprintf("Formula %s!%s (=%s) could not be evaluated: %v", fc.sheet, fc.cell, fc.formula, err))
+			continue
+		}
+		values[i], evaluated[i] = value, true
+	}
+
+	for i, fc := range dependents {
+		if !evaluated[i] {
+			continue
+		}
+
+		number, err := strconv.ParseFloat(values[i], 64)
+		if err != nil {
+			cached, _ := file.GetCellValue(fc.sheet, fc.cell, excelize.Options{RawCellValue: true})
+			if cached != values[i] {
+				result.Warnings = append(result.Warnings,
+					fmt.Sprintf("Formula %s!%s now evaluates to %q; the cached text %q updates when the workbook is recalculated in Excel",
+						fc.sheet, fc.cell, values[i], cached))
+			}
+			continue
+		}
+
+		if err := file.SetCellFloat(fc.sheet, fc.cell, number, -1, 64); err != nil {
+			return result, fmt.Errorf("failed to cache value of %s!%s: %w", fc.sheet, fc.cell, err)
+		}
+		if err := file.SetCellFormula(fc.sheet, fc.cell, fc.formula); err != nil {
+			return result, fmt.Errorf("failed to restore formula of %s!%s: %w", fc.sheet, fc.cell, err)
+		}
+		result.Recalculated++
+	}
+
+	// Put back members of shared formula groups whose master was rewritten
+	for _, fc := range formulas {
+		current, err := file.GetCellFormula(fc.sheet, fc.cell)
+		if err != nil {
+			return result, fmt.Errorf("failed to read formula of %s!%s: %w", fc.sheet, fc.cell, err)
+		}
+		if current == "" {
+			if err := file.SetCellFormula(fc.sheet, fc.cell, fc.formula); err != nil {
+				return result, fmt.Errorf("failed to restore formula of %s!%s: %w", fc.sheet, fc.cell, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// scanFormulas collects every formula cell in the workbook
+func scanFormulas(file *excelize.File) ([]formulaCell, error) {
+	var formulas []formulaCell
+
+	for _, sheet := range file.GetSheetList() {
+		rows, err := file.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read worksheet '%s': %w", sheet, err)
+		}
+
+		// Formula cells with an empty cached value may lie beyond the last value of a row
+		width, height := 0, len(rows)
+		for _, row := range rows {
+			if len(row) > width {
+				width = len(row)
+			}
+		}
+		if dimension, err := file.GetSheetDimension(sheet); err == nil {
+			parts := strings.Split(dimension, ":")
+			if col, row, err := excelize.CellNameToCoordinates(parts[len(parts)-1]); err == nil {
+				width, height = max(width, col), max(height, row)
+			}
+		}
+
+		for row := 1; row <= height; row++ {
+			for col := 1; col <= width; col++ {
+				cell, _ := excelize.CoordinatesToCellName(col, row)
+				formula, err := file.GetCellFormula(sheet, cell)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read formula of %s!%s: %w", sheet, cell, err)
+				}
+				if formula == "" {
+					continue
+				}
+				formulas = append(formulas, formulaCell{
+					sheet: sheet, cell: cell, col: col, row: row,
+					formula: formula, refs: parseFormulaRefs(formula, sheet),
+				})
+			}
+		}
+	}
+
+	return formulas, nil
+}
+
+// dependentFormulas returns the formulas that depend on the written cells, directly or transitively
+func dependentFormulas(formulas []formulaCell, written []journal.Entry) []formulaCell {
+	type position struct {
+		sheet    string
+		col, row int
+	}
+
+	var dirty []position
+	for _, entry := range written {
+		if col, row, err := excelize.CellNameToCoordinates(entry.Cell); err == nil {
+			dirty = append(dirty, position{entry.Sheet, col, row})
+		}
+	}
+
+	selected := make([]bool, len(formulas))
+	var dependents []formulaCell
+	for changed := true; changed; {
+		changed = false
+		for i, fc := range formulas {
+			if selected[i] {
+				continue
+			}
+			for _, ref := range fc.refs {
+				depends := false
+				for _, p := range dirty {
+					if ref.covers(p.sheet, p.col, p.row) {
+						depends = true
+						break
+					}
+				}
+				if depends {
+					selected[i], changed = true, true
+					dependents = append(dependents, fc)
+					dirty = append(dirty, position{fc.sheet, fc.col, fc.row})
+					break
+				}
+			}
+		}
+	}
+
+	return dependents
+}
+
+// parseFormulaRefs extracts the cell and range references of a formula on a sheet
+func parseFormulaRefs(formula, sheet string) []formulaRef {
+	// Blank out string literals so their contents are not taken for references
+	formula = stringLiteralPattern.ReplaceAllStringFunc(formula, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+
+	var refs []formulaRef
+	for _, m := range formulaRefPattern.FindAllStringSubmatchIndex(formula, -1) {
+		start, end := m[0], m[1]
+		if start > 0 && (isNameChar(formula[start-1]) || formula[start-1] == '$') {
+			continue // Part of a longer name
+		}
+		if end < len(formula) && (isNameChar(formula[end]) || formula[end] == '(' || formula[end] == '!') {
+			continue // Function or sheet name
+		}
+
+		refSheet := sheet
+		if m[2] >= 0 {
+			refSheet = strings.ReplaceAll(strings.Trim(formula[m[2]:m[3]], "'"), "''", "'")
+		}
+
+		area := strings.ReplaceAll(strings.ToUpper(formula[m[4]:m[5]]), "$", "")
+		if ref, ok := parseArea(refSheet, area); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// parseArea converts a reference such as I5, I5:K9 or I:K into bounds
+func parseArea(sheet, area string) (formulaRef, bool) {
+	parts := strings.SplitN(area, ":", 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+
+	ref := formulaRef{sheet: sheet}
+	var errStart, errEnd error
+	if strings.IndexAny(parts[0], "0123456789") < 0 {
+		ref.startCol, errStart = excelize.ColumnNameToNumber(parts[0])
+		ref.endCol, errEnd = excelize.ColumnNameToNumber(parts[1])
+		ref.startRow, ref.endRow = 1, excelize.TotalRows
+	} else {
+		ref.startCol, ref.startRow, errStart = excelize.CellNameToCoordinates(parts[0])
+		ref.endCol, ref.endRow, errEnd = excelize.CellNameToCoordinates(parts[1])
+	}
+	if errStart != nil || errEnd != nil {
+		return ref, false
+	}
+
+	if ref.startCol > ref.endCol {
+		ref.startCol, ref.endCol = ref.endCol, ref.startCol
+	}
+	if ref.startRow > ref.endRow {
+		ref.startRow, ref.endRow = ref.endRow, ref.startRow
+	}
+	return ref, true
+}
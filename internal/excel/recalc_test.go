@@ -0,0 +1,118 @@
+package excel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestParseFormulaRefs tests extracting references from formulas
+func TestParseFormulaRefs(t *testing.T) {
+	tests := []struct {
+		formula string
+		want    []formulaRef
+	}{
+		{"SUM(I5:K5)", []formulaRef{{"001", 9, 5, 11, 5}}},
+		{"$I$5*2+LOG10(J5)", []formulaRef{{"001", 9, 5, 9, 5}, {"001", 10, 5, 10, 5}}},
+		{"AVERAGE(I:I)", []formulaRef{{"001", 9, 1, 9, excelize.TotalRows}}},
+		{"'Year 1'!B2+Summary!C3", []formulaRef{{"Year 1", 2, 2, 2, 2}, {"Summary", 3, 3, 3, 3}}},
+		{`IF(W5>=70,"A1","F")`, []formulaRef{{"001", 23, 5, 23, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			got := parseFormulaRefs(tt.formula, "001")
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseFormulaRefs() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ref %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestBatchUpdateRecalculatesFormulas tests refreshing cached values of dependent formulas
+func TestBatchUpdateRecalculatesFormulas(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	// A shared total formula over both student rows, a chained average and an unrelated formula
+	ref := "L2:L3"
+	sharedType := excelize.STCellFormulaTypeShared
+	f.SetCellFormula("001", "L2", "SUM(I2:K2)", excelize.FormulaOpts{Type: &sharedType, Ref: &ref})
+	f.SetCellFormula("001", "M2", "L2/3")
+	f.SetCellFormula("001", "N2", "1/0")
+	f.SetCellFormula("001", "O2", "I3/J3")
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7", "C8"},
+		MasterColumns:       []string{"I", "J", "K"},
+		RecalculateFormulas: true,
+	})
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		FilePath:  "stu001.xlsx",
+		Marks:     map[string]float64{"C6": 30, "C7": 30, "C8": 30},
+	}}
+
+	summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if summary.FormulasRecalculated != 2 {
+		t.Errorf("FormulasRecalculated = %d, want 2", summary.FormulasRecalculated)
+	}
+	if len(summary.Errors) != 0 {
+		t.Errorf("Errors = %v, want none for formulas independent of the written cells", summary.Errors)
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	defer f.Close()
+
+	for cell, want := range map[string]string{"L2": "90", "M2": "30"} {
+		if got, _ := f.GetCellValue("001", cell, excelize.Options{RawCellValue: true}); got != want {
+			t.Errorf("cached %s = %q, want %q", cell, got, want)
+		}
+	}
+	for cell, want := range map[string]string{"L2": "SUM(I2:K2)", "L3": "SUM(I3:K3)", "M2": "L2/3"} {
+		if got, _ := f.GetCellFormula("001", cell); got != want {
+			t.Errorf("formula %s = %q, want %q", cell, got, want)
+		}
+	}
+}
+
+// TestRecalculateReportsErrors tests reporting formulas that fail to evaluate
+func TestRecalculateReportsErrors(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellFormula("001", "L2", "100/(I2-I2)")
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"I"},
+		RecalculateFormulas: true,
+	})
+	students := []*models.StudentData{{StudentID: "STU001", Marks: map[string]float64{"C6": 50}}}
+
+	summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0], "001!L2") {
+		t.Errorf("Errors = %v, want one evaluation error for L2", summary.Errors)
+	}
+}
@@ -0,0 +1,56 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains helpers for highlighting cells without losing their existing formatting.
+package excel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// fillStyles applies a solid fill to cells while keeping the rest of each cell's
+// style (number format, font, borders). Derived styles are cached per original style.
+type fillStyles struct {
+	file  *excelize.File
+	color string
+	cache map[int]int
+}
+
+// newFillStyles creates a fill style helper for the given hex color (e.g. "FFF2CC").
+// An empty color removes the fill instead.
+func newFillStyles(file *excelize.File, color string) *fillStyles {
+	return &fillStyles{
+		file:  file,
+		color: strings.TrimPrefix(strings.ToUpper(color), "#"),
+		cache: make(map[int]int),
+	}
+}
+
+// apply sets (or removes) the fill on a single cell
+func (s *fillStyles) apply(sheet, cell string) error {
+	styleID, err := s.file.GetCellStyle(sheet, cell)
+	if err != nil {
+		return fmt.Errorf("failed to read style of cell %s: %w", cell, err)
+	}
+
+	filledID, ok := s.cache[styleID]
+	if !ok {
+		style, err := s.file.GetStyle(styleID)
+		if err != nil {
+			return fmt.Errorf("failed to read style %d: %w", styleID, err)
+		}
+		style.Fill = excelize.Fill{}
+		if s.color != "" {
+			style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{s.color}}
+		}
+
+		filledID, err = s.file.NewStyle(style)
+		if err != nil {
+			return fmt.Errorf("failed to create highlight style: %w", err)
+		}
+		s.cache[styleID] = filledID
+	}
+
+	return s.file.SetCellStyle(sheet, cell, cell, filledID)
+}
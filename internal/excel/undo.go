@@ -0,0 +1,325 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains journaling of cell writes and undoing a journaled run.
+package excel
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/pkg/models"
+)
+
+// UndoConflict describes a journaled cell that was not reverted because it changed after the run
+type UndoConflict struct {
+	Entry        journal.Entry `json:"entry"`
+	CurrentValue string        `json:"current_value"`
+}
+
+// UndoResult reports the outcome of undoing a run
+type UndoResult struct {
+	RunID         string                `json:"run_id"`
+	Reverted      int                   `json:"reverted"`
+	Conflicts     []UndoConflict        `json:"conflicts,omitempty"`
+	RemovedRows   int                   `json:"removed_rows,omitempty"`
+	KeptRows      []journal.AppendedRow `json:"kept_rows,omitempty"`
+	Recalculated  int                   `json:"recalculated,omitempty"`
+	FormulaErrors []string              `json:"formula_errors,omitempty"`
+}
+
+// LastJournal returns the journal of cell writes made by the last batch update
+func (w *Writer) LastJournal() *journal.Journal {
+	return w.journal
+}
+
+// setMark writes a mark to a master sheet cell, rounded by the column's rounding rule,
+// and records the write in the journal
+func (w *Writer) setMark(file *excelize.File, studentData *models.StudentData, column string, row int, mark float64) error {
+	rule := w.config.Rounding.For(column)
+	rounded := rule.Apply(mark)
+	intended := cellContent{value: strconv.FormatFloat(rounded, 'f', -1, 64)}
+	return w.writeCell(file, studentData, column, row, intended, func(sheet, cell string) error {
+		return file.SetCellFloat(sheet, cell, rounded, rule.Places(), 64)
+	})
+}
+
+// setText writes a text value to a master sheet cell and records the write in the journal
+func (w *Writer) setText(file *excelize.File, studentData *models.StudentData, column string, row int, value string) error {
+	return w.writeCell(file, studentData, column, row, cellContent{value: value}, func(sheet, cell string) error {
+		return file.SetCellStr(sheet, cell, value)
+	})
+}
+
+// cellContent is the value or formula a write is meant to leave in a cell
+type cellContent struct {
+	value   string
+	formula string
+}
+
+// writeCell performs a write to a master sheet cell, recording the previous value, the intended
+// content and the content read back after the write in the journal
+func (w *Writer) writeCell(file *excelize.File, studentData *models.StudentData, column string, row int, intended cellContent, write func(sheet, cell string) error) error {
+	sheet := w.config.MasterWorksheetName
+	cell := fmt.Sprintf("%s%d", column, row)
+
+	oldValue, oldKind, oldFormula, err := cellSnapshot(file, sheet, cell)
+	if err != nil {
+		return err
+	}
+
+	if err := write(sheet, cell); err != nil {
+		return err
+	}
+
+	if w.journal != nil {
+		newValue, _ := file.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+		newFormula, _ := file.GetCellFormula(sheet, cell)
+		w.journal.Record(journal.Entry{
+			Sheet:      sheet,
+			Cell:       cell,
+			Row:        row,
+			Column:     column,
+			StudentID:  studentData.StudentID,
+			SourceFile: studentData.FilePath,
+			OldValue:   oldValue,
+			OldKind:    oldKind,
+			OldFormula: oldFormula,
+			NewValue:   newValue,
+			NewFormula: newFormula,
+
+			IntendedValue:   intended.value,
+			IntendedFormula: intended.formula,
+		})
+	}
+
+	return nil
+}
+
+// cellSnapshot returns the raw value, value kind and formula of a cell
+func cellSnapshot(file *excelize.File, sheet, cell string) (string, string, string, error) {
+	value, err := file.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read cell %s: %w", cell, err)
+	}
+
+	formula, err := file.GetCellFormula(sheet, cell)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read formula of cell %s: %w", cell, err)
+	}
+
+	cellType, err := file.GetCellType(sheet, cell)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read type of cell %s: %w", cell, err)
+	}
+
+	kind := journal.KindText
+	switch {
+	case value == "" && formula == "":
+		kind = journal.KindEmpty
+	case cellType == excelize.CellTypeSharedString || cellType == excelize.CellTypeInlineString ||
+		cellType == excelize.CellTypeFormula:
+		kind = journal.KindText
+	default:
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			kind = journal.KindNumber
+		}
+	}
+
+	return value, kind, formula, nil
+}
+
+// restoreCell puts a journaled cell back to its value before the run
+func restoreCell(file *excelize.File, entry journal.Entry) error {
+	var err error
+	switch entry.OldKind {
+	case journal.KindEmpty:
+		err = file.SetCellValue(entry.Sheet, entry.Cell, nil)
+	case journal.KindNumber:
+		number, parseErr := strconv.ParseFloat(entry.OldValue, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid journaled number %q for cell %s", entry.OldValue, entry.Cell)
+		}
+		err = file.SetCellFloat(entry.Sheet, entry.Cell, number, -1, 64)
+	default:
+		err = file.SetCellStr(entry.Sheet, entry.Cell, entry.OldValue)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore cell %s: %w", entry.Cell, err)
+	}
+
+	if entry.OldFormula != "" {
+		if err := file.SetCellFormula(entry.Sheet, entry.Cell, entry.OldFormula); err != nil {
+			return fmt.Errorf("failed to restore formula of cell %s: %w", entry.Cell, err)
+		}
+	}
+
+	return nil
+}
+
+// UndoJournal reverts the cells written by a journaled run. A cell is only reverted
+// while its current value still equals the value the run wrote; any other cell is
+// reported as a conflict and left untouched. Rows the run added are then removed,
+// unless they still hold a conflict or other data, or rows were added below them.
+func (w *Writer) UndoJournal(masterSheetPath string, j *journal.Journal) (*UndoResult, error) {
+	result := &UndoResult{RunID: j.RunID}
+	var reverted []journal.Entry
+
+	masterFile, err := excelize.OpenFile(masterSheetPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to open master sheet: %w", err)
+	}
+	defer masterFile.Close()
+
+	// Revert in reverse order so repeated writes to a cell unwind correctly
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+
+		current, err := masterFile.GetCellValue(entry.Sheet, entry.Cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return result, fmt.Errorf("failed to read cell %s: %w", entry.Cell, err)
+		}
+
+		// Formula cells are matched by formula, since recalculation changes their cached value
+		unchanged := valuesEqual(current, entry.NewValue)
+		if entry.NewFormula != "" {
+			formula, err := masterFile.GetCellFormula(entry.Sheet, entry.Cell)
+			if err != nil {
+				return result, fmt.Errorf("failed to read formula of cell %s: %w", entry.Cell, err)
+			}
+			unchanged = formula == entry.NewFormula
+		}
+
+		if !unchanged {
+			result.Conflicts = append(result.Conflicts, UndoConflict{Entry: entry, CurrentValue: current})
+			continue
+		}
+
+		if err := restoreCell(masterFile, entry); err != nil {
+			return result, err
+		}
+		result.Reverted++
+
+		// Audit the revert as a write from the run's value back to the old one
+		restored, _ := masterFile.GetCellValue(entry.Sheet, entry.Cell, excelize.Options{RawCellValue: true})
+		reverted = append(reverted, journal.Entry{
+			Sheet:      entry.Sheet,
+			Cell:       entry.Cell,
+			Row:        entry.Row,
+			Column:     entry.Column,
+			StudentID:  entry.StudentID,
+			SourceFile: entry.SourceFile,
+			OldValue:   current,
+			NewValue:   restored,
+		})
+	}
+
+	// Remove the rows the run added, last first, once they hold nothing but copied formulas
+	removed := make(map[int]bool)
+	for i := len(j.AppendedRows) - 1; i >= 0; i-- {
+		row := j.AppendedRows[i]
+		removable, err := appendedRowRemovable(masterFile, row, result.Conflicts)
+		if err != nil {
+			return result, err
+		}
+		if !removable {
+			result.KeptRows = append(result.KeptRows, row)
+			continue
+		}
+		if err := removeAppendedRow(masterFile, row); err != nil {
+			return result, err
+		}
+		removed[row.Row] = true
+		result.RemovedRows++
+	}
+
+	if result.Reverted > 0 {
+		if w.config.RecalculateFormulas {
+			var remaining []journal.Entry
+			for _, entry := range reverted {
+				if !removed[entry.Row] {
+					remaining = append(remaining, entry)
+				}
+			}
+			recalc, err := w.recalculateFormulas(masterFile, remaining)
+			if err != nil {
+				return result, fmt.Errorf("failed to recalculate formulas: %w", err)
+			}
+			result.Recalculated = recalc.Recalculated
+			result.FormulaErrors = append(recalc.Errors, recalc.Warnings...)
+		}
+		if err := w.appendAudit(masterFile, "undo-"+j.RunID, time.Now(), reverted); err != nil {
+			return result, err
+		}
+	}
+	if result.Reverted > 0 || result.RemovedRows > 0 {
+		if err := masterFile.Save(); err != nil {
+			return result, fmt.Errorf("failed to save master sheet: %w", err)
+		}
+	}
+
+	// A run whose every cell conflicted stays open, so it can be undone once the conflicts are resolved
+	if result.Reverted > 0 || len(result.Conflicts) == 0 {
+		now := time.Now()
+		j.UndoneAt = &now
+	}
+
+	return result, nil
+}
+
+// appendedRowRemovable reports whether a row added by a run can be removed: it has no
+// conflicting cell, no rows below it and nothing left in it but formulas
+func appendedRowRemovable(file *excelize.File, row journal.AppendedRow, conflicts []UndoConflict) (bool, error) {
+	for _, c := range conflicts {
+		if c.Entry.Sheet == row.Sheet && c.Entry.Row == row.Row {
+			return false, nil
+		}
+	}
+
+	rows, err := file.GetRows(row.Sheet)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rows of sheet %s: %w", row.Sheet, err)
+	}
+	if len(rows) > row.Row {
+		return false, nil
+	}
+	if len(rows) < row.Row {
+		return true, nil
+	}
+
+	for i, value := range rows[row.Row-1] {
+		if value == "" {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(i+1, row.Row)
+		formula, err := file.GetCellFormula(row.Sheet, cell)
+		if err != nil {
+			return false, fmt.Errorf("failed to read formula of cell %s: %w", cell, err)
+		}
+		if formula == "" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// removeAppendedRow deletes a row added by a run together with the comments on it
+func removeAppendedRow(file *excelize.File, row journal.AppendedRow) error {
+	comments, err := file.GetComments(row.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read comments of sheet %s: %w", row.Sheet, err)
+	}
+	for _, comment := range comments {
+		if _, commentRow, err := excelize.CellNameToCoordinates(comment.Cell); err == nil && commentRow == row.Row {
+			if err := file.DeleteComment(row.Sheet, comment.Cell); err != nil {
+				return fmt.Errorf("failed to remove comment on %s: %w", comment.Cell, err)
+			}
+		}
+	}
+
+	if err := file.RemoveRow(row.Sheet, row.Row); err != nil {
+		return fmt.Errorf("failed to remove row %d: %w", row.Row, err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package excel
+
+import (
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestBatchUpdateJournal tests that batch updates record every cell write
+func TestBatchUpdateJournal(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellValue("001", "I2", 50)
+	f.SetCellValue("001", "J2", "absent")
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7", "C8"},
+		MasterColumns:       []string{"I", "J", "K"},
+	})
+	writer.SetRunID("run-1")
+
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		FilePath:  "stu001.xlsx",
+		Marks:     map[string]float64{"C6": 85.5, "C7": 92, "C8": 78.5},
+	}}
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	j := writer.LastJournal()
+	if j == nil || j.RunID != "run-1" || len(j.Entries) != 3 {
+		t.Fatalf("LastJournal() = %+v, want 3 entries for run-1", j)
+	}
+
+	want := map[string][2]string{
+		"I2": {"50", journal.KindNumber},
+		"J2": {"absent", journal.KindText},
+		"K2": {"", journal.KindEmpty},
+	}
+	for _, entry := range j.Entries {
+		w := want[entry.Cell]
+		if entry.OldValue != w[0] || entry.OldKind != w[1] {
+			t.Errorf("journal entry %s old = %q (%s), want %q (%s)", entry.Cell, entry.OldValue, entry.OldKind, w[0], w[1])
+		}
+		if entry.Row != 2 || entry.StudentID != "STU001" || entry.SourceFile != "stu001.xlsx" {
+			t.Errorf("journal entry %s = %+v", entry.Cell, entry)
+		}
+	}
+}
+
+// TestUndoJournal tests reverting a run while keeping later hand edits
+func TestUndoJournal(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellValue("001", "I2", 50)
+	f.SetCellValue("001", "J2", "absent")
+	f.Save()
+	f.Close()
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7", "C8"},
+		MasterColumns:       []string{"I", "J", "K"},
+	})
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		Marks:     map[string]float64{"C6": 85.5, "C7": 92, "C8": 78.5},
+	}}
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	// Hand edit made after the run must survive the undo
+	f, _ = excelize.OpenFile(testFile)
+	f.SetCellValue("001", "K2", 99)
+	f.Save()
+	f.Close()
+
+	result, err := writer.UndoJournal(testFile, writer.LastJournal())
+	if err != nil {
+		t.Fatalf("UndoJournal() error = %v", err)
+	}
+	if result.Reverted != 2 {
+		t.Errorf("UndoJournal() reverted %d cells, want 2", result.Reverted)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Entry.Cell != "K2" || result.Conflicts[0].CurrentValue != "99" {
+		t.Errorf("UndoJournal() conflicts = %+v, want K2 with current value 99", result.Conflicts)
+	}
+	if writer.LastJournal().UndoneAt == nil {
+		t.Error("UndoJournal() should mark the journal as undone")
+	}
+
+	f, _ = excelize.OpenFile(testFile)
+	defer f.Close()
+	for cell, want := range map[string]string{"I2": "50", "J2": "absent", "K2": "99"} {
+		if got, _ := f.GetCellValue("001", cell); got != want {
+			t.Errorf("after undo %s = %q, want %q", cell, got, want)
+		}
+	}
+	if cellType, _ := f.GetCellType("001", "J2"); cellType != excelize.CellTypeSharedString && cellType != excelize.CellTypeInlineString {
+		t.Errorf("after undo J2 type = %v, want text", cellType)
+	}
+}
+
+// TestUndoJournalAllConflicts tests that a run whose every cell conflicted can be undone later
+func TestUndoJournalAllConflicts(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"I"},
+	})
+	students := []*models.StudentData{{StudentID: "STU001", Marks: map[string]float64{"C6": 85}}}
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	j := writer.LastJournal()
+
+	setI2 := func(value int) {
+		f, _ := excelize.OpenFile(testFile)
+		f.SetCellValue("001", "I2", value)
+		f.Save()
+		f.Close()
+	}
+
+	setI2(99)
+	result, err := writer.UndoJournal(testFile, j)
+	if err != nil || result.Reverted != 0 || len(result.Conflicts) != 1 {
+		t.Fatalf("UndoJournal() = %+v, %v, want one conflict", result, err)
+	}
+	if j.UndoneAt != nil {
+		t.Fatal("UndoJournal() marked the journal undone although nothing was reverted")
+	}
+
+	// Once the hand edit is reverted, the run can be undone
+	setI2(85)
+	if result, err := writer.UndoJournal(testFile, j); err != nil || result.Reverted != 1 {
+		t.Fatalf("UndoJournal() after resolving = %+v, %v, want one cell reverted", result, err)
+	}
+	if j.UndoneAt == nil {
+		t.Error("UndoJournal() should mark the journal as undone")
+	}
+}
@@ -0,0 +1,92 @@
+// Package excel provides Excel file reading and writing operations for the Mark Master Sheet Consolidator.
+// This file contains the read-back verification of a saved master sheet.
+package excel
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/journal"
+)
+
+// VerifyMasterSheet reopens a saved master sheet and compares every cell written by the run
+// against what the run meant to write, as journaled before the write was read back. Numbers
+// are compared within the column's rounding precision and formula cells by their formula.
+// It returns one message per mismatch.
+func (w *Writer) VerifyMasterSheet(masterSheetPath string, j *journal.Journal) ([]string, error) {
+	masterFile, err := excelize.OpenFile(masterSheetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen master sheet: %w", err)
+	}
+	defer masterFile.Close()
+
+	var mismatches []string
+	for _, entry := range latestEntries(j.Entries) {
+		expectedValue, expectedFormula := intendedContent(entry)
+		if expectedFormula != "" {
+			formula, err := masterFile.GetCellFormula(entry.Sheet, entry.Cell)
+			if err != nil {
+				return mismatches, fmt.Errorf("failed to read formula of %s!%s: %w", entry.Sheet, entry.Cell, err)
+			}
+			if formula != expectedFormula {
+				mismatches = append(mismatches, mismatchMessage(entry, "="+formula, "="+expectedFormula))
+			}
+			continue
+		}
+
+		value, err := masterFile.GetCellValue(entry.Sheet, entry.Cell, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return mismatches, fmt.Errorf("failed to read %s!%s: %w", entry.Sheet, entry.Cell, err)
+		}
+		if !w.writtenValueMatches(entry.Column, value, expectedValue) {
+			mismatches = append(mismatches, mismatchMessage(entry, value, expectedValue))
+		}
+	}
+
+	return mismatches, nil
+}
+
+// intendedContent returns the value or formula a journaled write meant to leave in its cell,
+// falling back to the read-back content for journals written before intents were recorded
+func intendedContent(entry journal.Entry) (value, formula string) {
+	if entry.IntendedValue == "" && entry.IntendedFormula == "" {
+		return entry.NewValue, entry.NewFormula
+	}
+	return entry.IntendedValue, entry.IntendedFormula
+}
+
+// writtenValueMatches compares a saved value with the written one, numbers within the column's precision
+func (w *Writer) writtenValueMatches(column, saved, written string) bool {
+	if valuesEqual(saved, written) {
+		return true
+	}
+	a, errA := strconv.ParseFloat(saved, 64)
+	b, errB := strconv.ParseFloat(written, 64)
+	return errA == nil && errB == nil && w.config.Rounding.For(column).Equal(a, b)
+}
+
+// latestEntries keeps the last journaled write of every cell, in journal order
+func latestEntries(entries []journal.Entry) []journal.Entry {
+	last := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		last[entry.Sheet+"!"+entry.Cell] = i
+	}
+
+	var latest []journal.Entry
+	for i, entry := range entries {
+		if last[entry.Sheet+"!"+entry.Cell] == i {
+			latest = append(latest, entry)
+		}
+	}
+	return latest
+}
+
+// mismatchMessage describes a cell whose saved content differs from what the run wrote
+func mismatchMessage(entry journal.Entry, saved, written string) string {
+	who := ""
+	if entry.StudentID != "" {
+		who = fmt.Sprintf(" for student %s", entry.StudentID)
+	}
+	return fmt.Sprintf("Verification failed%s: %s!%s holds %q, expected %q", who, entry.Sheet, entry.Cell, saved, written)
+}
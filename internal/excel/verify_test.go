@@ -0,0 +1,133 @@
+package excel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestVerifyMasterSheet tests comparing a saved master against the journaled writes
+func TestVerifyMasterSheet(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	one := 1
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7"},
+		MasterColumns:       []string{"I", "J"},
+		Derived:             config.DerivedConfig{TotalColumn: "W"},
+		Rounding:            config.RoundingConfig{Precision: &one},
+	})
+	students := []*models.StudentData{{
+		StudentID: "STU001",
+		Marks:     map[string]float64{"C6": 70, "C7": 80},
+	}}
+
+	summary, err := writer.BatchUpdateMasterSheet(testFile, students)
+	if err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+	if summary.VerificationFailures != 0 || len(summary.Errors) != 0 {
+		t.Fatalf("clean run verification = %d, errors %v", summary.VerificationFailures, summary.Errors)
+	}
+
+	// Tamper with the saved file: a difference below the precision, a changed mark and a changed formula
+	f, _ := excelize.OpenFile(testFile)
+	f.SetCellFloat("001", "I2", 70.04, -1, 64)
+	f.SetCellFloat("001", "J2", 8, -1, 64)
+	f.SetCellFormula("001", "W2", "I2")
+	f.Save()
+	f.Close()
+
+	mismatches, err := writer.VerifyMasterSheet(testFile, writer.LastJournal())
+	if err != nil {
+		t.Fatalf("VerifyMasterSheet() error = %v", err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("mismatches = %v, want J2 and W2", mismatches)
+	}
+	if !strings.Contains(mismatches[0], "STU001") || !strings.Contains(mismatches[0], "001!J2") {
+		t.Errorf("mismatch[0] = %q", mismatches[0])
+	}
+	if !strings.Contains(mismatches[1], "001!W2") {
+		t.Errorf("mismatch[1] = %q", mismatches[1])
+	}
+}
+
+// TestVerifyMasterSheetAgainstIntent tests that a wrong value is caught even though it was journaled as read back
+func TestVerifyMasterSheetAgainstIntent(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"I"},
+	})
+	writer.journal = journal.New("run-1", testFile)
+
+	f, err := excelize.OpenFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	student := &models.StudentData{StudentID: "STU001"}
+	// A faulty write path stores ten times the intended mark
+	if err := writer.writeCell(f, student, "I", 2, cellContent{value: "7.5"}, func(sheet, cell string) error {
+		return f.SetCellFloat(sheet, cell, 75, -1, 64)
+	}); err != nil {
+		t.Fatalf("writeCell() error = %v", err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Failed to save master: %v", err)
+	}
+	f.Close()
+
+	mismatches, err := writer.VerifyMasterSheet(testFile, writer.LastJournal())
+	if err != nil {
+		t.Fatalf("VerifyMasterSheet() error = %v", err)
+	}
+	if len(mismatches) != 1 || !strings.Contains(mismatches[0], `holds "75", expected "7.5"`) {
+		t.Errorf("mismatches = %v, want I2 holding 75 instead of 7.5", mismatches)
+	}
+}
+
+// TestLatestEntries tests keeping only the final write of a cell
+func TestLatestEntries(t *testing.T) {
+	entries := []journal.Entry{
+		{Sheet: "001", Cell: "I2", NewValue: "1"},
+		{Sheet: "001", Cell: "J2", NewValue: "2"},
+		{Sheet: "001", Cell: "I2", NewValue: "3"},
+	}
+
+	latest := latestEntries(entries)
+	if len(latest) != 2 || latest[0].Cell != "J2" || latest[1].NewValue != "3" {
+		t.Errorf("latestEntries() = %+v", latest)
+	}
+}
+
+// TestUndoAfterRecalculation tests that recalculated formula cells are not undo conflicts
+func TestUndoAfterRecalculation(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6", "C7"},
+		MasterColumns:       []string{"I", "J"},
+		Derived:             config.DerivedConfig{TotalColumn: "W"},
+		RecalculateFormulas: true,
+	})
+	students := []*models.StudentData{{StudentID: "STU001", Marks: map[string]float64{"C6": 70, "C7": 80}}}
+
+	if _, err := writer.BatchUpdateMasterSheet(testFile, students); err != nil {
+		t.Fatalf("BatchUpdateMasterSheet() error = %v", err)
+	}
+
+	result, err := writer.UndoJournal(testFile, writer.LastJournal())
+	if err != nil {
+		t.Fatalf("UndoJournal() error = %v", err)
+	}
+	if result.Reverted != 3 || len(result.Conflicts) != 0 {
+		t.Errorf("UndoJournal() reverted %d, conflicts %+v", result.Reverted, result.Conflicts)
+	}
+}
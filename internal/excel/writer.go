@@ -3,20 +3,26 @@
 package excel
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/xuri/excelize/v2"
+	"mark-master-sheet/internal/backup"
 	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/journal"
 	"mark-master-sheet/pkg/models"
 )
 
 // Writer handles writing to Excel files
 type Writer struct {
-	config *config.ExcelConfig
-	reader *Reader
+	config  *config.ExcelConfig
+	reader  *Reader
+	runID   string
+	journal *journal.Journal
 }
 
 // NewWriter creates a new Excel writer
@@ -27,113 +33,29 @@ func NewWriter(cfg *config.ExcelConfig) *Writer {
 	}
 }
 
-// CreateBackup creates a timestamped backup of the master sheet
-func (w *Writer) CreateBackup(masterSheetPath, backupDir string) (string, error) {
-	// Ensure backup directory exists
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create backup directory: %w", err)
-	}
-
-	// Generate backup filename with timestamp
-	timestamp := time.Now().Format("20060102_150405")
-	originalName := filepath.Base(masterSheetPath)
-	ext := filepath.Ext(originalName)
-	nameWithoutExt := originalName[:len(originalName)-len(ext)]
-	backupName := fmt.Sprintf("%s_backup_%s%s", nameWithoutExt, timestamp, ext)
-	backupPath := filepath.Join(backupDir, backupName)
-
-	// Copy the file
-	sourceFile, err := os.Open(masterSheetPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(backupPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer destFile.Close()
-
-	// Copy file contents
-	buffer := make([]byte, 64*1024) // 64KB buffer
-	for {
-		n, err := sourceFile.Read(buffer)
-		if n > 0 {
-			if _, writeErr := destFile.Write(buffer[:n]); writeErr != nil {
-				return "", fmt.Errorf("failed to write to backup file: %w", writeErr)
-			}
-		}
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return "", fmt.Errorf("failed to read from source file: %w", err)
-		}
-	}
+// SetRunID sets the run ID recorded with backups and changes made by the writer
+func (w *Writer) SetRunID(runID string) {
+	w.runID = runID
+}
 
-	return backupPath, nil
+// CreateBackup creates a verified, timestamped backup of the master sheet
+func (w *Writer) CreateBackup(masterSheetPath, backupDir string) (string, error) {
+	return backup.Create(masterSheetPath, backupDir, w.runID)
 }
 
-// UpdateMasterSheet updates the master sheet with student data
+// UpdateMasterSheet updates the master sheet with the data of one student. It is a batch update
+// of a single student, so the marks are rounded, guarded and journaled like those of a run.
 func (w *Writer) UpdateMasterSheet(masterSheetPath string, studentData *models.StudentData) error {
-	// Open the master sheet
-	masterFile, err := excelize.OpenFile(masterSheetPath)
+	summary, err := w.BatchUpdateMasterSheet(masterSheetPath, []*models.StudentData{studentData})
 	if err != nil {
-		return fmt.Errorf("failed to open master sheet: %w", err)
-	}
-	defer masterFile.Close()
-
-	// Check if the master worksheet exists
-	worksheets := masterFile.GetSheetList()
-	worksheetExists := false
-	for _, sheet := range worksheets {
-		if sheet == w.config.MasterWorksheetName {
-			worksheetExists = true
-			break
-		}
+		return err
 	}
-
-	if !worksheetExists {
-		return fmt.Errorf("master worksheet '%s' not found", w.config.MasterWorksheetName)
+	if summary.StudentsNotFound > 0 {
+		return fmt.Errorf("student not found in master sheet: %s", studentData.StudentID)
 	}
-
-	// Find the student in the master sheet
-	rowNumber, err := w.reader.FindStudentInMasterSheet(masterFile, studentData.StudentID)
-	if err != nil {
-		return fmt.Errorf("student not found in master sheet: %w", err)
+	if len(summary.Errors) > 0 {
+		return fmt.Errorf("failed to update student %s: %s", studentData.StudentID, strings.Join(summary.Errors, "; "))
 	}
-
-	// Update marks in the corresponding columns
-	for i, markCell := range w.config.MarkCells {
-		if i >= len(w.config.MasterColumns) {
-			break // Safety check
-		}
-
-		mark, exists := studentData.Marks[markCell]
-		if !exists {
-			continue // Skip if mark doesn't exist
-		}
-
-		// Skip empty marks (represented as -1)
-		if mark < 0 {
-			continue
-		}
-
-		// Calculate the target cell (column + row)
-		targetCell := fmt.Sprintf("%s%d", w.config.MasterColumns[i], rowNumber)
-
-		// Set the mark value
-		if err := masterFile.SetCellFloat(w.config.MasterWorksheetName, targetCell, mark, 2, 64); err != nil {
-			return fmt.Errorf("failed to set mark in cell %s: %w", targetCell, err)
-		}
-	}
-
-	// Save the updated master sheet
-	if err := masterFile.Save(); err != nil {
-		return fmt.Errorf("failed to save master sheet: %w", err)
-	}
-
 	return nil
 }
 
@@ -169,9 +91,16 @@ func (w *Writer) SaveMasterSheetCopy(masterSheetPath, outputDir string) (string,
 
 // BatchUpdateMasterSheet updates the master sheet with multiple student data entries
 func (w *Writer) BatchUpdateMasterSheet(masterSheetPath string, studentDataList []*models.StudentData) (*models.ProcessingSummary, error) {
+	return w.BatchUpdateMasterSheetContext(context.Background(), masterSheetPath, studentDataList)
+}
+
+// BatchUpdateMasterSheetContext updates the master sheet like BatchUpdateMasterSheet, but stops
+// without saving and returns the context error if ctx is cancelled before the workbook is saved
+func (w *Writer) BatchUpdateMasterSheetContext(ctx context.Context, masterSheetPath string, studentDataList []*models.StudentData) (*models.ProcessingSummary, error) {
 	summary := &models.ProcessingSummary{
 		StartTime: time.Now(),
 	}
+	w.journal = journal.New(w.runID, masterSheetPath)
 
 	// Open the master sheet once for all updates
 	masterFile, err := excelize.OpenFile(masterSheetPath)
@@ -194,10 +123,37 @@ func (w *Writer) BatchUpdateMasterSheet(masterSheetPath string, studentDataList
 		return summary, fmt.Errorf("master worksheet '%s' not found", w.config.MasterWorksheetName)
 	}
 
+	marker, err := w.newUpdateMarker(masterFile)
+	if err != nil {
+		return summary, err
+	}
+
+	guard, err := w.newTargetGuard(masterFile)
+	if err != nil {
+		return summary, err
+	}
+
 	// Process each student data
 	for _, studentData := range studentDataList {
+		if err := ctx.Err(); err != nil {
+			return summary, w.abandon(err)
+		}
+
 		// Find the student in the master sheet
 		rowNumber, err := w.reader.FindStudentInMasterSheet(masterFile, studentData.StudentID)
+		if err != nil && w.config.AppendUnmatched {
+			// Add late enrolments as new rows instead of skipping them
+			newRow, appendErr := w.appendStudentRow(masterFile, studentData)
+			if appendErr != nil {
+				summary.Warnings = append(summary.Warnings,
+					fmt.Sprintf("Failed to add student %s to master sheet: %v", studentData.StudentID, appendErr))
+			} else {
+				rowNumber, err = newRow, nil
+				summary.StudentsAdded++
+				summary.Warnings = append(summary.Warnings,
+					fmt.Sprintf("Student %s added to master sheet at row %d, please review", studentData.StudentID, rowNumber))
+			}
+		}
 		if err != nil {
 			summary.StudentsNotFound++
 			summary.Warnings = append(summary.Warnings,
@@ -205,6 +161,29 @@ func (w *Writer) BatchUpdateMasterSheet(masterSheetPath string, studentDataList
 			continue
 		}
 
+		// Refuse to replace formulas, locked or merged cells unless explicitly overridden
+		blocked, err := guard.blockedCells(w.targetColumns(studentData), rowNumber)
+		if err != nil {
+			summary.Errors = append(summary.Errors,
+				fmt.Sprintf("Failed to check target cells for student %s: %v", studentData.StudentID, err))
+			continue
+		}
+		if len(blocked) > 0 {
+			cells := make([]string, len(blocked))
+			for i, b := range blocked {
+				cells[i] = b.String()
+			}
+			if !w.config.OverwriteProtected {
+				summary.Errors = append(summary.Errors,
+					fmt.Sprintf("Student %s not updated, target cells are protected: %s",
+						studentData.StudentID, strings.Join(cells, "; ")))
+				continue
+			}
+			summary.Warnings = append(summary.Warnings,
+				fmt.Sprintf("Overwriting protected cells for student %s: %s",
+					studentData.StudentID, strings.Join(cells, "; ")))
+		}
+
 		// Update marks in the corresponding columns
 		markCount := 0
 		for i, markCell := range w.config.MarkCells {
@@ -217,35 +196,99 @@ func (w *Writer) BatchUpdateMasterSheet(masterSheetPath string, studentDataList
 				continue // Skip if mark doesn't exist or is empty
 			}
 
-			// Calculate the target cell (column + row)
-			targetCell := fmt.Sprintf("%s%d", w.config.MasterColumns[i], rowNumber)
-
-			// Set the mark value
-			if err := masterFile.SetCellFloat(w.config.MasterWorksheetName, targetCell, mark, 2, 64); err != nil {
+			// Set the mark value and record it in the journal
+			if err := w.setMark(masterFile, studentData, w.config.MasterColumns[i], rowNumber, mark); err != nil {
 				summary.Errors = append(summary.Errors,
-					fmt.Sprintf("Failed to set mark for student %s in cell %s: %v",
-						studentData.StudentID, targetCell, err))
+					fmt.Sprintf("Failed to set mark for student %s in cell %s%d: %v",
+						studentData.StudentID, w.config.MasterColumns[i], rowNumber, err))
 				continue
 			}
 			markCount++
+
+			// Highlight and annotate the updated cell if enabled
+			if marker != nil {
+				targetCell := fmt.Sprintf("%s%d", w.config.MasterColumns[i], rowNumber)
+				if err := marker.mark(targetCell, studentData.FilePath); err != nil {
+					summary.Warnings = append(summary.Warnings,
+						fmt.Sprintf("Failed to mark updated cell %s for student %s: %v",
+							targetCell, studentData.StudentID, err))
+				} else {
+					w.recordHighlight(marker, targetCell)
+				}
+			}
 		}
 
 		if markCount > 0 {
 			summary.StudentsUpdated++
+
+			// Refresh the total and grade columns from the new marks
+			if err := w.writeDerived(masterFile, studentData, rowNumber); err != nil {
+				summary.Errors = append(summary.Errors,
+					fmt.Sprintf("Failed to update derived columns for student %s: %v", studentData.StudentID, err))
+			}
 		}
 	}
 
+	// Refresh the cached values of formulas depending on the written cells
+	if w.config.RecalculateFormulas {
+		recalc, err := w.recalculateFormulas(masterFile, w.journal.Entries)
+		if err != nil {
+			return summary, fmt.Errorf("failed to recalculate formulas: %w", err)
+		}
+		summary.FormulasRecalculated = recalc.Recalculated
+		summary.Errors = append(summary.Errors, recalc.Errors...)
+		summary.Warnings = append(summary.Warnings, recalc.Warnings...)
+	}
+
+	// Record the provenance of every write in the audit worksheet
+	if err := w.appendAudit(masterFile, w.runID, summary.StartTime, w.journal.Entries); err != nil {
+		return summary, err
+	}
+
+	// Last chance to stop before the master sheet on disk is changed
+	if err := ctx.Err(); err != nil {
+		return summary, w.abandon(err)
+	}
+
 	// Save the updated master sheet
 	if err := masterFile.Save(); err != nil {
 		return summary, fmt.Errorf("failed to save master sheet: %w", err)
 	}
 
+	// Read the saved file back and check it holds every write of the run
+	mismatches, err := w.VerifyMasterSheet(masterSheetPath, w.journal)
+	if err != nil {
+		mismatches = append(mismatches, fmt.Sprintf("Verification could not be completed: %v", err))
+	}
+	summary.VerificationFailures = len(mismatches)
+	summary.Errors = append(summary.Errors, mismatches...)
+
 	summary.EndTime = time.Now()
 	summary.TotalDuration = summary.EndTime.Sub(summary.StartTime)
 
 	return summary, nil
 }
 
+// abandon drops the journal of a batch update that was not saved and returns err
+func (w *Writer) abandon(err error) error {
+	w.journal = nil
+	return err
+}
+
+// targetColumns returns the master columns that receive a mark for a student
+func (w *Writer) targetColumns(studentData *models.StudentData) []string {
+	var columns []string
+	for i, markCell := range w.config.MarkCells {
+		if i >= len(w.config.MasterColumns) {
+			break
+		}
+		if mark, exists := studentData.Marks[markCell]; exists && mark >= 0 {
+			columns = append(columns, w.config.MasterColumns[i])
+		}
+	}
+	return columns
+}
+
 // ValidateMasterSheet checks if the master sheet has the expected structure
 func (w *Writer) ValidateMasterSheet(masterSheetPath string) error {
 	masterFile, err := excelize.OpenFile(masterSheetPath)
@@ -1,6 +1,9 @@
 package excel
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -187,6 +190,42 @@ func TestWriterBasicFunctionality(t *testing.T) {
 	}
 }
 
+// TestBatchUpdateMasterSheetContextCancelled tests that a cancelled batch update leaves the master sheet unchanged
+func TestBatchUpdateMasterSheetContextCancelled(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	before, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read master: %v", err)
+	}
+
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"I"},
+	})
+	students := []*models.StudentData{
+		{StudentID: "STU001", Marks: map[string]float64{"C6": 70}},
+		{StudentID: "STU002", Marks: map[string]float64{"C6": 80}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := writer.BatchUpdateMasterSheetContext(ctx, testFile, students); !errors.Is(err, context.Canceled) {
+		t.Fatalf("BatchUpdateMasterSheetContext() error = %v, want context.Canceled", err)
+	}
+	if writer.LastJournal() != nil {
+		t.Errorf("LastJournal() = %+v, want nil for an unsaved update", writer.LastJournal())
+	}
+
+	after, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read master: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("cancelled batch update changed the master sheet")
+	}
+}
+
 // Helper function to create test master file for writer tests
 func createTestMasterFileForWriter(t *testing.T) string {
 	f := excelize.NewFile()
@@ -307,3 +346,38 @@ func BenchmarkWriterCreation(b *testing.B) {
 }
 
 
+
+// TestUpdateMasterSheetRoundsAndJournals tests that a single student update follows the rounding rule and can be undone
+func TestUpdateMasterSheetRoundsAndJournals(t *testing.T) {
+	testFile := createTestMasterFileForWriter(t)
+	one := 1
+	writer := NewWriter(&config.ExcelConfig{
+		MasterWorksheetName: "001",
+		MarkCells:           []string{"C6"},
+		MasterColumns:       []string{"I"},
+		Rounding:            config.RoundingConfig{Mode: config.RoundHalfUp, Precision: &one},
+	})
+
+	student := &models.StudentData{StudentID: "STU001", Marks: map[string]float64{"C6": 85.55}}
+	if err := writer.UpdateMasterSheet(testFile, student); err != nil {
+		t.Fatalf("UpdateMasterSheet() error = %v", err)
+	}
+
+	f, err := excelize.OpenFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	value, _ := f.GetCellValue("001", "I2", excelize.Options{RawCellValue: true})
+	f.Close()
+	if value != "85.6" {
+		t.Errorf("I2 = %q, want the mark rounded to 85.6", value)
+	}
+
+	j := writer.LastJournal()
+	if j == nil || len(j.Entries) != 1 || j.Entries[0].Cell != "I2" {
+		t.Fatalf("journal = %+v, want the write of I2", j)
+	}
+	if _, err := writer.UndoJournal(testFile, j); err != nil {
+		t.Fatalf("UndoJournal() error = %v", err)
+	}
+}
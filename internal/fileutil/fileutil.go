@@ -0,0 +1,79 @@
+// Package fileutil provides file helpers shared across the Mark Master Sheet Consolidator.
+// It handles durable file copies, content hashing and process identity.
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+)
+
+// HashFile returns the hex encoded SHA-256 digest and size of a file
+func HashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return HashReader(file)
+}
+
+// HashReader returns the hex encoded SHA-256 digest and size of the reader contents
+func HashReader(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash contents: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// CopyFile copies src to dst, replacing dst if it exists, and syncs the copy to disk.
+// It returns the SHA-256 digest and size of the data read from src.
+func CopyFile(src, dst string) (string, int64, error) {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create destination file: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(destFile, io.TeeReader(sourceFile, hash))
+	if err != nil {
+		destFile.Close()
+		return "", 0, fmt.Errorf("failed to copy file contents: %w", err)
+	}
+
+	if err := destFile.Sync(); err != nil {
+		destFile.Close()
+		return "", 0, fmt.Errorf("failed to sync destination file: %w", err)
+	}
+
+	if err := destFile.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to close destination file: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// CurrentUser returns the login name of the user running the process, or "unknown"
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, key := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(key); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,73 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashFile tests SHA-256 hashing of a file
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	sum, size, err := HashFile(path)
+	if err != nil {
+		t.Fatalf("HashFile() error = %v", err)
+	}
+
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if sum != want {
+		t.Errorf("HashFile() = %s, want %s", sum, want)
+	}
+	if size != 3 {
+		t.Errorf("HashFile() size = %d, want 3", size)
+	}
+
+	if _, _, err := HashFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("HashFile() expected error for missing file")
+	}
+}
+
+// TestCopyFile tests copying a file with hashing
+func TestCopyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src.bin")
+	dst := filepath.Join(tempDir, "dst.bin")
+
+	content := strings.Repeat("mark master sheet ", 10000)
+	if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("stale content"), 0644); err != nil {
+		t.Fatalf("Failed to create destination: %v", err)
+	}
+
+	sum, size, err := CopyFile(src, dst)
+	if err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("CopyFile() size = %d, want %d", size, len(content))
+	}
+
+	copied, _ := os.ReadFile(dst)
+	if string(copied) != content {
+		t.Error("CopyFile() destination content differs from source")
+	}
+
+	dstSum, _, _ := HashFile(dst)
+	if dstSum != sum {
+		t.Errorf("CopyFile() digest = %s, destination digest = %s", sum, dstSum)
+	}
+}
+
+// TestCurrentUser tests that the process owner can always be named
+func TestCurrentUser(t *testing.T) {
+	if name := CurrentUser(); name == "" {
+		t.Error("CurrentUser() returned an empty name")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 
+	"mark-master-sheet/internal/backup"
 	"mark-master-sheet/internal/config"
 	"mark-master-sheet/internal/logger"
 	"mark-master-sheet/internal/processor"
@@ -46,6 +47,16 @@ type App struct {
 	enableBackupCheck   *widget.Check
 	skipInvalidCheck    *widget.Check
 	maxConcurrentEntry  *widget.Entry
+	keepBackupsEntry    *widget.Entry
+	highlightCheck      *widget.Check
+	commentCheck        *widget.Check
+	auditCheck          *widget.Check
+	appendRowsCheck     *widget.Check
+	
+	backupList          *widget.List
+	backupDetailsLabel  *widget.Label
+	backups             []backup.Info
+	selectedBackup      int
 	
 	progressBar         *widget.ProgressBar
 	statusLabel         *widget.Label
@@ -121,6 +132,7 @@ func (a *App) setupUI() {
 		container.NewTabItem("Excel Settings", a.createExcelSettingsTab()),
 		container.NewTabItem("Mark Mappings", a.createMarkMappingsTab()),
 		container.NewTabItem("Processing", a.createProcessingTab()),
+		container.NewTabItem("Backups", a.createBackupsTab()),
 		container.NewTabItem("Logs", a.createLogsTab()),
 	)
 
@@ -465,6 +477,11 @@ func (a *App) createProcessingTab() *fyne.Container {
 	a.skipInvalidCheck = widget.NewCheck("Skip Invalid Files (Continue on errors)", nil)
 	a.skipInvalidCheck.SetChecked(true)
 
+	a.highlightCheck = widget.NewCheck("Highlight Updated Cells", nil)
+	a.commentCheck = widget.NewCheck("Comment Updated Cells (source file and time)", nil)
+	a.auditCheck = widget.NewCheck("Record Changes in Protected Audit Worksheet", nil)
+	a.appendRowsCheck = widget.NewCheck("Add Unmatched Students as New Rows (marked for review)", nil)
+
 	a.maxConcurrentEntry = widget.NewEntry()
 	a.maxConcurrentEntry.SetText("10")
 	a.maxConcurrentEntry.SetPlaceHolder("1-20")
@@ -475,6 +492,16 @@ func (a *App) createProcessingTab() *fyne.Container {
 		return nil
 	}
 
+	a.keepBackupsEntry = widget.NewEntry()
+	a.keepBackupsEntry.SetText("0")
+	a.keepBackupsEntry.SetPlaceHolder("0 = keep all")
+	a.keepBackupsEntry.Validator = func(text string) error {
+		if val, err := strconv.Atoi(text); err != nil || val < 0 {
+			return fmt.Errorf("must be a number of 0 or more")
+		}
+		return nil
+	}
+
 	// Enhanced processing buttons
 	dryRunButton := widget.NewButton("Dry Run (Test)", func() {
 		a.startProcessing(true)
@@ -507,7 +534,11 @@ func (a *App) createProcessingTab() *fyne.Container {
 	optionsForm := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "Backup Options:", Widget: a.enableBackupCheck},
+			{Text: "Keep Last Backups:", Widget: a.keepBackupsEntry},
 			{Text: "Error Handling:", Widget: a.skipInvalidCheck},
+			{Text: "Mark Updates:", Widget: container.NewVBox(a.highlightCheck, a.commentCheck)},
+			{Text: "Audit Trail:", Widget: a.auditCheck},
+			{Text: "Unmatched Students:", Widget: a.appendRowsCheck},
 			{Text: "Concurrent Processing:", Widget: a.maxConcurrentEntry},
 		},
 	}
@@ -923,8 +954,14 @@ func (a *App) resetToDefaults() {
 	a.enableBackupCheck.SetChecked(true)
 	a.skipInvalidCheck.SetChecked(true)
 	a.maxConcurrentEntry.SetText("10")
+	a.keepBackupsEntry.SetText("0")
+	a.highlightCheck.SetChecked(false)
+	a.commentCheck.SetChecked(false)
+	a.auditCheck.SetChecked(false)
+	a.appendRowsCheck.SetChecked(false)
 
 	a.resetMarkMappings()
+	a.config = nil
 
 	a.updateStatus("Configuration reset to defaults")
 }
@@ -979,9 +1016,15 @@ Mark Mappings:
 
 Processing Options:
 - Enable Backup: Creates timestamped backups before changes
+- Keep Last Backups: Number of backups to keep (0 keeps all)
 - Skip Invalid Files: Continues processing if some files fail
 - Max Concurrent Files: Number of files to process simultaneously (1-20)
 
+Backups:
+- Lists backups of the master sheet with creation time and size
+- "Restore Selected" replaces the master sheet after confirmation
+- The current master sheet is backed up before a restore
+
 For more detailed information, see the documentation at:
 https://github.com/v-eenay/MarkMasterSheetConsolidator`
 
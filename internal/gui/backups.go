@@ -0,0 +1,147 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"mark-master-sheet/internal/backup"
+	"mark-master-sheet/internal/lock"
+)
+
+// createBackupsTab creates the backups tab for listing and restoring master sheet backups
+func (a *App) createBackupsTab() *fyne.Container {
+	a.selectedBackup = -1
+
+	a.backupList = widget.NewList(
+		func() int {
+			return len(a.backups)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("backup")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < len(a.backups) {
+				b := a.backups[id]
+				obj.(*widget.Label).SetText(fmt.Sprintf("%s    %s    %s",
+					b.CreatedAt.Format("2006-01-02 15:04:05"), backup.FormatSize(b.Size), b.Name))
+			}
+		},
+	)
+	a.backupList.OnSelected = func(id widget.ListItemID) {
+		a.selectedBackup = id
+		if id < len(a.backups) {
+			a.backupDetailsLabel.SetText(fmt.Sprintf("Selected: %s", a.backups[id].Path))
+		}
+	}
+	a.backupList.OnUnselected = func(id widget.ListItemID) {
+		a.selectedBackup = -1
+		a.backupDetailsLabel.SetText("No backup selected")
+	}
+
+	a.backupDetailsLabel = createSecondaryLabel("No backup selected")
+
+	refreshButton := widget.NewButton("Refresh", func() {
+		a.refreshBackups()
+	})
+	refreshButton.Importance = widget.MediumImportance
+
+	restoreButton := widget.NewButton("Restore Selected", func() {
+		a.confirmRestoreBackup()
+	})
+	restoreButton.Importance = widget.DangerImportance
+
+	listScroll := container.NewScroll(a.backupList)
+	listScroll.SetMinSize(fyne.NewSize(700, 300))
+
+	helpText := createHelpText("Restoring replaces the master sheet with the selected backup. The current master sheet is backed up first.")
+
+	return container.NewVBox(
+		widget.NewCard("Master Sheet Backups",
+			"Review and restore timestamped backups of the master sheet",
+			container.NewVBox(
+				listScroll,
+				a.backupDetailsLabel,
+				widget.NewSeparator(),
+				container.NewHBox(refreshButton, restoreButton),
+				helpText,
+			)),
+	)
+}
+
+// refreshBackups reloads the list of backups for the configured master sheet
+func (a *App) refreshBackups() {
+	backups, err := backup.List(a.backupFolderEntry.Text, a.masterFileEntry.Text)
+	if err != nil {
+		a.showError(fmt.Sprintf("Failed to list backups: %v", err))
+		return
+	}
+
+	a.backups = backups
+	a.selectedBackup = -1
+	a.backupList.UnselectAll()
+	a.backupList.Refresh()
+	a.backupDetailsLabel.SetText(fmt.Sprintf("%d backup(s) found", len(backups)))
+}
+
+// confirmRestoreBackup asks for confirmation before restoring the selected backup
+func (a *App) confirmRestoreBackup() {
+	if a.isProcessing {
+		a.showError("Cannot restore a backup while processing is in progress")
+		return
+	}
+	if a.selectedBackup < 0 || a.selectedBackup >= len(a.backups) {
+		a.showError("Select a backup to restore")
+		return
+	}
+
+	selected := a.backups[a.selectedBackup]
+	message := fmt.Sprintf("Restore %s over %s?\n\nThe current master sheet will be backed up first.",
+		selected.Name, filepath.Base(a.masterFileEntry.Text))
+
+	dialog.ShowConfirm("Restore Backup", message, func(ok bool) {
+		if ok {
+			a.restoreBackup(selected)
+		}
+	}, a.window)
+}
+
+// restoreBackup restores the given backup over the master sheet while holding the master sheet
+// lock, so that it cannot overwrite the file under a CLI or watch mode run
+func (a *App) restoreBackup(selected backup.Info) {
+	var staleAfter time.Duration
+	if a.config != nil {
+		staleAfter = a.config.Processing.LockStaleAfter()
+	}
+	masterLock, err := lock.Acquire(a.masterFileEntry.Text, "restore", staleAfter)
+	if err != nil {
+		a.showError(fmt.Sprintf("Failed to restore backup: %v", err))
+		return
+	}
+	defer func() {
+		if err := masterLock.Release(); err != nil {
+			a.appendLog(fmt.Sprintf("Warning: failed to release master sheet lock: %v\n", err))
+		}
+	}()
+
+	safetyBackup, err := backup.Restore(selected.Path, a.masterFileEntry.Text, a.backupFolderEntry.Text)
+	if err != nil && !backup.ManifestOnly(err) {
+		a.showError(fmt.Sprintf("Failed to restore backup: %v", err))
+		return
+	}
+	if err != nil {
+		a.appendLog(fmt.Sprintf("Warning: %v\n", err))
+	}
+
+	if safetyBackup != "" {
+		a.appendLog(fmt.Sprintf("Previous master sheet backed up to: %s\n", safetyBackup))
+	}
+	a.appendLog(fmt.Sprintf("Restored backup %s over %s\n", selected.Name, a.masterFileEntry.Text))
+	a.updateStatus("Backup restored successfully")
+	a.refreshBackups()
+}
@@ -0,0 +1,121 @@
+package gui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+
+	"mark-master-sheet/internal/backup"
+	"mark-master-sheet/internal/lock"
+)
+
+// TestRefreshBackups tests listing backups in the backups tab
+func TestRefreshBackups(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	tempDir := t.TempDir()
+	masterFile := filepath.Join(tempDir, "master.xlsx")
+	backupDir := filepath.Join(tempDir, "backups")
+	if err := os.WriteFile(masterFile, []byte("master"), 0644); err != nil {
+		t.Fatalf("Failed to create master file: %v", err)
+	}
+	app.masterFileEntry.SetText(masterFile)
+	app.backupFolderEntry.SetText(backupDir)
+
+	app.refreshBackups()
+	if len(app.backups) != 0 {
+		t.Errorf("refreshBackups() found %d backups, want 0", len(app.backups))
+	}
+
+	if _, err := backup.Create(masterFile, backupDir, "test-run"); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	app.refreshBackups()
+	if len(app.backups) != 1 {
+		t.Errorf("refreshBackups() found %d backups, want 1", len(app.backups))
+	}
+	if app.selectedBackup != -1 {
+		t.Error("refreshBackups() should clear the selection")
+	}
+}
+
+// TestRestoreBackup tests restoring a backup from the backups tab
+func TestRestoreBackup(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	tempDir := t.TempDir()
+	masterFile := filepath.Join(tempDir, "master.xlsx")
+	backupDir := filepath.Join(tempDir, "backups")
+	os.WriteFile(masterFile, []byte("version 1"), 0644)
+	app.masterFileEntry.SetText(masterFile)
+	app.backupFolderEntry.SetText(backupDir)
+
+	if _, err := backup.Create(masterFile, backupDir, "test-run"); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	os.WriteFile(masterFile, []byte("version 2"), 0644)
+
+	app.refreshBackups()
+	app.restoreBackup(app.backups[0])
+
+	content, _ := os.ReadFile(masterFile)
+	if string(content) != "version 1" {
+		t.Errorf("restoreBackup() master content = %q, want %q", content, "version 1")
+	}
+
+	// The restore must have backed up the replaced master first
+	if len(app.backups) != 2 {
+		t.Errorf("restoreBackup() should leave 2 backups, got %d", len(app.backups))
+	}
+}
+
+// TestRestoreBackupLocked tests that a restore leaves the master sheet alone while another run holds its lock
+func TestRestoreBackupLocked(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	tempDir := t.TempDir()
+	masterFile := filepath.Join(tempDir, "master.xlsx")
+	backupDir := filepath.Join(tempDir, "backups")
+	os.WriteFile(masterFile, []byte("version 1"), 0644)
+	app.masterFileEntry.SetText(masterFile)
+	app.backupFolderEntry.SetText(backupDir)
+
+	if _, err := backup.Create(masterFile, backupDir, "test-run"); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	os.WriteFile(masterFile, []byte("version 2"), 0644)
+	app.refreshBackups()
+
+	held, err := lock.Acquire(masterFile, "run", 0)
+	if err != nil {
+		t.Fatalf("lock.Acquire() error = %v", err)
+	}
+	app.restoreBackup(app.backups[0])
+	if content, _ := os.ReadFile(masterFile); string(content) != "version 2" {
+		t.Errorf("master content = %q while locked, want it unchanged", content)
+	}
+
+	held.Release()
+	app.restoreBackup(app.backups[0])
+	if content, _ := os.ReadFile(masterFile); string(content) != "version 1" {
+		t.Errorf("master content = %q after the lock was released, want %q", content, "version 1")
+	}
+	if _, err := lock.Acquire(masterFile, "run", 0); err != nil {
+		t.Errorf("lock.Acquire() after restore error = %v, want the restore lock released", err)
+	}
+}
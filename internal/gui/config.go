@@ -1,6 +1,7 @@
 package gui
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+	"github.com/BurntSushi/toml"
 
 	"mark-master-sheet/internal/config"
 )
@@ -26,6 +28,11 @@ func (a *App) loadDefaultConfig() {
 	a.enableBackupCheck.SetChecked(true)
 	a.skipInvalidCheck.SetChecked(true)
 	a.maxConcurrentEntry.SetText("10")
+	a.keepBackupsEntry.SetText("0")
+	a.highlightCheck.SetChecked(false)
+	a.commentCheck.SetChecked(false)
+	a.auditCheck.SetChecked(false)
+	a.appendRowsCheck.SetChecked(false)
 	
 	a.updateStatus("Default configuration loaded")
 }
@@ -46,6 +53,7 @@ func (a *App) loadConfigFromFile() {
 			return
 		}
 
+		a.config = cfg
 		a.applyConfigToUI(cfg)
 		a.updateStatus(fmt.Sprintf("Configuration loaded from %s", filepath.Base(configPath)))
 
@@ -102,6 +110,11 @@ func (a *App) applyConfigToUI(cfg *config.Config) {
 	a.enableBackupCheck.SetChecked(cfg.Processing.BackupEnabled)
 	a.skipInvalidCheck.SetChecked(cfg.Processing.SkipInvalidFiles)
 	a.maxConcurrentEntry.SetText(fmt.Sprintf("%d", cfg.Processing.MaxConcurrentFiles))
+	a.keepBackupsEntry.SetText(fmt.Sprintf("%d", cfg.Backup.KeepLast))
+	a.highlightCheck.SetChecked(cfg.Excel.HighlightUpdates)
+	a.commentCheck.SetChecked(cfg.Excel.CommentUpdates)
+	a.auditCheck.SetChecked(cfg.Excel.AuditSheet)
+	a.appendRowsCheck.SetChecked(cfg.Excel.AppendUnmatched)
 	
 	// Mark mappings
 	if len(cfg.Excel.MarkCells) == len(cfg.Excel.MasterColumns) {
@@ -135,6 +148,12 @@ func (a *App) buildConfigFromUI() (*config.Config, error) {
 		return nil, fmt.Errorf("max concurrent files must be between 1 and 20")
 	}
 	
+	// Parse backup retention
+	keepBackups, err := strconv.Atoi(a.keepBackupsEntry.Text)
+	if err != nil || keepBackups < 0 {
+		return nil, fmt.Errorf("keep last backups must be a number of 0 or more")
+	}
+	
 	// Build mark cells and columns from mappings
 	var markCells []string
 	var masterColumns []string
@@ -149,28 +168,51 @@ func (a *App) buildConfigFromUI() (*config.Config, error) {
 		return nil, fmt.Errorf("at least one mark mapping is required")
 	}
 	
-	// Create configuration
-	cfg := &config.Config{
+	// Start from the loaded configuration so that settings without a widget are kept
+	cfg := defaultConfig()
+	if a.config != nil {
+		loaded := *a.config
+		cfg = &loaded
+	}
+
+	cfg.Paths.StudentFilesFolder = a.studentFolderEntry.Text
+	cfg.Paths.MasterSheetPath = a.masterFileEntry.Text
+	cfg.Paths.OutputFolder = a.outputFolderEntry.Text
+	cfg.Paths.BackupFolder = a.backupFolderEntry.Text
+
+	cfg.Excel.StudentWorksheetName = a.studentWorksheetEntry.Text
+	cfg.Excel.MasterWorksheetName = a.masterWorksheetEntry.Text
+	cfg.Excel.StudentIDCell = a.studentIDCellEntry.Text
+	cfg.Excel.MarkCells = markCells
+	cfg.Excel.MasterColumns = masterColumns
+	cfg.Excel.HighlightUpdates = a.highlightCheck.Checked
+	cfg.Excel.CommentUpdates = a.commentCheck.Checked
+	cfg.Excel.AuditSheet = a.auditCheck.Checked
+	cfg.Excel.AppendUnmatched = a.appendRowsCheck.Checked
+
+	cfg.Processing.MaxConcurrentFiles = maxConcurrent
+	cfg.Processing.BackupEnabled = a.enableBackupCheck.Checked
+	cfg.Processing.SkipInvalidFiles = a.skipInvalidCheck.Checked
+	cfg.Backup.KeepLast = keepBackups
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	
+	return cfg, nil
+}
+
+// defaultConfig returns the settings used for everything the UI does not show when no
+// configuration file was loaded
+func defaultConfig() *config.Config {
+	return &config.Config{
 		Paths: config.PathsConfig{
-			StudentFilesFolder: a.studentFolderEntry.Text,
-			MasterSheetPath:    a.masterFileEntry.Text,
-			OutputFolder:       a.outputFolderEntry.Text,
-			LogFolder:          "./logs",
-			BackupFolder:       a.backupFolderEntry.Text,
-		},
-		Excel: config.ExcelConfig{
-			StudentWorksheetName: a.studentWorksheetEntry.Text,
-			MasterWorksheetName:  a.masterWorksheetEntry.Text,
-			StudentIDCell:        a.studentIDCellEntry.Text,
-			MarkCells:            markCells,
-			MasterColumns:        masterColumns,
+			LogFolder: "./logs",
 		},
 		Processing: config.ProcessingConfig{
-			MaxConcurrentFiles: maxConcurrent,
-			BackupEnabled:      a.enableBackupCheck.Checked,
-			SkipInvalidFiles:   a.skipInvalidCheck.Checked,
-			TimeoutSeconds:     300,
-			RetryAttempts:      3,
+			TimeoutSeconds: 300,
+			RetryAttempts:  3,
 		},
 		Logging: config.LoggingConfig{
 			Level:          "INFO",
@@ -181,92 +223,21 @@ func (a *App) buildConfigFromUI() (*config.Config, error) {
 			MaxAgeDays:     30,
 		},
 	}
-	
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, err
-	}
-	
-	return cfg, nil
 }
 
-// saveConfigToPath saves configuration to the specified file path
+// saveConfigToPath saves configuration to the specified file path, writing every section
+// so that settings without a widget survive a save
 func (a *App) saveConfigToPath(cfg *config.Config, configPath string) error {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(configPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
-	
-	// For now, we'll create a simple TOML representation
-	// In a full implementation, you'd use a TOML encoder
-	content := fmt.Sprintf(`[paths]
-student_files_folder = "%s"
-master_sheet_path = "%s"
-output_folder = "%s"
-log_folder = "%s"
-backup_folder = "%s"
-
-[excel_settings]
-student_worksheet_name = "%s"
-master_worksheet_name = "%s"
-student_id_cell = "%s"
-mark_cells = [%s]
-master_columns = [%s]
-
-[processing]
-max_concurrent_files = %d
-backup_enabled = %t
-skip_invalid_files = %t
-timeout_seconds = %d
-retry_attempts = %d
 
-[logging]
-level = "%s"
-console_output = %t
-file_output = %t
-max_file_size_mb = %d
-max_backup_files = %d
-max_age_days = %d
-`,
-		cfg.Paths.StudentFilesFolder,
-		cfg.Paths.MasterSheetPath,
-		cfg.Paths.OutputFolder,
-		cfg.Paths.LogFolder,
-		cfg.Paths.BackupFolder,
-		cfg.Excel.StudentWorksheetName,
-		cfg.Excel.MasterWorksheetName,
-		cfg.Excel.StudentIDCell,
-		formatStringArray(cfg.Excel.MarkCells),
-		formatStringArray(cfg.Excel.MasterColumns),
-		cfg.Processing.MaxConcurrentFiles,
-		cfg.Processing.BackupEnabled,
-		cfg.Processing.SkipInvalidFiles,
-		cfg.Processing.TimeoutSeconds,
-		cfg.Processing.RetryAttempts,
-		cfg.Logging.Level,
-		cfg.Logging.ConsoleOutput,
-		cfg.Logging.FileOutput,
-		cfg.Logging.MaxFileSizeMB,
-		cfg.Logging.MaxBackupFiles,
-		cfg.Logging.MaxAgeDays,
-	)
-	
-	return os.WriteFile(configPath, []byte(content), 0644)
-}
-
-// formatStringArray formats a string array for TOML
-func formatStringArray(arr []string) string {
-	if len(arr) == 0 {
-		return ""
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
 	}
-	
-	result := ""
-	for i, s := range arr {
-		if i > 0 {
-			result += ", "
-		}
-		result += fmt.Sprintf(`"%s"`, s)
-	}
-	return result
+
+	return os.WriteFile(configPath, buf.Bytes(), 0644)
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"fyne.io/fyne/v2/test"
+	"github.com/BurntSushi/toml"
 
 	"mark-master-sheet/internal/config"
 )
@@ -221,6 +222,7 @@ func TestApplyConfigToUI(t *testing.T) {
 			StudentIDCell:        "A1",
 			MarkCells:            []string{"B1", "B2"},
 			MasterColumns:        []string{"X", "Y"},
+			HighlightUpdates:     true,
 		},
 		Processing: config.ProcessingConfig{
 			MaxConcurrentFiles: 15,
@@ -247,6 +249,9 @@ func TestApplyConfigToUI(t *testing.T) {
 	if app.enableBackupCheck.Checked != cfg.Processing.BackupEnabled {
 		t.Errorf("applyConfigToUI() backup enabled = %v, want %v", app.enableBackupCheck.Checked, cfg.Processing.BackupEnabled)
 	}
+	if !app.highlightCheck.Checked || app.commentCheck.Checked {
+		t.Errorf("applyConfigToUI() highlight/comment = %v/%v, want true/false", app.highlightCheck.Checked, app.commentCheck.Checked)
+	}
 
 	// Test mark mappings
 	if len(app.markMappings) != len(cfg.Excel.MarkCells) {
@@ -334,37 +339,194 @@ func TestSaveConfigToPath(t *testing.T) {
 	}
 }
 
-// TestFormatStringArray tests string array formatting for TOML
-func TestFormatStringArray(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    []string
-		expected string
-	}{
-		{
-			name:     "empty array",
-			input:    []string{},
-			expected: "",
-		},
-		{
-			name:     "single item",
-			input:    []string{"item1"},
-			expected: `"item1"`,
+// TestSaveConfigToPathEscapes tests that quotes, backslashes and control characters survive a reload
+func TestSaveConfigToPathEscapes(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	cfg := &config.Config{
+		Paths: config.PathsConfig{
+			MasterSheetPath:    `C:\Marks\master "final".xlsx`,
+			StudentFilesFolder: "./students",
+			OutputFolder:       "./output",
 		},
-		{
-			name:     "multiple items",
-			input:    []string{"item1", "item2", "item3"},
-			expected: `"item1", "item2", "item3"`,
+		Excel: config.ExcelConfig{
+			MarkCells:          []string{"C6"},
+			MasterColumns:      []string{"I"},
+			AuditSheet:         true,
+			AuditSheetPassword: "p\"a\\ss\tword\u0001",
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatStringArray(tt.input)
-			if result != tt.expected {
-				t.Errorf("formatStringArray() = %v, want %v", result, tt.expected)
-			}
-		})
+	configPath := filepath.Join(t.TempDir(), "escapes.toml")
+	if err := app.saveConfigToPath(cfg, configPath); err != nil {
+		t.Fatalf("saveConfigToPath() unexpected error: %v", err)
+	}
+
+	var loaded config.Config
+	if _, err := toml.DecodeFile(configPath, &loaded); err != nil {
+		t.Fatalf("saved config does not parse: %v", err)
+	}
+	if loaded.Excel.AuditSheetPassword != cfg.Excel.AuditSheetPassword {
+		t.Errorf("audit_sheet_password = %q, want %q", loaded.Excel.AuditSheetPassword, cfg.Excel.AuditSheetPassword)
+	}
+	if loaded.Paths.MasterSheetPath != cfg.Paths.MasterSheetPath {
+		t.Errorf("master_sheet_path = %q, want %q", loaded.Paths.MasterSheetPath, cfg.Paths.MasterSheetPath)
+	}
+}
+
+// TestSaveConfigKeepsHiddenSettings tests that settings without a widget survive loading, saving and reloading
+func TestSaveConfigKeepsHiddenSettings(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "original.toml")
+	content := `[paths]
+student_files_folder = "./students"
+master_sheet_path = "./master.xlsx"
+output_folder = "./output"
+log_folder = "./logs"
+backup_folder = "./backups"
+
+[excel_settings]
+student_worksheet_name = "Grading Sheet"
+master_worksheet_name = "001"
+student_id_cell = "B2"
+mark_cells = ["C6", "C7"]
+master_columns = ["I", "J"]
+
+[excel_settings.rounding]
+mode = "half_up"
+precision = 1
+
+[processing]
+max_concurrent_files = 4
+timeout_seconds = 300
+retry_attempts = 5
+on_cancel = "commit"
+file_timeout_seconds = 12.5
+
+[discovery]
+exclude = ["drafts"]
+
+[watch]
+debounce_seconds = 9
+
+[backup]
+keep_last = 3
+max_age_days = 14
+max_total_size_mb = 250
+
+[logging]
+level = "DEBUG"
+`
+	if err := os.WriteFile(original, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	loaded, err := config.LoadConfig(original)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	app.config = loaded
+	app.applyConfigToUI(loaded)
+	app.maxConcurrentEntry.SetText("6")
+
+	cfg, err := app.buildConfigFromUI()
+	if err != nil {
+		t.Fatalf("buildConfigFromUI() error = %v", err)
+	}
+	saved := filepath.Join(tempDir, "saved.toml")
+	if err := app.saveConfigToPath(cfg, saved); err != nil {
+		t.Fatalf("saveConfigToPath() error = %v", err)
+	}
+	reloaded, err := config.LoadConfig(saved)
+	if err != nil {
+		t.Fatalf("LoadConfig() of the saved config error = %v", err)
+	}
+
+	if reloaded.Processing.MaxConcurrentFiles != 6 {
+		t.Errorf("max_concurrent_files = %d, want the UI value 6", reloaded.Processing.MaxConcurrentFiles)
+	}
+	if reloaded.Backup != loaded.Backup {
+		t.Errorf("backup = %+v, want %+v", reloaded.Backup, loaded.Backup)
+	}
+	if reloaded.Processing.RetryAttempts != 5 || reloaded.Processing.OnCancel != "commit" || reloaded.Processing.FileTimeoutSeconds != 12.5 {
+		t.Errorf("processing = %+v, want the loaded retry, cancel and timeout settings", reloaded.Processing)
+	}
+	if len(reloaded.Discovery.Exclude) != 1 || reloaded.Watch.DebounceSeconds != 9 || reloaded.Logging.Level != "DEBUG" {
+		t.Errorf("discovery, watch and logging = %+v, %+v, %+v", reloaded.Discovery, reloaded.Watch, reloaded.Logging)
+	}
+	if rule := reloaded.Excel.Rounding.For("I"); rule.Mode != "half_up" || *rule.Precision != 1 {
+		t.Errorf("rounding = %+v, want half_up to 1 decimal", rule)
+	}
+}
+
+// TestSaveConfigKeepsAuditPassword tests that the audit sheet and highlight settings survive loading, saving and reloading
+func TestSaveConfigKeepsAuditPassword(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	tempDir := t.TempDir()
+	original := filepath.Join(tempDir, "original.toml")
+	content := `[paths]
+student_files_folder = "./students"
+master_sheet_path = "./master.xlsx"
+output_folder = "./output"
+
+[excel_settings]
+mark_cells = ["C6"]
+master_columns = ["I"]
+highlight_updates = true
+highlight_color = "#C6EFCE"
+audit_sheet = true
+audit_sheet_name = "Changes \"2025\""
+audit_sheet_password = "p\"a\\ss\tword"
+
+[processing]
+max_concurrent_files = 2
+timeout_seconds = 300
+`
+	if err := os.WriteFile(original, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	loaded, err := config.LoadConfig(original)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	app.config = loaded
+	app.applyConfigToUI(loaded)
+
+	cfg, err := app.buildConfigFromUI()
+	if err != nil {
+		t.Fatalf("buildConfigFromUI() error = %v", err)
+	}
+	saved := filepath.Join(tempDir, "saved.toml")
+	if err := app.saveConfigToPath(cfg, saved); err != nil {
+		t.Fatalf("saveConfigToPath() error = %v", err)
+	}
+	reloaded, err := config.LoadConfig(saved)
+	if err != nil {
+		t.Fatalf("LoadConfig() of the saved config error = %v", err)
+	}
+
+	if got := reloaded.Excel.AuditSheetPassword; got != "p\"a\\ss\tword" {
+		t.Errorf("audit_sheet_password = %q, want %q", got, "p\"a\\ss\tword")
+	}
+	if got := reloaded.Excel.AuditSheetName; got != `Changes "2025"` {
+		t.Errorf("audit_sheet_name = %q, want %q", got, `Changes "2025"`)
+	}
+	if got := reloaded.Excel.HighlightColor; got != "#C6EFCE" {
+		t.Errorf("highlight_color = %q, want %q", got, "#C6EFCE")
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"mark-master-sheet/internal/config"
 	"mark-master-sheet/internal/logger"
 	"mark-master-sheet/internal/processor"
+	"mark-master-sheet/pkg/models"
 )
 
 // startProcessing begins the file processing operation
@@ -45,6 +46,7 @@ func (a *App) startProcessing(dryRun bool) {
 	
 	// Initialize processor
 	proc := processor.NewProcessor(cfg, logger)
+	proc.SetEventHandler(a.handleProcessingEvent)
 	
 	// Set up processing state
 	a.isProcessing = true
@@ -102,6 +104,11 @@ func (a *App) runProcessing(dryRun bool) {
 		if a.processingContext.Err() == context.Canceled {
 			a.updateStatus("Processing cancelled")
 			a.appendLog(fmt.Sprintf("Processing cancelled after %v\n", duration))
+			if summary != nil && summary.StudentsUpdated > 0 {
+				a.appendLog(fmt.Sprintf("Results of the files read before cancelling were written: %d students updated\n", summary.StudentsUpdated))
+			} else if !dryRun {
+				a.appendLog("Master sheet left unchanged\n")
+			}
 		} else {
 			a.updateStatus("Processing failed")
 			a.appendLog(fmt.Sprintf("Processing failed: %v\n", err))
@@ -178,11 +185,34 @@ func (a *App) displayProcessingSummary(summary interface{}, dryRun bool, duratio
 	// Type assertion to access summary fields
 	// Note: This would need to be adjusted based on the actual summary type
 	a.appendLog(fmt.Sprintf("Duration: %v\n", duration))
+	if s, ok := summary.(*models.ProcessingSummary); ok && len(s.Duplicates) > 0 {
+		a.appendLog(fmt.Sprintf("\nPossible duplicate submissions (%d), check before releasing marks:\n", len(s.Duplicates)))
+		for _, group := range s.Duplicates {
+			a.appendLog(fmt.Sprintf("  - %s\n", group))
+			for _, file := range group.Files {
+				a.appendLog(fmt.Sprintf("      %s\n", file))
+			}
+		}
+	}
 	a.appendLog("Processing completed.\n")
 	a.appendLog("Check the logs folder for detailed processing information.\n")
 	a.appendLog("========================\n\n")
 }
 
+// handleProcessingEvent reflects the progress events of a run in the progress bar, status and log
+func (a *App) handleProcessingEvent(event processor.Event) {
+	switch event.Type {
+	case processor.EventStarted:
+		a.appendLog(fmt.Sprintf("Found %d files to process\n", event.Total))
+	case processor.EventBackupCreated:
+		a.appendLog(fmt.Sprintf("Backup created: %s\n", event.Path))
+	case processor.EventFileDone:
+		a.updateProgress(event.Done, event.Total, event.Path)
+	case processor.EventWriting:
+		a.updateStatus("Writing marks to the master sheet...")
+	}
+}
+
 // Additional helper methods for UI updates during processing
 func (a *App) updateProgress(current, total int, currentFile string) {
 	if total > 0 {
@@ -11,6 +11,7 @@ import (
 	"fyne.io/fyne/v2/test"
 
 	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/processor"
 )
 
 // TestValidatePaths tests path validation functionality
@@ -237,6 +238,29 @@ func TestUpdateProgress(t *testing.T) {
 	}
 }
 
+// TestHandleProcessingEvent tests that processor events drive the progress bar
+func TestHandleProcessingEvent(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	app := NewApp()
+	app.setupUI()
+
+	app.handleProcessingEvent(processor.Event{Type: processor.EventStarted, Total: 4})
+	app.handleProcessingEvent(processor.Event{Type: processor.EventFileDone, Path: "student1.xlsx", Done: 2, Total: 4})
+	if app.progressBar.Value != 0.5 {
+		t.Errorf("Progress bar value = %v, want 0.5", app.progressBar.Value)
+	}
+	if !strings.Contains(app.logOutput.Text, "student1.xlsx") {
+		t.Error("Log should name the finished file")
+	}
+
+	app.handleProcessingEvent(processor.Event{Type: processor.EventWriting, Done: 4, Total: 4})
+	if !strings.Contains(app.statusLabel.Text, "master sheet") {
+		t.Errorf("Status = %q, want the write phase", app.statusLabel.Text)
+	}
+}
+
 // TestLogMethods tests different log level methods
 func TestLogMethods(t *testing.T) {
 	testApp := test.NewApp()
@@ -0,0 +1,194 @@
+// Package journal records the cell writes made to the master sheet by each run of the
+// Mark Master Sheet Consolidator, so that a run can be undone cell by cell.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirName is the name of the journal directory inside the output folder
+const DirName = "journals"
+
+// Value kinds recorded for the previous content of a cell
+const (
+	KindEmpty  = "empty"
+	KindNumber = "number"
+	KindText   = "text"
+)
+
+// Entry records a single cell write
+type Entry struct {
+	Sheet      string `json:"sheet"`
+	Cell       string `json:"cell"`
+	Row        int    `json:"row"`
+	Column     string `json:"column"`
+	StudentID  string `json:"student_id,omitempty"`
+	SourceFile string `json:"source_file,omitempty"`
+	OldValue   string `json:"old_value"`
+	OldKind    string `json:"old_kind"`
+	OldFormula string `json:"old_formula,omitempty"`
+	NewValue   string `json:"new_value"`
+	NewFormula string `json:"new_formula,omitempty"`
+	Highlight  string `json:"highlight,omitempty"` // fill color applied to the cell, if any
+
+	// What the run meant to write, kept apart from the read-back NewValue and NewFormula
+	// so that verification compares the saved file against the intent
+	IntendedValue   string `json:"intended_value,omitempty"`
+	IntendedFormula string `json:"intended_formula,omitempty"`
+}
+
+// AppendedRow records a row added to the master sheet for a student missing from it
+type AppendedRow struct {
+	Sheet      string `json:"sheet"`
+	Row        int    `json:"row"`
+	StudentID  string `json:"student_id"`
+	SourceFile string `json:"source_file,omitempty"`
+}
+
+// Journal lists the cell writes made by one run
+type Journal struct {
+	RunID           string        `json:"run_id"`
+	MasterSheetPath string        `json:"master_sheet_path"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UndoneAt        *time.Time    `json:"undone_at,omitempty"`
+	Entries         []Entry       `json:"entries"`
+	AppendedRows    []AppendedRow `json:"appended_rows,omitempty"`
+}
+
+// New creates an empty journal for a run
+func New(runID, masterSheetPath string) *Journal {
+	return &Journal{
+		RunID:           runID,
+		MasterSheetPath: masterSheetPath,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// Record appends a cell write to the journal
+func (j *Journal) Record(entry Entry) {
+	j.Entries = append(j.Entries, entry)
+}
+
+// RecordAppendedRow records a row added to the master sheet, so that undo can remove it
+func (j *Journal) RecordAppendedRow(row AppendedRow) {
+	j.AppendedRows = append(j.AppendedRows, row)
+}
+
+// Dir returns the journal directory for an output folder
+func Dir(outputFolder string) string {
+	return filepath.Join(outputFolder, DirName)
+}
+
+// Path returns the file path of the journal for a run
+func Path(dir, runID string) string {
+	return filepath.Join(dir, runID+".json")
+}
+
+// Save writes the journal to the directory, replacing any previous version atomically
+func (j *Journal) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode journal: %w", err)
+	}
+
+	path := Path(dir, j.RunID)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return "", fmt.Errorf("failed to replace journal: %w", err)
+	}
+
+	return path, nil
+}
+
+// Load reads a journal file
+func Load(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+	}
+
+	return &j, nil
+}
+
+// List returns the journals in the directory, newest first.
+// A missing directory is returned as an empty list.
+func List(dir string) ([]*Journal, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		j, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		journals = append(journals, j)
+	}
+
+	sort.SliceStable(journals, func(a, b int) bool {
+		return journals[a].CreatedAt.After(journals[b].CreatedAt)
+	})
+
+	return journals, nil
+}
+
+// Latest returns the newest journal for the master sheet that has not been undone
+func Latest(dir, masterSheetPath string) (*Journal, error) {
+	journals, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, j := range journals {
+		if j.UndoneAt == nil && SameFile(j.MasterSheetPath, masterSheetPath) {
+			return j, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no run to undo for %s", masterSheetPath)
+}
+
+// Find returns the journal for a run ID
+func Find(dir, runID string) (*Journal, error) {
+	path := Path(dir, runID)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no journal found for run %s", runID)
+	}
+	return Load(path)
+}
+
+// SameFile compares two file paths after making them absolute
+func SameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
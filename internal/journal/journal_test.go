@@ -0,0 +1,68 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSaveAndLoad tests writing and reading a journal
+func TestSaveAndLoad(t *testing.T) {
+	dir := Dir(t.TempDir())
+
+	j := New("run-1", "master.xlsx")
+	j.Record(Entry{Sheet: "001", Cell: "I2", Row: 2, Column: "I", OldKind: KindEmpty, NewValue: "85.5"})
+
+	path, err := j.Save(dir)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if path != Path(dir, "run-1") {
+		t.Errorf("Save() path = %s, want %s", path, Path(dir, "run-1"))
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.RunID != "run-1" || len(loaded.Entries) != 1 || loaded.Entries[0].NewValue != "85.5" {
+		t.Errorf("Load() = %+v, want the saved journal", loaded)
+	}
+
+	if _, err := Find(dir, "missing"); err == nil {
+		t.Error("Find() expected error for unknown run")
+	}
+}
+
+// TestLatest tests selecting the newest journal that has not been undone
+func TestLatest(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "master.xlsx")
+	now := time.Now()
+
+	older := New("run-old", master)
+	older.CreatedAt = now.Add(-2 * time.Hour)
+	undone := New("run-undone", master)
+	undone.CreatedAt = now.Add(-time.Hour)
+	undone.UndoneAt = &now
+	other := New("run-other", filepath.Join(dir, "other.xlsx"))
+	other.CreatedAt = now
+
+	for _, j := range []*Journal{older, undone, other} {
+		if _, err := j.Save(dir); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	latest, err := Latest(dir, master)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if latest.RunID != "run-old" {
+		t.Errorf("Latest() = %s, want run-old", latest.RunID)
+	}
+
+	if _, err := Latest(filepath.Join(dir, "missing"), master); err == nil {
+		t.Error("Latest() expected error when there are no journals")
+	}
+}
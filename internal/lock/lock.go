@@ -0,0 +1,257 @@
+// Package lock provides an advisory lock file next to the master sheet so that only one
+// run of the Mark Master Sheet Consolidator writes to a workbook at a time.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"mark-master-sheet/internal/fileutil"
+)
+
+// Suffix is appended to the master sheet path to name its lock file
+const Suffix = ".lock"
+
+// DefaultStaleAfter is the age after which a lock held by another host is considered abandoned
+const DefaultStaleAfter = 12 * time.Hour
+
+// ErrLocked is returned when another run holds the master sheet lock
+var ErrLocked = errors.New("master sheet is locked by another run")
+
+// ErrOpenInExcel is returned when Excel's owner file shows the workbook is open
+var ErrOpenInExcel = errors.New("master sheet is open in Excel")
+
+// Info describes the holder of a lock
+type Info struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	User      string    `json:"user"`
+	StartedAt time.Time `json:"started_at"`
+	Command   string    `json:"command,omitempty"`
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("%s on %s (pid %d, %s) since %s",
+		i.User, i.Host, i.PID, i.Command, i.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// Lock is a held master sheet lock
+type Lock struct {
+	Path string
+	Info Info
+}
+
+// Path returns the lock file path for a master sheet
+func Path(masterSheetPath string) string {
+	return masterSheetPath + Suffix
+}
+
+// Acquire takes the lock for a master sheet. It refuses while the workbook is open in
+// Excel or another live run holds the lock; stale locks are replaced. A staleAfter of
+// zero uses DefaultStaleAfter.
+func Acquire(masterSheetPath, command string, staleAfter time.Duration) (*Lock, error) {
+	if owner := ExcelOwnerFile(masterSheetPath); owner != "" {
+		return nil, fmt.Errorf("%w (owner file %s); close the workbook and try again", ErrOpenInExcel, owner)
+	}
+
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+
+	host, _ := os.Hostname()
+	l := &Lock{
+		Path: Path(masterSheetPath),
+		Info: Info{
+			PID:       os.Getpid(),
+			Host:      host,
+			User:      fileutil.CurrentUser(),
+			StartedAt: time.Now(),
+			Command:   command,
+		},
+	}
+
+	data, err := json.MarshalIndent(l.Info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	// A stale lock is removed once and the lock taken again
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, writeErr := file.Write(data)
+			closeErr := file.Close()
+			if writeErr != nil || closeErr != nil {
+				os.Remove(l.Path)
+				return nil, fmt.Errorf("failed to write lock file %s: %w", l.Path, errors.Join(writeErr, closeErr))
+			}
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", l.Path, err)
+		}
+
+		holder, readErr := Read(masterSheetPath)
+		if readErr == nil && !IsStale(*holder, staleAfter) {
+			return nil, fmt.Errorf("%w: %s (remove %s if that run is gone)", ErrLocked, holder, l.Path)
+		}
+		if readErr != nil && recentlyModified(l.Path) {
+			// Another run may be between creating and writing its lock
+			return nil, fmt.Errorf("%w: lock file %s is being written", ErrLocked, l.Path)
+		}
+		if readErr != nil {
+			holder = nil
+		}
+		if err := removeStale(l.Path, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: lock file %s keeps reappearing", ErrLocked, l.Path)
+}
+
+// removeStale deletes a stale lock file, or an unreadable one when stale is nil, while holding
+// the takeover file next to it. Runs that found the same stale lock take turns, and each only
+// removes the lock file while it still names the stale holder, so a lock taken by the run that
+// went first is never removed. The caller then takes the lock with an exclusive create.
+func removeStale(path string, stale *Info) error {
+	takeover := path + ".takeover"
+	file, err := os.OpenFile(takeover, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil && os.IsExist(err) && !recentlyModified(takeover) {
+		// Left behind by a run that stopped in the middle of a takeover
+		os.Remove(takeover)
+		file, err = os.OpenFile(takeover, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: another run is replacing the stale lock file %s", ErrLocked, path)
+		}
+		return fmt.Errorf("failed to create takeover file %s: %w", takeover, err)
+	}
+	file.Close()
+	defer os.Remove(takeover)
+
+	current, err := readInfo(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case stale == nil && (err == nil || recentlyModified(path)), stale != nil && (err != nil || !current.sameHolder(*stale)):
+		return nil // Replaced since it was found stale; the caller looks at it again
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale lock file %s: %w", path, err)
+	}
+	return nil
+}
+
+// sameHolder reports whether two lock infos describe the same run
+func (i Info) sameHolder(other Info) bool {
+	return i.PID == other.PID && i.Host == other.Host && i.StartedAt.Equal(other.StartedAt)
+}
+
+// Release removes the lock file if it is still held by this lock
+func (l *Lock) Release() error {
+	holder, err := readInfo(l.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !holder.sameHolder(l.Info) {
+		return fmt.Errorf("lock file %s was taken over by %s", l.Path, holder)
+	}
+	if err := os.Remove(l.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// Read returns the holder of a master sheet's lock
+func Read(masterSheetPath string) (*Info, error) {
+	return readInfo(Path(masterSheetPath))
+}
+
+// Remove deletes a master sheet's lock file regardless of its holder
+func Remove(masterSheetPath string) error {
+	if err := os.Remove(Path(masterSheetPath)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("master sheet is not locked")
+		}
+		return fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	return nil
+}
+
+// IsStale reports whether a lock was abandoned: its process is gone on this host, or it
+// was taken on another host, where the process cannot be checked, more than staleAfter ago
+func IsStale(info Info, staleAfter time.Duration) bool {
+	host, _ := os.Hostname()
+	if info.Host == host {
+		return !processAlive(info.PID)
+	}
+	return time.Since(info.StartedAt) > staleAfter
+}
+
+// ExcelOwnerFile returns the path of the "~$" owner file Excel creates next to an open
+// workbook, or an empty string if there is none
+func ExcelOwnerFile(masterSheetPath string) string {
+	dir, base := filepath.Split(masterSheetPath)
+	candidates := []string{"~$" + base}
+	if len(base) > 2 {
+		// Some Office versions replace the first two characters of the name
+		candidates = append(candidates, "~$"+base[2:])
+	}
+
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// unreadableGrace is how long an unreadable lock file is respected before it is treated as stale
+const unreadableGrace = time.Minute
+
+// recentlyModified reports whether a file changed within unreadableGrace
+func recentlyModified(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < unreadableGrace
+}
+
+// readInfo decodes a lock file
+func readInfo(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+	return &info, nil
+}
+
+// processAlive reports whether a process with the PID exists on this host
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true // FindProcess fails on Windows when the process does not exist
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
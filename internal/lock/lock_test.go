@@ -0,0 +1,156 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeLock writes a lock file held by the given holder
+func writeLock(t *testing.T, master string, info Info) {
+	t.Helper()
+	data, _ := json.Marshal(info)
+	if err := os.WriteFile(Path(master), data, 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+}
+
+// TestAcquireRelease tests taking, refusing and releasing the lock
+func TestAcquireRelease(t *testing.T) {
+	master := filepath.Join(t.TempDir(), "master.xlsx")
+
+	first, err := Acquire(master, "run 1", 0)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if first.Info.PID != os.Getpid() || first.Info.User == "" {
+		t.Errorf("lock info = %+v", first.Info)
+	}
+
+	if _, err := Acquire(master, "run 2", 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Acquire() error = %v, want ErrLocked", err)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(Path(master)); !os.IsNotExist(err) {
+		t.Error("lock file still exists after Release()")
+	}
+
+	second, err := Acquire(master, "run 2", 0)
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	second.Release()
+}
+
+// TestAcquireStaleLocks tests which leftover locks are replaced
+func TestAcquireStaleLocks(t *testing.T) {
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name    string
+		holder  Info
+		wantErr bool
+	}{
+		{"dead process on this host", Info{PID: 999999999, Host: host, StartedAt: time.Now()}, false},
+		{"old lock from another host", Info{PID: 1, Host: "elsewhere", StartedAt: time.Now().Add(-13 * time.Hour)}, false},
+		{"recent lock from another host", Info{PID: 1, Host: "elsewhere", StartedAt: time.Now()}, true},
+		{"live process on this host", Info{PID: os.Getpid(), Host: host, StartedAt: time.Now()}, true},
+		{"long running process on this host", Info{PID: os.Getpid(), Host: host, StartedAt: time.Now().Add(-13 * time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			master := filepath.Join(t.TempDir(), "master.xlsx")
+			writeLock(t, master, tt.holder)
+
+			l, err := Acquire(master, "run", 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Acquire() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if l != nil {
+				l.Release()
+			}
+		})
+	}
+}
+
+// TestAcquireRefusesOpenWorkbook tests detecting Excel's owner file
+func TestAcquireRefusesOpenWorkbook(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "Results.xlsx")
+	os.WriteFile(filepath.Join(dir, "~$Results.xlsx"), []byte("owner"), 0644)
+
+	if _, err := Acquire(master, "run", 0); !errors.Is(err, ErrOpenInExcel) {
+		t.Errorf("Acquire() error = %v, want ErrOpenInExcel", err)
+	}
+	if _, err := os.Stat(Path(master)); !os.IsNotExist(err) {
+		t.Error("lock file created although the workbook is open")
+	}
+}
+
+// TestReleaseKeepsTakenOverLock tests that a lock replaced by another run is left alone
+func TestReleaseKeepsTakenOverLock(t *testing.T) {
+	master := filepath.Join(t.TempDir(), "master.xlsx")
+	l, err := Acquire(master, "run", 0)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	writeLock(t, master, Info{PID: 1, Host: "elsewhere", StartedAt: time.Now()})
+	if err := l.Release(); err == nil {
+		t.Error("Release() expected error for a lock taken over by another run")
+	}
+	if _, err := os.Stat(Path(master)); err != nil {
+		t.Errorf("taken over lock was removed: %v", err)
+	}
+}
+
+// TestAcquireStaleLockConcurrently tests that only one of several runs finding the same stale lock takes it
+func TestAcquireStaleLockConcurrently(t *testing.T) {
+	host, _ := os.Hostname()
+
+	for round := 0; round < 200; round++ {
+		master := filepath.Join(t.TempDir(), "master.xlsx")
+		writeLock(t, master, Info{PID: 999999999, Host: host, StartedAt: time.Now()})
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			held  []*Lock
+			start = make(chan struct{})
+		)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				l, err := Acquire(master, "run", 0)
+				if err != nil {
+					if !errors.Is(err, ErrLocked) {
+						t.Errorf("Acquire() error = %v, want ErrLocked", err)
+					}
+					return
+				}
+				mu.Lock()
+				held = append(held, l)
+				mu.Unlock()
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if len(held) != 1 {
+			t.Fatalf("round %d: %d runs hold the lock, want 1", round, len(held))
+		}
+		if err := held[0].Release(); err != nil {
+			t.Fatalf("round %d: Release() error = %v", round, err)
+		}
+	}
+}
@@ -74,6 +74,25 @@ func NewLogger(cfg *config.LoggingConfig, logDir string) (*Logger, error) {
 	}, nil
 }
 
+// WithRunLog returns a logger writing to the same outputs and also to the given file,
+// with a function closing the file
+func (l *Logger) WithRunLog(path string) (*Logger, func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create run log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open run log: %w", err)
+	}
+
+	runLogger := logrus.New()
+	runLogger.SetLevel(l.GetLevel())
+	runLogger.SetFormatter(l.Formatter)
+	runLogger.SetOutput(io.MultiWriter(l.Out, file))
+
+	return &Logger{Logger: runLogger, config: l.config}, file.Close, nil
+}
+
 // LogProcessingStart logs the start of processing
 func (l *Logger) LogProcessingStart(totalFiles int) {
 	l.WithFields(logrus.Fields{
@@ -131,6 +150,14 @@ func (l *Logger) LogBackupCreated(originalPath, backupPath string) {
 	}).Info("Backup created successfully")
 }
 
+// LogBackupPruned logs removal of a backup by a retention rule
+func (l *Logger) LogBackupPruned(backupPath, reason string) {
+	l.WithFields(logrus.Fields{
+		"backup_path": backupPath,
+		"reason":      reason,
+	}).Info("Old backup removed")
+}
+
 // LogValidationError logs validation errors
 func (l *Logger) LogValidationError(filePath, field, value, message string) {
 	l.WithFields(logrus.Fields{
@@ -2,6 +2,8 @@ package logger
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -93,6 +95,34 @@ func TestNewLogger(t *testing.T) {
 	}
 }
 
+// TestWithRunLog tests copying log entries to a run log file
+func TestWithRunLog(t *testing.T) {
+	tempDir := t.TempDir()
+	base, err := NewLogger(&config.LoggingConfig{Level: "INFO"}, tempDir)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	path := filepath.Join(tempDir, "runs", "run.log")
+	runLog, closeLog, err := base.WithRunLog(path)
+	if err != nil {
+		t.Fatalf("WithRunLog() error = %v", err)
+	}
+	runLog.Info("run entry")
+	runLog.Debug("below level")
+	if err := closeLog(); err != nil {
+		t.Fatalf("close error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read run log: %v", err)
+	}
+	if !strings.Contains(string(content), "run entry") || strings.Contains(string(content), "below level") {
+		t.Errorf("run log = %q, want the info entry only", content)
+	}
+}
+
 // TestLogLevels tests different log levels
 func TestLogLevels(t *testing.T) {
 	tempDir := t.TempDir()
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestDiscoverFilesCancelled tests that discovery stops with the context error
+func TestDiscoverFilesCancelled(t *testing.T) {
+	root := t.TempDir()
+	createDiscoveryTree(t, root, "a.xlsx", "sub/b.xlsx")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, _, err := discoverFiles(ctx, root, config.DiscoveryConfig{}, func(string, error) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("discoverFiles() error = %v, want context.Canceled", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("discoverFiles() files = %v, want none", files)
+	}
+}
+
+// TestProcessFilesConcurrentlyCancelled tests that no queued file is read once the context is cancelled
+func TestProcessFilesConcurrentlyCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+	studentDir := createTestStudentFiles(t, tempDir)
+	files, _, err := discoverFiles(context.Background(), studentDir, config.DiscoveryConfig{}, func(string, error) {})
+	if err != nil {
+		t.Fatalf("discoverFiles() error = %v", err)
+	}
+
+	processor := NewProcessor(createTestConfig(tempDir), createTestLogger(t, tempDir))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	students, summary := processor.processFilesConcurrently(ctx, files)
+	if len(students) != 0 || summary.SuccessfulFiles != 0 {
+		t.Errorf("read %d students (%d successful files), want none", len(students), summary.SuccessfulFiles)
+	}
+	if summary.CancelledFiles != len(files) {
+		t.Errorf("CancelledFiles = %d, want %d", summary.CancelledFiles, len(files))
+	}
+}
+
+// TestProcessFileWithRetriesCancelledDuringBackoff tests that the retry backoff ends when the context is cancelled
+func TestProcessFileWithRetriesCancelledDuringBackoff(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Processing.RetryAttempts = 3
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	processor.readFile = func(path string) (*models.StudentData, error) {
+		return nil, transientError(path)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := processor.processFileWithRetries(ctx, filepath.Join(tempDir, "missing.xlsx"))
+	if !errors.Is(result.Error, context.Canceled) {
+		t.Errorf("processFileWithRetries() error = %v, want context.Canceled", result.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("processFileWithRetries() took %v after cancellation, want the backoff cut short", elapsed)
+	}
+}
+
+// TestProcessFilesCancelPolicy tests which results of a cancelled run reach the master sheet
+func TestProcessFilesCancelPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		onCancel    string
+		wantUpdated int
+	}{
+		{name: "default discards", onCancel: "", wantUpdated: 0},
+		{name: "discard", onCancel: config.OnCancelDiscard, wantUpdated: 0},
+		{name: "commit", onCancel: config.OnCancelCommit, wantUpdated: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := createTestConfig(tempDir)
+			cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+			cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+			cfg.Processing.OnCancel = tt.onCancel
+
+			// A file that stays busy keeps the run in its retry backoff until it is cancelled
+			busy := filepath.Join(cfg.Paths.StudentFilesFolder, "busy.xlsx")
+			if err := os.WriteFile(busy, []byte("in use"), 0644); err != nil {
+				t.Fatalf("Failed to write busy file: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(300*time.Millisecond, cancel)
+
+			processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+			processor.readFile = func(path string) (*models.StudentData, error) {
+				if path == busy {
+					return nil, transientError(path)
+				}
+				return processor.reader.ReadStudentData(path)
+			}
+			summary, err := processor.ProcessFiles(ctx, false)
+			if err != context.Canceled {
+				t.Fatalf("ProcessFiles() error = %v, want context.Canceled", err)
+			}
+			if !summary.Cancelled || summary.CancelledFiles != 1 {
+				t.Errorf("Cancelled = %v, CancelledFiles = %d, want true and 1", summary.Cancelled, summary.CancelledFiles)
+			}
+			if summary.StudentsUpdated != tt.wantUpdated {
+				t.Errorf("StudentsUpdated = %d, want %d", summary.StudentsUpdated, tt.wantUpdated)
+			}
+
+			f, err := excelize.OpenFile(cfg.Paths.MasterSheetPath)
+			if err != nil {
+				t.Fatalf("Failed to open master: %v", err)
+			}
+			defer f.Close()
+			mark, _ := f.GetCellValue("001", "I2")
+			if written := mark != ""; written != (tt.wantUpdated > 0) {
+				t.Errorf("master I2 = %q, written %v, want written %v", mark, written, tt.wantUpdated > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package processor provides the main processing logic for the Mark Master Sheet Consolidator.
+// This file contains checkpointing the files read so that an interrupted run can be resumed.
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"mark-master-sheet/internal/checkpoint"
+	"mark-master-sheet/pkg/models"
+)
+
+// SetResume makes the next runs continue the run interrupted before writing the master sheet,
+// reusing the student data it checkpointed instead of reading those files again
+func (p *Processor) SetResume(resume bool) {
+	p.resume = resume
+}
+
+// openCheckpoint starts recording the files read by a production run. When resuming, it
+// reopens the checkpoint of the interrupted run and refuses to continue if the configuration
+// or the master sheet changed since. Failing to start a new checkpoint only costs the ability
+// to resume, so it is reported as a warning.
+func (p *Processor) openCheckpoint(summary *models.ProcessingSummary) error {
+	path := checkpoint.Path(p.config.Paths.OutputFolder)
+	configHash, err := checkpoint.ConfigHash(p.config)
+	var masterHash string
+	if err == nil {
+		masterHash, err = checkpoint.MasterHash(p.config.Paths.MasterSheetPath)
+	}
+
+	if p.resume {
+		var cp *checkpoint.Checkpoint
+		if err == nil {
+			cp, err = checkpoint.Resume(path, configHash, masterHash)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resume: %w", err)
+		}
+		p.checkpoint = cp
+		p.logger.WithField("resumed_run_id", cp.RunID).WithField("files", cp.Len()).Info("Resuming interrupted run")
+		return nil
+	}
+
+	if err == nil {
+		if previous, loadErr := checkpoint.Load(path); loadErr == nil && previous != nil {
+			p.logger.WithField("interrupted_run_id", previous.RunID).Warn("Starting over, discarding the checkpoint of an interrupted run")
+		}
+		p.checkpoint, err = checkpoint.Create(path, checkpoint.Header{
+			RunID:           summary.RunID,
+			StartedAt:       summary.StartTime,
+			ConfigHash:      configHash,
+			MasterSheetPath: p.config.Paths.MasterSheetPath,
+			MasterHash:      masterHash,
+		})
+	}
+	if err != nil {
+		p.logger.WithError(err).Error("Failed to start checkpoint")
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("Checkpoint not written, this run cannot be resumed if interrupted: %v", err))
+	}
+	return nil
+}
+
+// readOrResume returns the checkpointed result of a file read before the interruption,
+// or reads the file
+func (p *Processor) readOrResume(ctx context.Context, filePath string) *models.ProcessingResult {
+	if data := p.checkpoint.Completed(filePath); data != nil {
+		return &models.ProcessingResult{
+			FilePath:    filePath,
+			Success:     true,
+			StudentData: data,
+			Resumed:     true,
+		}
+	}
+	return p.processFileWithRetries(ctx, filePath)
+}
+
+// checkpointResult records a file read successfully. After a failure the run carries on
+// without checkpointing, since the file would only be incomplete.
+func (p *Processor) checkpointResult(result *models.ProcessingResult, summary *models.ProcessingSummary) {
+	if !p.checkpoint.Recording() || result.Resumed {
+		return
+	}
+	if err := p.checkpoint.Record(result.StudentData); err != nil {
+		p.logger.WithError(err).Error("Failed to write checkpoint")
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("Checkpoint stopped, this run cannot be fully resumed if interrupted: %v", err))
+		p.checkpoint.Close()
+	}
+}
+
+// finishCheckpoint deletes the checkpoint of a run that got through its write phase
+func (p *Processor) finishCheckpoint() {
+	if err := p.checkpoint.Remove(); err != nil {
+		p.logger.WithError(err).Warn("Failed to remove checkpoint")
+	}
+	p.checkpoint = nil
+}
+
+// closeCheckpoint closes the checkpoint file, keeping it for a resume
+func (p *Processor) closeCheckpoint() {
+	if err := p.checkpoint.Close(); err != nil {
+		p.logger.WithError(err).Warn("Failed to close checkpoint")
+	}
+	p.checkpoint = nil
+}
@@ -0,0 +1,266 @@
+// Package processor provides the main processing logic for the Mark Master Sheet Consolidator.
+// This file contains the discovery of student files with exclude rules, depth and symlink limits.
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"mark-master-sheet/internal/archive"
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// defaultExcludes are always skipped: Office owner files, macOS archive folders and metadata files
+var defaultExcludes = []string{"~$*", "__MACOSX", ".DS_Store", "._*"}
+
+// discovery walks the student files folder collecting Excel files and skipped paths
+type discovery struct {
+	ctx     context.Context
+	root    string
+	config  config.DiscoveryConfig
+	files   []string
+	skipped []models.SkippedPath
+	visited map[string]bool
+	onError func(path string, err error)
+}
+
+// discoverFiles finds the Excel files under root allowed by the discovery settings.
+// It stops with the context error once ctx is cancelled.
+func discoverFiles(ctx context.Context, root string, cfg config.DiscoveryConfig, onError func(string, error)) ([]string, []models.SkippedPath, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to access %s: %w", root, err)
+	}
+
+	d := &discovery{ctx: ctx, root: root, config: cfg, visited: make(map[string]bool), onError: onError}
+
+	// The student files folder may itself be an archive, whose entries are matched by their
+	// path inside it just like the files of a folder
+	if !info.IsDir() {
+		if !archive.IsArchive(root) {
+			return nil, nil, fmt.Errorf("%s is neither a directory nor a ZIP archive", root)
+		}
+		if err := d.walkArchive(root, "", 1); err != nil {
+			return nil, nil, err
+		}
+		return d.files, d.skipped, nil
+	}
+
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		d.visited[real] = true
+	}
+	d.walk(root, "", 1)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return d.files, d.skipped, nil
+}
+
+// walk visits a directory at the given depth, where files in the root are at depth 1
+func (d *discovery) walk(dir, rel string, depth int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		d.onError(dir, err)
+		d.skip(dir, fmt.Sprintf("unreadable: %v", err))
+		return
+	}
+
+	for _, entry := range entries {
+		if d.ctx.Err() != nil {
+			return
+		}
+		fullPath := filepath.Join(dir, entry.Name())
+		relPath := path.Join(rel, entry.Name())
+
+		if reason := d.excluded(relPath); reason != "" {
+			d.skip(fullPath, reason)
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			policy := d.config.SymlinkPolicy()
+			if policy == config.SymlinksSkip {
+				d.skip(fullPath, "symbolic link")
+				continue
+			}
+			target, err := os.Stat(fullPath)
+			if err != nil {
+				d.skip(fullPath, "broken symbolic link")
+				continue
+			}
+			isDir = target.IsDir()
+			if isDir && policy != config.SymlinksFollow {
+				d.skip(fullPath, "symbolic link to a folder")
+				continue
+			}
+		}
+
+		if isDir {
+			if d.config.MaxDepth > 0 && depth >= d.config.MaxDepth {
+				d.skip(fullPath, fmt.Sprintf("deeper than max_depth %d", d.config.MaxDepth))
+				continue
+			}
+			real, err := filepath.EvalSymlinks(fullPath)
+			if err == nil {
+				if d.visited[real] {
+					d.skip(fullPath, "folder already visited (symbolic link loop)")
+					continue
+				}
+				d.visited[real] = true
+			}
+			d.walk(fullPath, relPath, depth+1)
+			continue
+		}
+
+		if archive.IsArchive(entry.Name()) {
+			if err := d.walkArchive(fullPath, relPath, depth); err != nil && d.ctx.Err() == nil {
+				d.onError(fullPath, err)
+				d.skip(fullPath, fmt.Sprintf("unreadable archive: %v", err))
+			}
+			continue
+		}
+		if !isExcelFile(entry.Name()) {
+			continue
+		}
+		if len(d.config.Include) > 0 && !matchesAny(d.config.Include, relPath) {
+			d.skip(fullPath, "not matched by include patterns")
+			continue
+		}
+		d.files = append(d.files, fullPath)
+	}
+}
+
+// walkArchive collects the Excel files inside an archive, descending into nested archives.
+// Folders inside an archive count towards the depth like ordinary folders.
+func (d *discovery) walkArchive(archivePath, rel string, depth int) error {
+	entries, err := archive.Entries(archivePath)
+	if err != nil {
+		return err
+	}
+
+	skippedFolders := make(map[string]bool)
+	for _, entry := range entries {
+		if err := d.ctx.Err(); err != nil {
+			return err
+		}
+		relPath := path.Join(rel, entry.Name)
+		segments := strings.Split(entry.Name, "/")
+
+		// Check the folders leading to the entry, reporting each skipped folder once
+		skipped := false
+		for i := 1; i <= len(segments) && !skipped; i++ {
+			prefix := strings.Join(segments[:i], "/")
+			reason := d.excluded(path.Join(rel, prefix))
+			if reason == "" && i < len(segments) && d.config.MaxDepth > 0 && depth+i-1 >= d.config.MaxDepth {
+				reason = fmt.Sprintf("deeper than max_depth %d", d.config.MaxDepth)
+			}
+			if reason == "" {
+				continue
+			}
+			skipped = true
+			qualified := archive.Join(archivePath, prefix)
+			if !skippedFolders[qualified] {
+				skippedFolders[qualified] = true
+				d.skip(qualified, reason)
+			}
+		}
+		if skipped {
+			continue
+		}
+
+		entryDepth := depth + len(segments) - 1
+		switch {
+		case archive.IsArchive(entry.Name):
+			if err := d.walkArchive(entry.Path, relPath, entryDepth); err != nil {
+				if d.ctx.Err() != nil {
+					return err
+				}
+				d.onError(entry.Path, err)
+				d.skip(entry.Path, fmt.Sprintf("unreadable archive: %v", err))
+			}
+		case !isExcelFile(entry.Name):
+		case len(d.config.Include) > 0 && !matchesAny(d.config.Include, relPath):
+			d.skip(entry.Path, "not matched by include patterns")
+		default:
+			d.files = append(d.files, entry.Path)
+		}
+	}
+
+	return nil
+}
+
+// excluded returns why a path is excluded, or an empty string
+func (d *discovery) excluded(relPath string) string {
+	for _, pattern := range defaultExcludes {
+		if matchPattern(pattern, relPath) {
+			return "default exclude " + pattern
+		}
+	}
+	for _, pattern := range d.config.Exclude {
+		if matchPattern(pattern, relPath) {
+			return "excluded by " + pattern
+		}
+	}
+	return ""
+}
+
+// skip records a skipped path
+func (d *discovery) skip(fullPath, reason string) {
+	d.skipped = append(d.skipped, models.SkippedPath{Path: fullPath, Reason: reason})
+}
+
+// isExcelFile reports whether a file name has an Excel extension
+func isExcelFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".xlsx" || ext == ".xls"
+}
+
+// matchesAny reports whether a relative path matches one of the patterns
+func matchesAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a slash-separated relative path against a discovery pattern.
+// Patterns without a slash are matched against the last path element only.
+func matchPattern(pattern, relPath string) bool {
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(relPath))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, where "**" matches any number of segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,259 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// createDiscoveryTree creates empty files under root for the given relative paths
+func createDiscoveryTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, rel := range paths {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create folder: %v", err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
+
+// relativePaths converts discovered paths to sorted slash-separated relative paths
+func relativePaths(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	var rel []string
+	for _, p := range paths {
+		r, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatalf("Rel() error = %v", err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+// TestDiscoverFiles tests default excludes, globs and max depth
+func TestDiscoverFiles(t *testing.T) {
+	root := t.TempDir()
+	createDiscoveryTree(t, root,
+		"alice.xlsx",
+		"~$alice.xlsx",
+		"._alice.xlsx",
+		".DS_Store",
+		"notes.txt",
+		"__MACOSX/bob.xlsx",
+		"cohort-a/bob.xlsx",
+		"cohort-a/drafts/bob-old.xlsx",
+		"cohort-b/deep/carol.xlsx",
+	)
+
+	tests := []struct {
+		name        string
+		config      config.DiscoveryConfig
+		wantFiles   []string
+		wantSkipped map[string]string
+	}{
+		{
+			name:      "defaults",
+			wantFiles: []string{"alice.xlsx", "cohort-a/bob.xlsx", "cohort-a/drafts/bob-old.xlsx", "cohort-b/deep/carol.xlsx"},
+			wantSkipped: map[string]string{
+				"~$alice.xlsx": "default exclude ~$*",
+				"._alice.xlsx": "default exclude ._*",
+				".DS_Store":    "default exclude .DS_Store",
+				"__MACOSX":     "default exclude __MACOSX",
+			},
+		},
+		{
+			name:      "exclude folder name",
+			config:    config.DiscoveryConfig{Exclude: []string{"drafts"}},
+			wantFiles: []string{"alice.xlsx", "cohort-a/bob.xlsx", "cohort-b/deep/carol.xlsx"},
+			wantSkipped: map[string]string{
+				"cohort-a/drafts": "excluded by drafts",
+			},
+		},
+		{
+			name:      "include with double star",
+			config:    config.DiscoveryConfig{Include: []string{"cohort-*/**/*.xlsx"}},
+			wantFiles: []string{"cohort-a/bob.xlsx", "cohort-a/drafts/bob-old.xlsx", "cohort-b/deep/carol.xlsx"},
+			wantSkipped: map[string]string{
+				"alice.xlsx": "not matched by include patterns",
+			},
+		},
+		{
+			name:      "max depth",
+			config:    config.DiscoveryConfig{MaxDepth: 2},
+			wantFiles: []string{"alice.xlsx", "cohort-a/bob.xlsx"},
+			wantSkipped: map[string]string{
+				"cohort-a/drafts": "deeper than max_depth 2",
+				"cohort-b/deep":   "deeper than max_depth 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, skipped, err := discoverFiles(context.Background(), root, tt.config, func(string, error) {})
+			if err != nil {
+				t.Fatalf("discoverFiles() error = %v", err)
+			}
+
+			got := relativePaths(t, root, files)
+			if len(got) != len(tt.wantFiles) {
+				t.Fatalf("files = %v, want %v", got, tt.wantFiles)
+			}
+			for i := range got {
+				if got[i] != tt.wantFiles[i] {
+					t.Errorf("files[%d] = %s, want %s", i, got[i], tt.wantFiles[i])
+				}
+			}
+
+			reasons := skippedReasons(t, root, skipped)
+			for path, want := range tt.wantSkipped {
+				if reasons[path] != want {
+					t.Errorf("skipped %s reason = %q, want %q", path, reasons[path], want)
+				}
+			}
+		})
+	}
+}
+
+// TestDiscoverFilesArchiveRoot tests that patterns match paths inside a student files folder that is a ZIP
+func TestDiscoverFilesArchiveRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "bulk.zip")
+	if err := os.WriteFile(root, createTestZip(t, map[string][]byte{
+		"alice/grading.xlsx":        nil,
+		"alice/drafts/grading.xlsx": nil,
+		"bob/notes.xlsx":            nil,
+	}), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	cfg := config.DiscoveryConfig{Include: []string{"*/grading.xlsx"}, Exclude: []string{"alice/drafts"}}
+	files, skipped, err := discoverFiles(context.Background(), root, cfg, func(string, error) {})
+	if err != nil {
+		t.Fatalf("discoverFiles() error = %v", err)
+	}
+	if want := root + "!/alice/grading.xlsx"; len(files) != 1 || files[0] != want {
+		t.Errorf("files = %v, want [%s]", files, want)
+	}
+
+	reasons := make(map[string]string)
+	for _, s := range skipped {
+		reasons[s.Path] = s.Reason
+	}
+	if got := reasons[root+"!/alice/drafts"]; got != "excluded by alice/drafts" {
+		t.Errorf("alice/drafts skip reason = %q", got)
+	}
+	if got := reasons[root+"!/bob/notes.xlsx"]; got != "not matched by include patterns" {
+		t.Errorf("bob/notes.xlsx skip reason = %q", got)
+	}
+}
+
+// skippedReasons maps relative skipped paths to their reasons
+func skippedReasons(t *testing.T, root string, skipped []models.SkippedPath) map[string]string {
+	t.Helper()
+	reasons := make(map[string]string)
+	for _, s := range skipped {
+		rel, _ := filepath.Rel(root, s.Path)
+		reasons[filepath.ToSlash(rel)] = s.Reason
+	}
+	return reasons
+}
+
+// TestDiscoverFilesSymlinks tests the symlink policies and loop detection
+func TestDiscoverFilesSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	createDiscoveryTree(t, root, "alice.xlsx")
+	createDiscoveryTree(t, outside, "shared/bob.xlsx", "carol.xlsx")
+
+	links := map[string]string{
+		"linked-folder": filepath.Join(outside, "shared"),
+		"carol.xlsx":    filepath.Join(outside, "carol.xlsx"),
+		"loop":          root,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		policy      string
+		wantFiles   []string
+		wantSkipped map[string]string
+	}{
+		{
+			policy:      config.SymlinksFiles,
+			wantFiles:   []string{"alice.xlsx", "carol.xlsx"},
+			wantSkipped: map[string]string{"linked-folder": "symbolic link to a folder"},
+		},
+		{
+			policy:      config.SymlinksFollow,
+			wantFiles:   []string{"alice.xlsx", "carol.xlsx", "linked-folder/bob.xlsx"},
+			wantSkipped: map[string]string{"loop": "folder already visited (symbolic link loop)"},
+		},
+		{
+			policy:      config.SymlinksSkip,
+			wantFiles:   []string{"alice.xlsx"},
+			wantSkipped: map[string]string{"carol.xlsx": "symbolic link", "loop": "symbolic link"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			files, skipped, err := discoverFiles(context.Background(), root, config.DiscoveryConfig{Symlinks: tt.policy}, func(string, error) {})
+			if err != nil {
+				t.Fatalf("discoverFiles() error = %v", err)
+			}
+
+			got := relativePaths(t, root, files)
+			if len(got) != len(tt.wantFiles) {
+				t.Fatalf("files = %v, want %v", got, tt.wantFiles)
+			}
+			for i := range got {
+				if got[i] != tt.wantFiles[i] {
+					t.Errorf("files[%d] = %s, want %s", i, got[i], tt.wantFiles[i])
+				}
+			}
+
+			reasons := skippedReasons(t, root, skipped)
+			for path, want := range tt.wantSkipped {
+				if reasons[path] != want {
+					t.Errorf("skipped %s reason = %q, want %q", path, reasons[path], want)
+				}
+			}
+		})
+	}
+}
+
+// TestMatchPattern tests name and path glob matching
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.xlsx", "a/b/grading.xlsx", true},
+		{"drafts", "cohort-a/drafts", true},
+		{"cohort-a/*.xlsx", "cohort-a/bob.xlsx", true},
+		{"cohort-a/*.xlsx", "cohort-a/x/bob.xlsx", false},
+		{"**/final/*.xlsx", "final/bob.xlsx", true},
+		{"**/final/*.xlsx", "2024/sem2/final/bob.xlsx", true},
+		{"cohort-*/**", "cohort-b/deep/carol.xlsx", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
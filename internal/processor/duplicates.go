@@ -0,0 +1,42 @@
+// Package processor provides the main processing logic for the Mark Master Sheet Consolidator.
+// This file contains flagging submissions that look copied from each other.
+package processor
+
+import (
+	"strings"
+
+	"mark-master-sheet/internal/duplicates"
+	"mark-master-sheet/pkg/models"
+)
+
+// findDuplicates compares the files read, and in incremental mode the unchanged files with
+// their recorded marks, for byte-identical content and identical marks across students
+func (p *Processor) findDuplicates(read []*models.StudentData, run *incrementalRun) []models.DuplicateGroup {
+	var submissions []duplicates.Submission
+	for _, data := range read {
+		submission := duplicates.Submission{Path: data.FilePath, StudentID: data.StudentID, Marks: data.Marks}
+		if checked := run.checked[data.FilePath]; checked != nil {
+			submission.SHA256 = checked.SHA256
+		}
+		submissions = append(submissions, submission)
+	}
+	if run.incremental {
+		// Only the unchanged files are recorded at this point
+		for _, unchanged := range run.next.Files {
+			submissions = append(submissions, duplicates.Submission{
+				Path:      unchanged.Path,
+				StudentID: unchanged.StudentID,
+				Marks:     unchanged.Marks,
+				SHA256:    unchanged.SHA256,
+			})
+		}
+	}
+
+	groups := duplicates.Find(submissions, func(path string, err error) {
+		p.logger.WithField("path", path).WithError(err).Debug("Failed to hash file for duplicate detection")
+	})
+	for _, group := range groups {
+		p.logger.WithField("kind", group.Kind).WithField("students", strings.Join(group.Students, ", ")).Warn("Possible duplicate submissions")
+	}
+	return groups
+}
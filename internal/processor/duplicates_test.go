@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mark-master-sheet/internal/fileutil"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestProcessFilesDuplicates tests that copied submissions are listed in the summary
+func TestProcessFilesDuplicates(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir) // every student has the same marks
+	cfg.Processing.Incremental = true
+
+	// The same file also handed in under another folder
+	copied := filepath.Join(cfg.Paths.StudentFilesFolder, "resubmitted", "STU001.xlsx")
+	os.MkdirAll(filepath.Dir(copied), 0755)
+	if _, _, err := fileutil.CopyFile(filepath.Join(cfg.Paths.StudentFilesFolder, "STU001.xlsx"), copied); err != nil {
+		t.Fatalf("Failed to copy student file: %v", err)
+	}
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	kinds := func(groups []models.DuplicateGroup) map[string][]string {
+		result := make(map[string][]string)
+		for _, g := range groups {
+			result[g.Kind] = g.Students
+		}
+		return result
+	}
+	want := map[string][]string{
+		models.DuplicateRepeatedFile:   {"STU001", "STU001"},
+		models.DuplicateIdenticalMarks: {"STU001", "STU001", "STU002", "STU003"},
+	}
+
+	summary, err := processor.ProcessFiles(context.Background(), true)
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+	if got := kinds(summary.Duplicates); !reflect.DeepEqual(got, want) {
+		t.Errorf("duplicates = %v, want %v", got, want)
+	}
+
+	// Unchanged files of an incremental run are still compared
+	if _, err := processor.ProcessFiles(context.Background(), false); err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+	createTestStudentFile(t, cfg.Paths.StudentFilesFolder, "STU004")
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("incremental run error = %v", err)
+	}
+	if summary.UnchangedFiles == 0 {
+		t.Fatal("incremental run read every file again")
+	}
+	if got := kinds(summary.Duplicates)[models.DuplicateIdenticalMarks]; len(got) != 5 {
+		t.Errorf("identical marks = %v, want the new student grouped with the unchanged ones", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Package processor provides the main processing logic for the Mark Master Sheet Consolidator.
+// This file contains the progress events reported to the CLI, GUI and other consumers.
+package processor
+
+import (
+	"time"
+
+	"mark-master-sheet/pkg/models"
+)
+
+// EventType identifies a step of a processing run
+type EventType string
+
+// Progress events, in the order a run reports them
+const (
+	EventStarted       EventType = "started"        // files discovered, Total is known
+	EventBackupCreated EventType = "backup_created" // Path is the backup file
+	EventFileStarted   EventType = "file_started"   // a worker began reading Path
+	EventFileDone      EventType = "file_done"      // Result holds the outcome for Path
+	EventWriting       EventType = "writing"        // marks are being written to the master sheet
+	EventCompleted     EventType = "completed"      // Summary and Err hold the outcome of the run
+)
+
+// Event reports the progress of a processing run
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Path    string
+	Index   int // position of the file in discovery order
+	Done    int // files finished so far
+	Total   int // files found
+	Result  *models.ProcessingResult
+	Summary *models.ProcessingSummary
+	Err     error
+}
+
+// EventHandler receives progress events. Calls never overlap, but they come from the
+// processing goroutines, so handlers must be quick and must not call back into the processor.
+type EventHandler func(Event)
+
+// SetEventHandler sets the function receiving progress events; nil disables them
+func (p *Processor) SetEventHandler(handler EventHandler) {
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+	p.onEvent = handler
+}
+
+// emit delivers an event to the handler, if one is set
+func (p *Processor) emit(event Event) {
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if p.onEvent == nil {
+		return
+	}
+	event.Time = time.Now()
+	p.onEvent(event)
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"testing"
+)
+
+// TestProcessFilesEvents tests the progress events reported by a production run
+func TestProcessFilesEvents(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+	cfg.Processing.MaxConcurrentFiles = 2
+
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	var events []Event
+	processor.SetEventHandler(func(event Event) {
+		events = append(events, event)
+	})
+
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+
+	counts := make(map[EventType]int)
+	var order []EventType
+	done := 0
+	for _, event := range events {
+		counts[event.Type]++
+		if event.Type != EventFileStarted && event.Type != EventFileDone {
+			order = append(order, event.Type)
+		}
+		if event.Type == EventFileDone {
+			if event.Index != done || event.Done != done+1 || event.Total != 3 || event.Result == nil {
+				t.Errorf("file done event %+v, want index %d of 3", event, done)
+			}
+			done++
+		}
+	}
+
+	wantOrder := []EventType{EventStarted, EventBackupCreated, EventWriting, EventCompleted}
+	if len(order) != len(wantOrder) {
+		t.Fatalf("event order = %v, want %v", order, wantOrder)
+	}
+	for i := range wantOrder {
+		if order[i] != wantOrder[i] {
+			t.Fatalf("event order = %v, want %v", order, wantOrder)
+		}
+	}
+	if counts[EventFileStarted] != 3 || counts[EventFileDone] != 3 {
+		t.Errorf("file started/done events = %d/%d, want 3/3", counts[EventFileStarted], counts[EventFileDone])
+	}
+
+	last := events[len(events)-1]
+	if last.Summary != summary || last.Err != nil || last.Done != 3 {
+		t.Errorf("completed event = %+v, want the run summary", last)
+	}
+}
+
+// TestProcessFilesEventsOnFailure tests that a failed run still reports completion
+func TestProcessFilesEventsOnFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = "nonexistent-master.xlsx"
+
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	var completed []Event
+	processor.SetEventHandler(func(event Event) {
+		if event.Type == EventCompleted {
+			completed = append(completed, event)
+		}
+	})
+
+	_, err := processor.ProcessFiles(context.Background(), true)
+	if err == nil {
+		t.Fatal("ProcessFiles() expected an error for a missing master sheet")
+	}
+	if len(completed) != 1 || completed[0].Err != err {
+		t.Errorf("completed events = %+v, want one carrying the error", completed)
+	}
+}
@@ -0,0 +1,130 @@
+// Package processor provides the main processing logic for the Mark Master Sheet Consolidator.
+// This file contains incremental processing based on the state file of the previous run.
+package processor
+
+import (
+	"context"
+	"strings"
+
+	"mark-master-sheet/internal/checkpoint"
+	"mark-master-sheet/internal/state"
+	"mark-master-sheet/pkg/models"
+)
+
+// incrementalRun compares the discovered files with the state recorded by the previous run
+// and builds the state recorded by this one
+type incrementalRun struct {
+	path        string
+	incremental bool
+	previous    *state.State // nil when there is no usable previous state
+	next        *state.State
+	checked     map[string]*state.FileState // fingerprints of the files read by this run
+}
+
+// startIncremental loads the state file and selects the files to read. In incremental mode
+// files unchanged since the previous run are left out; otherwise every file is read and
+// the state is only refreshed.
+func (p *Processor) startIncremental(ctx context.Context, files []string) (*incrementalRun, []string, int, error) {
+	masterSheetPath := p.config.Paths.MasterSheetPath
+	settings := state.SettingsHash(&p.config.Excel)
+	run := &incrementalRun{
+		path:        state.Path(p.config.Paths.OutputFolder),
+		incremental: p.config.Processing.Incremental,
+		next:        state.New(masterSheetPath, settings),
+		checked:     make(map[string]*state.FileState),
+	}
+
+	previous, err := state.Load(run.path)
+	switch {
+	case err != nil:
+		p.logger.WithError(err).Warn("Ignoring unreadable state file, reading every file")
+	case previous != nil && previous.Matches(masterSheetPath, settings):
+		masterHash, err := checkpoint.MasterHash(masterSheetPath)
+		if err != nil || previous.MasterChanged(masterHash) {
+			if run.incremental {
+				p.logger.Warn("Master sheet changed since the previous run, reading every file")
+			}
+			break
+		}
+		run.previous = previous
+	case previous != nil && run.incremental:
+		p.logger.Warn("State file was recorded for another master sheet or Excel settings, reading every file")
+	}
+
+	var toRead []string
+	unchanged := 0
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, 0, err
+		}
+
+		current, changed, err := state.Check(path, run.previous.Get(path))
+		if err != nil {
+			// Leave the error to the reader, which reports it with the processing stage
+			p.logger.WithField("path", path).WithError(err).Debug("Failed to fingerprint file")
+			toRead = append(toRead, path)
+			continue
+		}
+		if !changed && run.incremental {
+			run.next.Record(current)
+			unchanged++
+			continue
+		}
+		run.checked[path] = current
+		toRead = append(toRead, path)
+	}
+
+	if run.incremental {
+		p.logger.WithField("changed", len(toRead)).WithField("unchanged", unchanged).Info("Incremental run")
+	}
+	return run, toRead, unchanged, nil
+}
+
+// differences returns the student data reduced to the marks that differ from the previous
+// state of its file, or nil when no mark needs writing. Outside incremental mode, and for new
+// files or files now naming another student, all marks are kept.
+func (r *incrementalRun) differences(data *models.StudentData) *models.StudentData {
+	previous := r.previous.Get(data.FilePath)
+	if !r.incremental || previous == nil || !strings.EqualFold(previous.StudentID, data.StudentID) {
+		return data
+	}
+
+	changed := *data
+	changed.Marks = make(map[string]float64)
+	for cell, mark := range data.Marks {
+		if old, ok := previous.Marks[cell]; (ok && old == mark) || mark < 0 {
+			continue
+		}
+		changed.Marks[cell] = mark
+	}
+	if len(changed.Marks) == 0 {
+		return nil
+	}
+	return &changed
+}
+
+// record stores the files whose marks are now in the master sheet. Files read but not written,
+// for example because the student was not found, keep their previous state so that the next
+// incremental run reads them again.
+func (r *incrementalRun) record(runID string, files []string, read []*models.StudentData, settled map[string]bool) {
+	for _, data := range read {
+		current := r.checked[data.FilePath]
+		if current == nil || !settled[data.FilePath] {
+			continue
+		}
+		current.StudentID = data.StudentID
+		current.StudentName = data.StudentName
+		current.Marks = data.Marks
+		current.RunID = runID
+		r.next.Record(current)
+	}
+
+	// Keep the previous state of files that failed or were not written; files no longer found are dropped
+	for _, path := range files {
+		if r.next.Get(path) == nil {
+			if previous := r.previous.Get(path); previous != nil {
+				r.next.Record(previous)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"mark-master-sheet/internal/excel"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/internal/state"
+)
+
+// TestProcessFilesIncremental tests that incremental runs only read changed files and only write differing marks
+func TestProcessFilesIncremental(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir) // STU003 is not in the master
+	cfg.Processing.Incremental = true
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	journalEntries := func(path string) int {
+		t.Helper()
+		if path == "" {
+			return 0
+		}
+		j, err := journal.Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load journal: %v", err)
+		}
+		return len(j.Entries)
+	}
+
+	// First run reads everything and records the two students written
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("first run error = %v", err)
+	}
+	if summary.SuccessfulFiles != 3 || summary.UnchangedFiles != 0 || journalEntries(summary.JournalPath) != 6 {
+		t.Fatalf("first run successful/unchanged/writes = %d/%d/%d, want 3/0/6",
+			summary.SuccessfulFiles, summary.UnchangedFiles, journalEntries(summary.JournalPath))
+	}
+	st, err := state.Load(state.Path(cfg.Paths.OutputFolder))
+	if err != nil || st == nil || len(st.Files) != 2 {
+		t.Fatalf("state after first run = %+v, %v, want the two written files", st, err)
+	}
+
+	// Change one mark of STU002
+	stu002 := createTestStudentFile(t, cfg.Paths.StudentFilesFolder, "STU002")
+	f, err := excelize.OpenFile(stu002)
+	if err != nil {
+		t.Fatalf("Failed to open student file: %v", err)
+	}
+	f.SetCellValue("Grading Sheet", "C6", 50)
+	if err := f.Save(); err != nil {
+		t.Fatalf("Failed to save student file: %v", err)
+	}
+	f.Close()
+
+	// STU001 is unchanged; STU003 was never written, so it is read again
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("incremental run error = %v", err)
+	}
+	if summary.UnchangedFiles != 1 || summary.SuccessfulFiles != 2 {
+		t.Errorf("incremental run unchanged/successful = %d/%d, want 1/2", summary.UnchangedFiles, summary.SuccessfulFiles)
+	}
+	if got := journalEntries(summary.JournalPath); got != 1 {
+		t.Errorf("incremental run wrote %d cells, want only the changed mark", got)
+	}
+
+	master, err := excelize.OpenFile(cfg.Paths.MasterSheetPath)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	mark, _ := master.GetCellValue("001", "I3")
+	master.Close()
+	if mark != "50" {
+		t.Errorf("master I3 = %q, want 50", mark)
+	}
+
+	// Nothing changed since
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("repeated run error = %v", err)
+	}
+	if summary.UnchangedFiles != 2 || summary.JournalPath != "" {
+		t.Errorf("repeated run unchanged = %d, journal %q, want 2 and no writes", summary.UnchangedFiles, summary.JournalPath)
+	}
+
+	// A full rebuild reads and writes everything again
+	cfg.Processing.Incremental = false
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("full run error = %v", err)
+	}
+	if summary.UnchangedFiles != 0 || summary.SuccessfulFiles != 3 || journalEntries(summary.JournalPath) != 6 {
+		t.Errorf("full run unchanged/successful/writes = %d/%d/%d, want 0/3/6",
+			summary.UnchangedFiles, summary.SuccessfulFiles, journalEntries(summary.JournalPath))
+	}
+}
+
+// TestProcessFilesIncrementalAfterUndo tests that an incremental run after an undo writes the reverted marks again
+func TestProcessFilesIncrementalAfterUndo(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+	cfg.Processing.Incremental = true
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil || summary.JournalPath == "" {
+		t.Fatalf("first run = journal %q, error %v, want marks written", summary.JournalPath, err)
+	}
+
+	j, err := journal.Load(summary.JournalPath)
+	if err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	undo, err := excel.NewWriter(&cfg.Excel).UndoJournal(cfg.Paths.MasterSheetPath, j)
+	if err != nil || undo.Reverted == 0 {
+		t.Fatalf("UndoJournal() reverted %d, error %v", undo.Reverted, err)
+	}
+
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("incremental run error = %v", err)
+	}
+	if summary.UnchangedFiles != 0 {
+		t.Errorf("incremental run after undo unchanged = %d, want every file read again", summary.UnchangedFiles)
+	}
+
+	master, err := excelize.OpenFile(cfg.Paths.MasterSheetPath)
+	if err != nil {
+		t.Fatalf("Failed to open master: %v", err)
+	}
+	defer master.Close()
+	if mark, _ := master.GetCellValue("001", "I3"); mark == "" {
+		t.Error("master I3 is empty, want the reverted mark written again")
+	}
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestProcessFilesConcurrentlyOrdered tests that results follow the input order whatever the completion order
+func TestProcessFilesConcurrentlyOrdered(t *testing.T) {
+	tempDir := t.TempDir()
+	studentDir := filepath.Join(tempDir, "students")
+	if err := os.MkdirAll(studentDir, 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	// Interleave valid and corrupt files so failures finish at different times than reads
+	var files, wantStudents, wantErrors []string
+	for i := 0; i < 12; i++ {
+		if i%4 == 3 {
+			path := filepath.Join(studentDir, fmt.Sprintf("corrupt%02d.xlsx", i))
+			if err := os.WriteFile(path, []byte("not a workbook"), 0644); err != nil {
+				t.Fatalf("Failed to write corrupt file: %v", err)
+			}
+			files = append(files, path)
+			wantErrors = append(wantErrors, path)
+			continue
+		}
+		id := fmt.Sprintf("STU%03d", 12-i)
+		files = append(files, createTestStudentFile(t, studentDir, id))
+		wantStudents = append(wantStudents, id)
+	}
+
+	cfg := createTestConfig(tempDir)
+	cfg.Processing.MaxConcurrentFiles = 4
+	cfg.Processing.RetryAttempts = 1
+	cfg.Processing.SkipInvalidFiles = false
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	for run := 0; run < 3; run++ {
+		students, summary := processor.processFilesConcurrently(context.Background(), files)
+
+		var gotStudents []string
+		for _, s := range students {
+			gotStudents = append(gotStudents, s.StudentID)
+		}
+		if !reflect.DeepEqual(gotStudents, wantStudents) {
+			t.Fatalf("run %d: students = %v, want %v", run, gotStudents, wantStudents)
+		}
+
+		if len(summary.Errors) != len(wantErrors) {
+			t.Fatalf("run %d: errors = %v, want one per corrupt file", run, summary.Errors)
+		}
+		for i, path := range wantErrors {
+			if !strings.HasPrefix(summary.Errors[i], "File "+path+":") {
+				t.Errorf("run %d: error %d = %q, want it for %s", run, i, summary.Errors[i], path)
+			}
+		}
+		if summary.SuccessfulFiles != len(wantStudents) || summary.FailedFiles != len(wantErrors) {
+			t.Errorf("run %d: successful/failed = %d/%d, want %d/%d",
+				run, summary.SuccessfulFiles, summary.FailedFiles, len(wantStudents), len(wantErrors))
+		}
+	}
+}
+
+// TestProcessFilesConcurrentlyMoreWorkersThanFiles tests a pool larger than the input
+func TestProcessFilesConcurrentlyMoreWorkersThanFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	file := createTestStudentFile(t, tempDir, "STU001")
+
+	cfg := createTestConfig(tempDir)
+	cfg.Processing.MaxConcurrentFiles = 8
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	students, summary := processor.processFilesConcurrently(context.Background(), []string{file})
+	if len(students) != 1 || summary.SuccessfulFiles != 1 {
+		t.Errorf("students = %d, successful = %d, want 1 and 1", len(students), summary.SuccessfulFiles)
+	}
+
+	students, summary = processor.processFilesConcurrently(context.Background(), nil)
+	if len(students) != 0 || summary.SuccessfulFiles != 0 {
+		t.Errorf("empty input read %d students", len(students))
+	}
+}
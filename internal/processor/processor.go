@@ -4,60 +4,154 @@ package processor
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
+	mathrand "math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"mark-master-sheet/internal/backup"
+	"mark-master-sheet/internal/checkpoint"
 	"mark-master-sheet/internal/config"
 	"mark-master-sheet/internal/excel"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/internal/lock"
 	"mark-master-sheet/internal/logger"
+	"mark-master-sheet/internal/quarantine"
 	"mark-master-sheet/pkg/models"
 )
 
+// abandonedReads counts the reads that overran their timeout and are still running in the whole
+// process, so that the processors of successive watch mode or GUI runs share one limit
+var abandonedReads atomic.Int32
+
 // Processor handles the main processing logic
 type Processor struct {
 	config *config.Config
 	logger *logger.Logger
 	reader *excel.Reader
 	writer *excel.Writer
+
+	// readFile reads one student file; tests replace it to simulate failures
+	readFile func(filePath string) (*models.StudentData, error)
+
+	resume     bool
+	checkpoint *checkpoint.Checkpoint // open while a production run reads files
+	quarantine *quarantine.Quarantine // set while a production run sets aside failing files
+
+	eventMu sync.Mutex
+	onEvent EventHandler
 }
 
 // NewProcessor creates a new processor instance
 func NewProcessor(cfg *config.Config, log *logger.Logger) *Processor {
-	return &Processor{
+	p := &Processor{
 		config: cfg,
 		logger: log,
 		reader: excel.NewReader(&cfg.Excel),
 		writer: excel.NewWriter(&cfg.Excel),
 	}
+	p.readFile = p.reader.ReadStudentData
+	return p
 }
 
 // ProcessFiles processes all Excel files in the student files directory
 func (p *Processor) ProcessFiles(ctx context.Context, dryRun bool) (*models.ProcessingSummary, error) {
+	summary, err := p.processFiles(ctx, dryRun)
+	p.emit(Event{
+		Type:    EventCompleted,
+		Done:    summary.SuccessfulFiles + summary.FailedFiles + summary.SkippedFiles,
+		Total:   summary.TotalFiles - summary.UnchangedFiles,
+		Summary: summary,
+		Err:     err,
+	})
+	return summary, err
+}
+
+// processFiles runs discovery, backup, reading and the master sheet update for ProcessFiles
+func (p *Processor) processFiles(ctx context.Context, dryRun bool) (*models.ProcessingSummary, error) {
 	summary := &models.ProcessingSummary{
+		RunID:     newRunID(),
 		StartTime: time.Now(),
 	}
+	p.writer.SetRunID(summary.RunID)
+	p.logger.WithField("run_id", summary.RunID).Info("Run started")
+
+	// Keep other runs away from the master sheet while this one may write to it
+	if !dryRun {
+		masterLock, err := lock.Acquire(p.config.Paths.MasterSheetPath, "run "+summary.RunID, p.config.Processing.LockStaleAfter())
+		if err != nil {
+			return summary, err
+		}
+		defer func() {
+			if err := masterLock.Release(); err != nil {
+				p.logger.WithError(err).Warn("Failed to release master sheet lock")
+			}
+		}()
+	}
 
 	// Validate master sheet first
 	if err := p.writer.ValidateMasterSheet(p.config.Paths.MasterSheetPath); err != nil {
 		return summary, fmt.Errorf("master sheet validation failed: %w", err)
 	}
 
+	// Checkpoint the files read so that an interrupted run can be resumed
+	if dryRun && p.resume {
+		return summary, fmt.Errorf("an interrupted run cannot be resumed in dry-run mode")
+	}
+	if !dryRun {
+		if err := p.openCheckpoint(summary); err != nil {
+			return summary, err
+		}
+		defer p.closeCheckpoint()
+	}
+
+	// Set aside files that fail validation so that they stop failing every run
+	if action := p.config.Quarantine.Action(); action != config.QuarantineOff && !dryRun {
+		p.quarantine = quarantine.New(p.config.QuarantineFolder(), p.config.Paths.StudentFilesFolder, action, summary.RunID)
+		defer func() { p.quarantine = nil }()
+	}
+
 	// Find all Excel files
-	excelFiles, err := p.findExcelFiles(p.config.Paths.StudentFilesFolder)
+	excelFiles, skippedPaths, err := p.findExcelFiles(ctx, p.config.Paths.StudentFilesFolder)
 	if err != nil {
+		if ctx.Err() != nil {
+			return p.cancelRun(ctx, summary)
+		}
 		return summary, fmt.Errorf("failed to find Excel files: %w", err)
 	}
+	summary.SkippedPaths = skippedPaths
 
 	summary.TotalFiles = len(excelFiles)
 	p.logger.LogProcessingStart(summary.TotalFiles)
 
 	if summary.TotalFiles == 0 {
+		p.emit(Event{Type: EventStarted})
 		p.logger.Info("No Excel files found to process")
+		p.finishCheckpoint()
+		return summary, nil
+	}
+
+	// Compare the files with the state of the previous run, leaving out unchanged ones in incremental mode
+	incremental, filesToRead, unchanged, err := p.startIncremental(ctx, excelFiles)
+	if err != nil || ctx.Err() != nil {
+		summary.CancelledFiles = summary.TotalFiles
+		return p.cancelRun(ctx, summary)
+	}
+	summary.UnchangedFiles = unchanged
+	p.emit(Event{Type: EventStarted, Total: len(filesToRead)})
+
+	if len(filesToRead) == 0 {
+		p.logger.Info("No new or changed files since the last run")
+		if !dryRun {
+			p.saveState(incremental, summary)
+		}
+		p.finishCheckpoint()
+		summary.EndTime = time.Now()
+		summary.TotalDuration = summary.EndTime.Sub(summary.StartTime)
+		p.logger.LogProcessingEnd(summary)
 		return summary, nil
 	}
 
@@ -68,37 +162,97 @@ func (p *Processor) ProcessFiles(ctx context.Context, dryRun bool) (*models.Proc
 			p.config.Paths.MasterSheetPath,
 			p.config.Paths.BackupFolder,
 		)
-		if err != nil {
+		if err != nil && !backup.ManifestOnly(err) {
 			return summary, fmt.Errorf("failed to create backup: %w", err)
 		}
+		if err != nil {
+			p.logger.WithField("backup_path", backupPath).WithError(err).Warn("Backup created with a problem in the backup manifest")
+		}
 		p.logger.LogBackupCreated(p.config.Paths.MasterSheetPath, backupPath)
+		p.emit(Event{Type: EventBackupCreated, Path: backupPath, Total: summary.TotalFiles})
+		p.pruneBackups()
 	}
 
 	// Process files concurrently
-	studentDataList, processingSummary := p.processFilesConcurrently(ctx, excelFiles)
+	studentDataList, processingSummary := p.processFilesConcurrently(ctx, filesToRead)
 
 	// Merge processing summary
 	summary.SuccessfulFiles = processingSummary.SuccessfulFiles
 	summary.FailedFiles = processingSummary.FailedFiles
 	summary.SkippedFiles = processingSummary.SkippedFiles
+	summary.ResumedFiles = processingSummary.ResumedFiles
+	summary.QuarantinedFiles = processingSummary.QuarantinedFiles
 	summary.Errors = processingSummary.Errors
 	summary.Warnings = processingSummary.Warnings
+	summary.CancelledFiles = processingSummary.CancelledFiles
+
+	// Decide what happens to the files read before a cancellation
+	writeCtx := ctx
+	if ctx.Err() != nil {
+		if dryRun || len(studentDataList) == 0 || p.config.Processing.CancelPolicy() != config.OnCancelCommit {
+			p.logger.Warn("Run cancelled, master sheet left unchanged")
+			return p.cancelRun(ctx, summary)
+		}
+		p.logger.WithField("students", len(studentDataList)).Warn("Run cancelled, committing the files read so far")
+		summary.Cancelled = true
+		summary.Warnings = append(summary.Warnings,
+			fmt.Sprintf("Run cancelled, %d files not processed; results of the files read so far were written", summary.CancelledFiles))
+		writeCtx = context.WithoutCancel(ctx)
+	}
+
+	// Flag submissions that look copied for the module leader to check
+	summary.Duplicates = p.findDuplicates(studentDataList, incremental)
+
+	// Write only the marks that differ from the previous run in incremental mode
+	settled := make(map[string]bool)
+	var updates []*models.StudentData
+	for _, studentData := range studentDataList {
+		if changed := incremental.differences(studentData); changed != nil {
+			updates = append(updates, changed)
+		} else {
+			settled[studentData.FilePath] = true
+		}
+	}
 
 	// Update master sheet if not in dry run mode
-	if !dryRun && len(studentDataList) > 0 {
-		updateSummary, err := p.writer.BatchUpdateMasterSheet(
+	if !dryRun && len(updates) > 0 {
+		p.emit(Event{Type: EventWriting, Done: summary.SuccessfulFiles + summary.FailedFiles + summary.SkippedFiles, Total: len(filesToRead)})
+		updateSummary, err := p.writer.BatchUpdateMasterSheetContext(
+			writeCtx,
 			p.config.Paths.MasterSheetPath,
-			studentDataList,
+			updates,
 		)
 		if err != nil {
+			if writeCtx.Err() != nil {
+				p.logger.Warn("Run cancelled during the master sheet update, master sheet left unchanged")
+				return p.cancelRun(ctx, summary)
+			}
 			return summary, fmt.Errorf("failed to update master sheet: %w", err)
 		}
 
 		summary.StudentsUpdated = updateSummary.StudentsUpdated
 		summary.StudentsNotFound = updateSummary.StudentsNotFound
+		summary.StudentsAdded = updateSummary.StudentsAdded
+		summary.FormulasRecalculated = updateSummary.FormulasRecalculated
+		summary.VerificationFailures = updateSummary.VerificationFailures
 		summary.Errors = append(summary.Errors, updateSummary.Errors...)
 		summary.Warnings = append(summary.Warnings, updateSummary.Warnings...)
 
+		// Record the cell writes so the run can be undone
+		if j := p.writer.LastJournal(); j != nil && len(j.Entries) > 0 {
+			for _, entry := range j.Entries {
+				settled[entry.SourceFile] = true
+			}
+			journalPath, err := j.Save(journal.Dir(p.config.Paths.OutputFolder))
+			if err != nil {
+				p.logger.WithError(err).Error("Failed to save run journal")
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("Run journal not saved, undo unavailable: %v", err))
+			} else {
+				summary.JournalPath = journalPath
+				p.logger.WithField("journal_path", journalPath).Info("Run journal saved")
+			}
+		}
+
 		// Save updated master sheet to output directory
 		outputPath, err := p.writer.SaveMasterSheetCopy(
 			p.config.Paths.MasterSheetPath,
@@ -111,120 +265,231 @@ func (p *Processor) ProcessFiles(ctx context.Context, dryRun bool) (*models.Proc
 		}
 	}
 
+	// Remember the files now in the master sheet for the next incremental run
+	if !dryRun {
+		incremental.record(summary.RunID, excelFiles, studentDataList, settled)
+		p.saveState(incremental, summary)
+	}
+
+	// The marks are in the master sheet, so there is nothing left to resume
+	p.finishCheckpoint()
+
 	summary.EndTime = time.Now()
 	summary.TotalDuration = summary.EndTime.Sub(summary.StartTime)
 
 	p.logger.LogProcessingEnd(summary)
+	if summary.Cancelled {
+		return summary, ctx.Err()
+	}
 	return summary, nil
 }
 
-// findExcelFiles recursively finds all Excel files in the given directory
-func (p *Processor) findExcelFiles(rootDir string) ([]string, error) {
-	var excelFiles []string
+// cancelRun finishes the summary of a run stopped by its context and returns the context error
+func (p *Processor) cancelRun(ctx context.Context, summary *models.ProcessingSummary) (*models.ProcessingSummary, error) {
+	summary.Cancelled = true
+	summary.EndTime = time.Now()
+	summary.TotalDuration = summary.EndTime.Sub(summary.StartTime)
 
-	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			p.logger.LogFileError(path, err, "directory_walk")
-			return nil // Continue walking despite errors
-		}
+	p.logger.WithError(ctx.Err()).WithField("cancelled_files", summary.CancelledFiles).Warn("Run cancelled")
+	return summary, ctx.Err()
+}
 
-		if info.IsDir() {
-			return nil
-		}
+// saveState writes the state file of an incremental comparison with the hash of the master sheet
+// as written, reporting failures as a warning
+func (p *Processor) saveState(run *incrementalRun, summary *models.ProcessingSummary) {
+	masterHash, err := checkpoint.MasterHash(p.config.Paths.MasterSheetPath)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to hash master sheet, the next incremental run reads every file")
+	}
+	run.next.MasterHash = masterHash
+	if err := run.next.Save(run.path); err != nil {
+		p.logger.WithError(err).Error("Failed to save state file")
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("State file not saved, the next incremental run reads every file: %v", err))
+	}
+}
 
-		// Check if it's an Excel file
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".xlsx" || ext == ".xls" {
-			excelFiles = append(excelFiles, path)
-		}
+// pruneBackups applies the configured backup retention rules
+func (p *Processor) pruneBackups() {
+	pruned, err := backup.Prune(
+		p.config.Paths.BackupFolder,
+		p.config.Paths.MasterSheetPath,
+		p.config.Backup,
+	)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to prune old backups")
+	}
+	for _, b := range pruned {
+		p.logger.LogBackupPruned(b.Info.Path, b.Reason)
+	}
+}
 
-		return nil
+// findExcelFiles finds the Excel files in the given directory allowed by the discovery settings,
+// returning the paths it skipped with the reason
+func (p *Processor) findExcelFiles(ctx context.Context, rootDir string) ([]string, []models.SkippedPath, error) {
+	files, skipped, err := discoverFiles(ctx, rootDir, p.config.Discovery, func(path string, err error) {
+		p.logger.LogFileError(path, err, "directory_walk")
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("error walking directory %s: %w", rootDir, err)
+		return nil, nil, fmt.Errorf("error walking directory %s: %w", rootDir, err)
+	}
+
+	for _, s := range skipped {
+		p.logger.WithField("path", s.Path).WithField("reason", s.Reason).Debug("Path skipped during discovery")
 	}
 
-	return excelFiles, nil
+	return files, skipped, nil
+}
+
+// fileJob is a file queued for the worker pool, with its position in the input
+type fileJob struct {
+	index int
+	path  string
+}
+
+// fileOutcome is the result of a file job
+type fileOutcome struct {
+	index  int
+	result *models.ProcessingResult
 }
 
-// processFilesConcurrently processes files using goroutines with rate limiting
+// processFilesConcurrently processes files with a fixed pool of workers fed by a channel.
+// Results are collected by input position and recorded in input order, so the same files
+// always produce the same student order, summary and log.
 func (p *Processor) processFilesConcurrently(ctx context.Context, files []string) ([]*models.StudentData, *models.ProcessingSummary) {
 	summary := &models.ProcessingSummary{}
 	var studentDataList []*models.StudentData
-	var mu sync.Mutex
-
-	// Create progress bar
-	bar := progressbar.NewOptions(len(files),
-		progressbar.OptionSetDescription("Processing files..."),
-		progressbar.OptionShowCount(),
-		progressbar.OptionShowIts(),
-		progressbar.OptionSetPredictTime(true),
-	)
 
-	// Create semaphore for rate limiting
-	semaphore := make(chan struct{}, p.config.Processing.MaxConcurrentFiles)
-	var wg sync.WaitGroup
+	jobs := make(chan fileJob)
+	outcomes := make(chan fileOutcome)
 
-	// Process each file
-	for _, filePath := range files {
-		select {
-		case <-ctx.Done():
-			p.logger.Warn("Processing cancelled by context")
-			break
-		default:
+	// Queue the files, stopping as soon as the context is cancelled
+	go func() {
+		defer close(jobs)
+		for i, path := range files {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- fileJob{index: i, path: path}:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
 
+	// Start the workers
+	workers := p.config.Processing.MaxConcurrentFiles
+	if workers > len(files) {
+		workers = len(files)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(path string) {
+		go func() {
 			defer wg.Done()
-			defer bar.Add(1)
-
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Process file with retries
-			result := p.processFileWithRetries(path)
-
-			// Update summary and collect data
-			mu.Lock()
-			if result.Success {
-				summary.SuccessfulFiles++
-				if result.StudentData != nil {
-					studentDataList = append(studentDataList, result.StudentData)
-				}
-			} else {
-				if p.config.Processing.SkipInvalidFiles {
-					summary.SkippedFiles++
-					p.logger.LogSkippedFile(path, result.Error.Error())
-				} else {
-					summary.FailedFiles++
-					summary.Errors = append(summary.Errors,
-						fmt.Sprintf("File %s: %v", path, result.Error))
-				}
+			for job := range jobs {
+				p.emit(Event{Type: EventFileStarted, Path: job.path, Index: job.index, Total: len(files)})
+				outcomes <- fileOutcome{index: job.index, result: p.readOrResume(ctx, job.path)}
 			}
-			mu.Unlock()
-
-			// Log progress
-			mu.Lock()
-			processed := summary.SuccessfulFiles + summary.FailedFiles + summary.SkippedFiles
-			mu.Unlock()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
 
-			if processed%10 == 0 { // Log every 10 files
-				p.logger.LogProgress(processed, len(files), path)
+	// Record results in input order as soon as all earlier files are done
+	results := make([]*models.ProcessingResult, len(files))
+	next := 0
+	for outcome := range outcomes {
+		results[outcome.index] = outcome.result
+		for next < len(results) && results[next] != nil {
+			p.recordResult(ctx, next, len(files), results[next], summary, &studentDataList)
+			next++
+			if next%10 == 0 { // Log every 10 files
+				p.logger.LogProgress(next, len(files), results[next-1].FilePath)
 			}
-		}(filePath)
+		}
 	}
 
-	wg.Wait()
-	bar.Finish()
+	// Files never started or after a gap left by a cancelled file count as cancelled
+	for ; next < len(results); next++ {
+		if results[next] == nil {
+			summary.CancelledFiles++
+			continue
+		}
+		p.recordResult(ctx, next, len(files), results[next], summary, &studentDataList)
+	}
+	if summary.CancelledFiles > 0 {
+		p.logger.WithField("remaining", summary.CancelledFiles).Warn("Processing cancelled by context")
+	}
 
 	return studentDataList, summary
 }
 
-// processFileWithRetries processes a single file with retry logic
-func (p *Processor) processFileWithRetries(filePath string) *models.ProcessingResult {
+// recordResult adds the result of the file at index to the summary, logs it and reports it as done
+func (p *Processor) recordResult(ctx context.Context, index, total int, result *models.ProcessingResult, summary *models.ProcessingSummary, studentDataList *[]*models.StudentData) {
+	switch {
+	case result.Success && result.Resumed:
+		summary.SuccessfulFiles++
+		summary.ResumedFiles++
+		*studentDataList = append(*studentDataList, result.StudentData)
+		p.logger.WithField("file_path", result.FilePath).WithField("student_id", result.StudentData.StudentID).Debug("File taken from checkpoint")
+	case result.Success:
+		summary.SuccessfulFiles++
+		*studentDataList = append(*studentDataList, result.StudentData)
+		p.checkpointResult(result, summary)
+		p.logger.LogFileProcessed(
+			result.FilePath,
+			result.StudentData.StudentID,
+			result.StudentData.GetMarkCount(),
+			result.Duration,
+		)
+	case result.Error != nil && result.Error == ctx.Err():
+		summary.CancelledFiles++
+		return
+	default:
+		p.logger.LogFileError(result.FilePath, result.Error, "processing")
+		p.quarantineResult(result, summary)
+		if p.config.Processing.SkipInvalidFiles {
+			summary.SkippedFiles++
+			p.logger.LogSkippedFile(result.FilePath, result.Error.Error())
+		} else {
+			summary.FailedFiles++
+			summary.Errors = append(summary.Errors,
+				fmt.Sprintf("File %s: %v", result.FilePath, result.Error))
+		}
+	}
+
+	p.emit(Event{
+		Type:   EventFileDone,
+		Path:   result.FilePath,
+		Index:  index,
+		Done:   summary.SuccessfulFiles + summary.FailedFiles + summary.SkippedFiles,
+		Total:  total,
+		Result: result,
+	})
+}
+
+// quarantineResult sets aside a file that failed validation, reporting failures as a warning
+func (p *Processor) quarantineResult(result *models.ProcessingResult, summary *models.ProcessingSummary) {
+	if p.quarantine == nil || !quarantine.Eligible(result.Error) {
+		return
+	}
+	dest, err := p.quarantine.Add(result.FilePath, result.Error)
+	if err != nil {
+		p.logger.WithField("file_path", result.FilePath).WithError(err).Error("Failed to quarantine file")
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("File %s not quarantined: %v", result.FilePath, err))
+		return
+	}
+	summary.QuarantinedFiles++
+	p.logger.WithField("file_path", result.FilePath).WithField("quarantine_path", dest).Info("File quarantined")
+}
+
+// processFileWithRetries processes a single file with retry logic. Only transient I/O failures
+// are retried, with exponential backoff; invalid files fail on the first attempt.
+// It gives up with the context error when ctx is cancelled between attempts or during the backoff.
+func (p *Processor) processFileWithRetries(ctx context.Context, filePath string) *models.ProcessingResult {
 	result := &models.ProcessingResult{
 		FilePath: filePath,
 	}
@@ -236,46 +501,166 @@ func (p *Processor) processFileWithRetries(filePath string) *models.ProcessingRe
 
 	var lastErr error
 	for attempt := 1; attempt <= p.config.Processing.RetryAttempts; attempt++ {
-		studentData, err := p.reader.ReadStudentData(filePath)
+		if err := ctx.Err(); err != nil {
+			result.Error = err
+			return result
+		}
+
+		studentData, err := p.readWithTimeout(ctx, filePath)
 		if err == nil {
 			result.Success = true
 			result.StudentData = studentData
-
-			p.logger.LogFileProcessed(
-				filePath,
-				studentData.StudentID,
-				studentData.GetMarkCount(),
-				result.Duration,
-			)
 			return result
 		}
 
 		lastErr = err
+		if !models.IsRetryable(err) {
+			break
+		}
 		if attempt < p.config.Processing.RetryAttempts {
 			p.logger.LogRetry(filePath, attempt, p.config.Processing.RetryAttempts, err)
-			time.Sleep(time.Duration(attempt) * time.Second) // Exponential backoff
+			if err := sleepContext(ctx, p.retryDelay(attempt)); err != nil {
+				result.Error = err
+				return result
+			}
 		}
 	}
 
 	result.Success = false
 	result.Error = lastErr
-	p.logger.LogFileError(filePath, lastErr, "processing")
 
 	return result
 }
 
+// readWithTimeout reads a student file under the per-file timeout. Excel files cannot be read
+// with a context, so a read that overruns is abandoned in the background and reported as a
+// timeout while the worker moves on to the next file. Once max_concurrent_files abandoned reads
+// are still running, the worker waits for the read to end before reporting the timeout.
+func (p *Processor) readWithTimeout(ctx context.Context, filePath string) (*models.StudentData, error) {
+	timeout := p.config.Processing.FileTimeout()
+	readCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type readResult struct {
+		data *models.StudentData
+		err  error
+	}
+	done := make(chan readResult, 1)
+	var state atomic.Int32
+	go func() {
+		data, err := p.readFile(filePath)
+		done <- readResult{data: data, err: err}
+		if !state.CompareAndSwap(readRunning, readFinished) {
+			abandonedReads.Add(-1)
+		}
+	}()
+
+	select {
+	case result := <-done:
+		return result.data, result.err
+	case <-readCtx.Done():
+	}
+
+	if err := ctx.Err(); err != nil {
+		p.abandonRead(&state)
+		return nil, err
+	}
+	timeoutErr := &models.FileProcessingError{
+		FilePath: filePath,
+		Stage:    models.StageTimeout,
+		Message:  fmt.Sprintf("reading the file took longer than %v", timeout),
+		Cause:    readCtx.Err(),
+	}
+	if p.abandonRead(&state) {
+		p.logger.WithField("file_path", filePath).WithField("abandoned_reads", abandonedReads.Load()).
+			Warn("Abandoned a file read that overran its timeout")
+		return nil, timeoutErr
+	}
+
+	p.logger.WithField("file_path", filePath).WithField("abandoned_reads", abandonedReads.Load()).
+		Warn("Too many abandoned file reads still running, waiting for this read to end")
+	select {
+	case <-done:
+		return nil, timeoutErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// States of a read started by readWithTimeout
+const (
+	readRunning int32 = iota
+	readFinished
+	readAbandoned
+)
+
+// abandonRead counts a read that is still running as abandoned, reporting false when
+// max_concurrent_files abandoned reads are already running
+func (p *Processor) abandonRead(state *atomic.Int32) bool {
+	limit := int32(p.config.Processing.MaxConcurrentFiles)
+	if limit < 1 {
+		limit = 1
+	}
+	for {
+		n := abandonedReads.Load()
+		if n >= limit {
+			return false
+		}
+		if abandonedReads.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+	if !state.CompareAndSwap(readRunning, readAbandoned) {
+		abandonedReads.Add(-1) // Finished in the meantime
+	}
+	return true
+}
+
+// retryDelay returns the backoff after a failed attempt, shortened by a random part of up to
+// retry_jitter so that files failing together are not retried in lockstep
+func (p *Processor) retryDelay(attempt int) time.Duration {
+	delay := p.config.Processing.RetryBackoff(attempt)
+	if jitter := p.config.Processing.RetryJitter; jitter > 0 {
+		delay -= time.Duration(mathrand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// sleepContext waits for the duration, returning the context error early if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newRunID generates a unique, time-ordered identifier for a processing run
+func newRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().Format("20060102-150405.000")
+	}
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
 // GetProcessingStatistics returns current processing statistics
 func (p *Processor) GetProcessingStatistics() map[string]interface{} {
 	stats := make(map[string]interface{})
 
 	// Count total files
-	excelFiles, err := p.findExcelFiles(p.config.Paths.StudentFilesFolder)
+	excelFiles, skippedPaths, err := p.findExcelFiles(context.Background(), p.config.Paths.StudentFilesFolder)
 	if err != nil {
 		stats["error"] = err.Error()
 		return stats
 	}
 
 	stats["total_excel_files"] = len(excelFiles)
+	stats["skipped_paths"] = len(skippedPaths)
 	stats["student_files_folder"] = p.config.Paths.StudentFilesFolder
 	stats["master_sheet_path"] = p.config.Paths.MasterSheetPath
 	stats["max_concurrent_files"] = p.config.Processing.MaxConcurrentFiles
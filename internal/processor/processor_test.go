@@ -1,7 +1,10 @@
 package processor
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,6 +13,8 @@ import (
 	"github.com/xuri/excelize/v2"
 
 	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/journal"
+	"mark-master-sheet/internal/lock"
 	"mark-master-sheet/internal/logger"
 )
 
@@ -292,8 +297,140 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+// TestProcessFilesSavesJournal tests that a production run records its cell writes
+func TestProcessFilesSavesJournal(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ProcessFiles() unexpected error: %v", err)
+	}
+
+	if summary.JournalPath == "" {
+		t.Fatal("ProcessFiles() should report the journal path")
+	}
+	j, err := journal.Load(summary.JournalPath)
+	if err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	if j.RunID != summary.RunID {
+		t.Errorf("journal run ID = %s, want %s", j.RunID, summary.RunID)
+	}
+	// STU001 and STU002 are in the master with three marks each
+	if len(j.Entries) != 6 {
+		t.Errorf("journal has %d entries, want 6", len(j.Entries))
+	}
+}
+
+// TestProcessFilesRespectsLock tests refusing to write while another run holds the master lock
+func TestProcessFilesRespectsLock(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+
+	held, err := lock.Acquire(cfg.Paths.MasterSheetPath, "other run", 0)
+	if err != nil {
+		t.Fatalf("Failed to take lock: %v", err)
+	}
+
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	if _, err := processor.ProcessFiles(context.Background(), false); !errors.Is(err, lock.ErrLocked) {
+		t.Errorf("ProcessFiles() error = %v, want ErrLocked", err)
+	}
+
+	// Dry runs do not write and ignore the lock
+	if _, err := processor.ProcessFiles(context.Background(), true); err != nil {
+		t.Errorf("ProcessFiles() dry run error = %v", err)
+	}
+
+	held.Release()
+	if _, err := processor.ProcessFiles(context.Background(), false); err != nil {
+		t.Fatalf("ProcessFiles() after release error = %v", err)
+	}
+	if _, err := os.Stat(lock.Path(cfg.Paths.MasterSheetPath)); !os.IsNotExist(err) {
+		t.Error("ProcessFiles() left its lock file behind")
+	}
+}
+
+// TestProcessFilesFromArchive tests reading submissions from a bulk ZIP with nested ZIPs
+func TestProcessFilesFromArchive(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	studentDir := createTestStudentFiles(t, tempDir)
+
+	read := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join(studentDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read student file: %v", err)
+		}
+		return data
+	}
+	nested := createTestZip(t, map[string][]byte{"grading.xlsx": read("STU002.xlsx")})
+	bulk := filepath.Join(tempDir, "bulk.zip")
+	if err := os.WriteFile(bulk, createTestZip(t, map[string][]byte{
+		"STU001/grading.xlsx":            read("STU001.xlsx"),
+		"__MACOSX/STU001/._grading.xlsx": []byte("resource fork"),
+		"STU002.zip":                     nested,
+	}), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	cfg.Paths.StudentFilesFolder = bulk
+
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+	if summary.TotalFiles != 2 || summary.SuccessfulFiles != 2 || summary.StudentsUpdated != 2 {
+		t.Errorf("total/successful/updated = %d/%d/%d, want 2/2/2",
+			summary.TotalFiles, summary.SuccessfulFiles, summary.StudentsUpdated)
+	}
+	if len(summary.SkippedPaths) != 1 || summary.SkippedPaths[0].Path != bulk+"!/__MACOSX" {
+		t.Errorf("SkippedPaths = %+v, want the __MACOSX folder", summary.SkippedPaths)
+	}
+
+	j, err := journal.Load(summary.JournalPath)
+	if err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	sources := make(map[string]bool)
+	for _, entry := range j.Entries {
+		sources[entry.SourceFile] = true
+	}
+	for _, want := range []string{bulk + "!/STU001/grading.xlsx", bulk + "!/STU002.zip!/grading.xlsx"} {
+		if !sources[want] {
+			t.Errorf("journal sources %v missing %s", sources, want)
+		}
+	}
+}
+
 // Helper functions for creating test files and configurations
 
+func createTestZip(t testing.TB, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s to archive: %v", name, err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to finish archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
 func createTestConfig(tempDir string) *config.Config {
 	return &config.Config{
 		Paths: config.PathsConfig{
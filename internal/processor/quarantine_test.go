@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/quarantine"
+)
+
+// TestProcessFilesQuarantine tests that files failing validation are moved out of later runs
+func TestProcessFilesQuarantine(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+	cfg.Quarantine.Mode = config.QuarantineMove
+
+	late := filepath.Join(cfg.Paths.StudentFilesFolder, "late")
+	if err := os.MkdirAll(late, 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	invalid := createTestStudentFile(t, late, "STU-004")
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	// Dry runs leave the file where it is
+	summary, err := processor.ProcessFiles(context.Background(), true)
+	if err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if summary.QuarantinedFiles != 0 {
+		t.Errorf("dry run quarantined %d files, want none", summary.QuarantinedFiles)
+	}
+
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+	if summary.QuarantinedFiles != 1 || summary.SkippedFiles != 1 {
+		t.Errorf("quarantined/skipped = %d/%d, want 1/1", summary.QuarantinedFiles, summary.SkippedFiles)
+	}
+	if _, err := os.Stat(invalid); !os.IsNotExist(err) {
+		t.Errorf("invalid file still in the student folder: %v", err)
+	}
+
+	entries, err := quarantine.List(cfg.QuarantineFolder())
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("quarantine = %v, %v, want the invalid file", entries, err)
+	}
+	if reason := entries[0].Reason; reason.RelativePath != "late/STU-004.xlsx" || reason.Field != "student_id" {
+		t.Errorf("reason = %+v, want the student ID validation of late/STU-004.xlsx", reason)
+	}
+
+	// The next run no longer sees it
+	summary, err = processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("second run error = %v", err)
+	}
+	if summary.TotalFiles != 3 || summary.SkippedFiles != 0 {
+		t.Errorf("second run total/skipped = %d/%d, want 3/0", summary.TotalFiles, summary.SkippedFiles)
+	}
+}
@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mark-master-sheet/internal/checkpoint"
+	"mark-master-sheet/pkg/models"
+)
+
+// interruptedRun runs the processor until it starts reading STU003.xlsx, leaving a checkpoint of the files before it
+func interruptedRun(t *testing.T, processor *Processor) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	processor.readFile = func(path string) (*models.StudentData, error) {
+		if filepath.Base(path) == "STU003.xlsx" {
+			cancel()
+			return nil, context.Canceled
+		}
+		return processor.reader.ReadStudentData(path)
+	}
+
+	processor.SetResume(false)
+	if _, err := processor.ProcessFiles(ctx, false); err != context.Canceled {
+		t.Fatalf("interrupted run error = %v, want context.Canceled", err)
+	}
+}
+
+// TestProcessFilesResume tests continuing an interrupted run from its checkpoint
+func TestProcessFilesResume(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+	cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+	cfg.Processing.MaxConcurrentFiles = 1
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+	checkpointPath := checkpoint.Path(cfg.Paths.OutputFolder)
+
+	interruptedRun(t, processor)
+	if cp, err := checkpoint.Load(checkpointPath); err != nil || cp.Len() != 2 {
+		t.Fatalf("checkpoint after interruption = %v, %v, want the 2 files read", cp, err)
+	}
+
+	// Only the file not yet read is read again
+	var reads []string
+	processor.readFile = func(path string) (*models.StudentData, error) {
+		reads = append(reads, filepath.Base(path))
+		return processor.reader.ReadStudentData(path)
+	}
+	processor.SetResume(true)
+	summary, err := processor.ProcessFiles(context.Background(), false)
+	if err != nil {
+		t.Fatalf("resumed run error = %v", err)
+	}
+	if len(reads) != 1 || reads[0] != "STU003.xlsx" {
+		t.Errorf("resumed run read %v, want only STU003.xlsx", reads)
+	}
+	if summary.ResumedFiles != 2 || summary.SuccessfulFiles != 3 || summary.StudentsUpdated != 2 {
+		t.Errorf("resumed/successful/updated = %d/%d/%d, want 2/3/2",
+			summary.ResumedFiles, summary.SuccessfulFiles, summary.StudentsUpdated)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still present after the resumed run wrote the master sheet: %v", err)
+	}
+
+	// Nothing is left to resume
+	if _, err := processor.ProcessFiles(context.Background(), false); !errors.Is(err, checkpoint.ErrNoCheckpoint) {
+		t.Errorf("second resume error = %v, want %v", err, checkpoint.ErrNoCheckpoint)
+	}
+}
+
+// TestProcessFilesResumeRefused tests that a run is not resumed after the configuration or master sheet changed
+func TestProcessFilesResumeRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, processor *Processor)
+		wantErr error
+	}{
+		{
+			name: "config changed",
+			change: func(t *testing.T, processor *Processor) {
+				processor.config.Excel.MarkCells = processor.config.Excel.MarkCells[:1]
+				processor.config.Excel.MasterColumns = processor.config.Excel.MasterColumns[:1]
+			},
+			wantErr: checkpoint.ErrConfigChanged,
+		},
+		{
+			name: "master changed",
+			change: func(t *testing.T, processor *Processor) {
+				f, err := os.OpenFile(processor.config.Paths.MasterSheetPath, os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatalf("Failed to open master: %v", err)
+				}
+				f.WriteString("edited")
+				f.Close()
+			},
+			wantErr: checkpoint.ErrMasterChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := createTestConfig(tempDir)
+			cfg.Paths.MasterSheetPath = createTestMasterFile(t, tempDir)
+			cfg.Paths.StudentFilesFolder = createTestStudentFiles(t, tempDir)
+			cfg.Processing.MaxConcurrentFiles = 1
+			processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+			interruptedRun(t, processor)
+			tt.change(t, processor)
+
+			processor.SetResume(true)
+			summary, err := processor.ProcessFiles(context.Background(), false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resume error = %v, want %v", err, tt.wantErr)
+			}
+			if summary.SuccessfulFiles != 0 {
+				t.Errorf("refused resume read %d files, want none", summary.SuccessfulFiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"mark-master-sheet/pkg/models"
+)
+
+// transientError is the error of a file that is locked by another program
+func transientError(path string) error {
+	return &models.FileProcessingError{
+		FilePath: path,
+		Stage:    "opening",
+		Message:  "failed to open Excel file",
+		Cause:    &fs.PathError{Op: "open", Path: path, Err: syscall.EBUSY},
+	}
+}
+
+// TestProcessFileWithRetriesClassification tests that only retryable errors are read again
+func TestProcessFileWithRetriesClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       func(path string) error
+		wantReads int
+	}{
+		{
+			name: "empty student ID",
+			err: func(path string) error {
+				return &models.ValidationError{Field: "student_id", Message: "student ID is empty", File: path}
+			},
+			wantReads: 1,
+		},
+		{
+			name: "missing worksheet",
+			err: func(path string) error {
+				return &models.FileProcessingError{FilePath: path, Stage: "worksheet_validation", Message: "worksheet 'Grading Sheet' not found"}
+			},
+			wantReads: 1,
+		},
+		{name: "file in use", err: transientError, wantReads: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := createTestConfig(tempDir)
+			cfg.Processing.RetryAttempts = 3
+			cfg.Processing.RetryBackoffSeconds = 0.001
+			cfg.Processing.RetryJitter = 0.5
+			processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+			reads := 0
+			processor.readFile = func(path string) (*models.StudentData, error) {
+				reads++
+				return nil, tt.err(path)
+			}
+
+			result := processor.processFileWithRetries(context.Background(), "student.xlsx")
+			if result.Success || result.Error == nil {
+				t.Fatalf("processFileWithRetries() = %+v, want a failure", result)
+			}
+			if reads != tt.wantReads {
+				t.Errorf("file read %d times, want %d", reads, tt.wantReads)
+			}
+		})
+	}
+}
+
+// TestRetryDelayJitter tests that jitter only shortens the exponential backoff
+func TestRetryDelayJitter(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := createTestConfig(tempDir)
+	cfg.Processing.RetryJitter = 0.25
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		full := cfg.Processing.RetryBackoff(attempt)
+		for i := 0; i < 20; i++ {
+			if got := processor.retryDelay(attempt); got > full || got < full*3/4 {
+				t.Fatalf("retryDelay(%d) = %v, want between %v and %v", attempt, got, full*3/4, full)
+			}
+		}
+	}
+}
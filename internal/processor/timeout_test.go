@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+// TestProcessFilesConcurrentlyFileTimeout tests that a file overrunning its timeout fails without holding up the others
+func TestProcessFilesConcurrentlyFileTimeout(t *testing.T) {
+	t.Cleanup(func() { waitForAbandonedReads(t) })
+	tempDir := t.TempDir()
+	studentDir := createTestStudentFiles(t, tempDir)
+	files, _, err := discoverFiles(context.Background(), studentDir, config.DiscoveryConfig{}, func(string, error) {})
+	if err != nil {
+		t.Fatalf("discoverFiles() error = %v", err)
+	}
+
+	cfg := createTestConfig(tempDir)
+	cfg.Processing.MaxConcurrentFiles = 1
+	cfg.Processing.RetryAttempts = 3
+	cfg.Processing.FileTimeoutSeconds = 0.1
+	cfg.Processing.SkipInvalidFiles = false
+	processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+
+	hung := files[0]
+	release := make(chan struct{})
+	defer close(release)
+	processor.readFile = func(path string) (*models.StudentData, error) {
+		if path == hung {
+			<-release
+		}
+		return processor.reader.ReadStudentData(path)
+	}
+
+	start := time.Now()
+	students, summary := processor.processFilesConcurrently(context.Background(), files)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("processing took %v, want the hung file abandoned after its timeout", elapsed)
+	}
+
+	if len(students) != len(files)-1 || summary.FailedFiles != 1 {
+		t.Fatalf("students = %d, failed = %d, want %d and 1", len(students), summary.FailedFiles, len(files)-1)
+	}
+	if len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0], "at timeout stage for "+hung) {
+		t.Errorf("errors = %v, want a timeout for %s", summary.Errors, hung)
+	}
+}
+
+// TestReadWithTimeoutCancelled tests that cancelling the run ends a read without reporting a timeout
+func TestReadWithTimeoutCancelled(t *testing.T) {
+	t.Cleanup(func() { waitForAbandonedReads(t) })
+	tempDir := t.TempDir()
+	processor := NewProcessor(createTestConfig(tempDir), createTestLogger(t, tempDir))
+
+	release := make(chan struct{})
+	defer close(release)
+	processor.readFile = func(path string) (*models.StudentData, error) {
+		<-release
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := processor.readWithTimeout(ctx, "student.xlsx"); !errors.Is(err, context.Canceled) {
+		t.Errorf("readWithTimeout() error = %v, want context.Canceled", err)
+	}
+}
+
+// TestReadWithTimeoutAbandonedLimit tests that no more than max_concurrent_files overrunning reads are
+// abandoned, also across processors, as watch mode and the GUI create one for every run
+func TestReadWithTimeoutAbandonedLimit(t *testing.T) {
+	t.Cleanup(func() { waitForAbandonedReads(t) })
+	release := make(chan struct{})
+	newProcessor := func() *Processor {
+		tempDir := t.TempDir()
+		cfg := createTestConfig(tempDir)
+		cfg.Processing.MaxConcurrentFiles = 1
+		cfg.Processing.FileTimeoutSeconds = 0.05
+		processor := NewProcessor(cfg, createTestLogger(t, tempDir))
+		processor.readFile = func(path string) (*models.StudentData, error) {
+			<-release
+			return nil, nil
+		}
+		return processor
+	}
+
+	// The first run abandons its read, the next run waits as the only slot is taken
+	if _, err := newProcessor().readWithTimeout(context.Background(), "first.xlsx"); err == nil {
+		t.Fatal("readWithTimeout() error = nil, want a timeout")
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := newProcessor().readWithTimeout(context.Background(), "second.xlsx")
+		second <- err
+	}()
+	select {
+	case err := <-second:
+		t.Fatalf("second read returned %v while the first was still running", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	var fileErr *models.FileProcessingError
+	if err := <-second; !errors.As(err, &fileErr) || fileErr.Stage != models.StageTimeout {
+		t.Errorf("second read error = %v, want a timeout", err)
+	}
+}
+
+// waitForAbandonedReads waits until the reads abandoned by a test have ended, so that they do
+// not count against the limit of the next test
+func waitForAbandonedReads(t *testing.T) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); abandonedReads.Load() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("abandoned reads = %d after the reads ended, want 0", abandonedReads.Load())
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package quarantine sets aside student files that fail validation, so that they stop failing
+// every run, and re-admits them once fixed. Quarantined files keep their layout relative to the
+// student files folder and each gets a sidecar file explaining why it was set aside.
+package quarantine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mark-master-sheet/internal/archive"
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/internal/fileutil"
+	"mark-master-sheet/pkg/models"
+)
+
+// ReasonSuffix is appended to the name of a quarantined file to name its sidecar
+const ReasonSuffix = ".reason.json"
+
+// ErrNewerSubmission is returned when re-admitting a file whose original location holds a newer file
+var ErrNewerSubmission = errors.New("a newer file exists at the original location")
+
+// ErrArchiveEntry is returned when re-admitting a file that came from inside an archive
+var ErrArchiveEntry = errors.New("file came from inside an archive")
+
+// Reason is the content of the sidecar of a quarantined file
+type Reason struct {
+	Source        string    `json:"source"`
+	RelativePath  string    `json:"relative_path"`
+	Mode          string    `json:"mode"`
+	ArchiveEntry  bool      `json:"archive_entry,omitempty"`
+	SourceSHA256  string    `json:"source_sha256,omitempty"`
+	Stage         string    `json:"stage,omitempty"`
+	Field         string    `json:"field,omitempty"`
+	Message       string    `json:"message"`
+	Error         string    `json:"error"`
+	RunID         string    `json:"run_id,omitempty"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Entry is a quarantined file with its reason
+type Entry struct {
+	Path   string
+	Reason Reason
+}
+
+// Quarantine sets aside the failing files of a student files folder
+type Quarantine struct {
+	dir   string
+	root  string
+	mode  string
+	runID string
+}
+
+// New creates a quarantine in dir for the files below root, moving or copying them
+// according to mode (config.QuarantineMove or config.QuarantineCopy) on behalf of a run
+func New(dir, root, mode, runID string) *Quarantine {
+	return &Quarantine{dir: dir, root: filepath.Clean(root), mode: mode, runID: runID}
+}
+
+// Eligible reports whether a file failing with err belongs in quarantine. Only files that
+// will fail again unchanged are set aside: not transient I/O failures, timeouts or missing files.
+func Eligible(err error) bool {
+	if err == nil || models.IsRetryable(err) || errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	var fileErr *models.FileProcessingError
+	if errors.As(err, &fileErr) && (fileErr.Stage == models.StageTimeout || errors.Is(fileErr.Cause, fs.ErrNotExist)) {
+		return false
+	}
+	return true
+}
+
+// Describe returns the stage, field and message of a validation or processing error
+func Describe(err error) (stage, field, message string) {
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		return "validation", validationErr.Field, validationErr.Message
+	}
+	var fileErr *models.FileProcessingError
+	if errors.As(err, &fileErr) {
+		return fileErr.Stage, "", fileErr.Message
+	}
+	return "", "", err.Error()
+}
+
+// Add sets aside a failing file and writes its sidecar, returning the quarantined path.
+// Archive entries cannot be removed from their archive, so they are always copied. A file
+// already quarantined is kept in copy mode, where it may be in the middle of being fixed.
+func (q *Quarantine) Add(path string, cause error) (string, error) {
+	rel := q.relative(path)
+	dest := filepath.Join(q.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine folder: %w", err)
+	}
+
+	reason := Reason{
+		Source:        path,
+		RelativePath:  rel,
+		Mode:          q.mode,
+		ArchiveEntry:  archive.IsArchivePath(path),
+		Error:         cause.Error(),
+		RunID:         q.runID,
+		QuarantinedAt: time.Now(),
+	}
+	reason.Stage, reason.Field, reason.Message = Describe(cause)
+
+	// Remember the content of the source, which a copied file leaves in place
+	if !reason.ArchiveEntry {
+		hash, _, err := fileutil.HashFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		reason.SourceSHA256 = hash
+	}
+
+	_, statErr := os.Stat(dest)
+	exists := statErr == nil
+	switch {
+	case reason.ArchiveEntry:
+		reason.Mode = config.QuarantineCopy
+		if !exists {
+			data, err := archive.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to extract %s: %w", path, err)
+			}
+			if err := os.WriteFile(dest, data, 0644); err != nil {
+				return "", fmt.Errorf("failed to write quarantined file: %w", err)
+			}
+		}
+	case q.mode == config.QuarantineMove:
+		if err := move(path, dest); err != nil {
+			return "", err
+		}
+	default:
+		if !exists {
+			if _, _, err := fileutil.CopyFile(path, dest); err != nil {
+				return "", fmt.Errorf("failed to copy %s to quarantine: %w", path, err)
+			}
+		}
+	}
+
+	if err := writeReason(dest, reason); err != nil {
+		return dest, err
+	}
+	return dest, nil
+}
+
+// relative returns the slash-separated path of a file below the root, with the archive
+// separators of archive entries turned into folders
+func (q *Quarantine) relative(path string) string {
+	var rel string
+	switch {
+	case strings.HasPrefix(path, q.root+string(filepath.Separator)):
+		rel = filepath.ToSlash(path[len(q.root)+1:])
+	case strings.HasPrefix(path, q.root+archive.Separator):
+		// The student files folder is itself an archive
+		rel = filepath.Base(q.root) + "/" + path[len(q.root)+len(archive.Separator):]
+	default:
+		rel = filepath.Base(path)
+	}
+	return strings.ReplaceAll(rel, archive.Separator, "/")
+}
+
+// List returns the quarantined files in dir, sorted by path. Sidecars whose file was
+// removed by hand are ignored.
+func List(dir string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ReasonSuffix) {
+			return nil
+		}
+
+		file := strings.TrimSuffix(path, ReasonSuffix)
+		if _, err := os.Stat(file); err != nil {
+			return nil
+		}
+		reason, err := readReason(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{Path: file, Reason: *reason})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quarantine: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// Readmit returns a fixed file to its original location and removes its sidecar. A file
+// is not put back over a newer submission: a moved file only where nothing took its place,
+// a copied file only over an original still holding the content it was quarantined with.
+// Files extracted from archives have to be replaced inside the archive by hand.
+func Readmit(entry Entry) error {
+	if entry.Reason.ArchiveEntry {
+		return ErrArchiveEntry
+	}
+	if _, err := os.Stat(entry.Reason.Source); err == nil {
+		if entry.Reason.Mode == config.QuarantineMove || entry.Reason.SourceSHA256 == "" {
+			return ErrNewerSubmission
+		}
+		hash, _, err := fileutil.HashFile(entry.Reason.Source)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", entry.Reason.Source, err)
+		}
+		if hash != entry.Reason.SourceSHA256 {
+			return ErrNewerSubmission
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entry.Reason.Source), 0755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %w", entry.Reason.Source, err)
+	}
+	if err := move(entry.Path, entry.Reason.Source); err != nil {
+		return err
+	}
+	if err := os.Remove(entry.Path + ReasonSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove quarantine reason: %w", err)
+	}
+	return nil
+}
+
+// move renames a file, copying it when source and destination are on different volumes
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if _, _, err := fileutil.CopyFile(src, dst); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", src, dst, err)
+	}
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("failed to remove %s after copying it: %w", src, err)
+	}
+	return nil
+}
+
+// writeReason writes the sidecar of a quarantined file
+func writeReason(file string, reason Reason) error {
+	data, err := json.MarshalIndent(reason, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine reason: %w", err)
+	}
+	if err := os.WriteFile(file+ReasonSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine reason: %w", err)
+	}
+	return nil
+}
+
+// readReason reads the sidecar of a quarantined file
+func readReason(path string) (*Reason, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var reason Reason
+	if err := json.Unmarshal(data, &reason); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &reason, nil
+}
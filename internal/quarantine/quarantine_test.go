@@ -0,0 +1,166 @@
+package quarantine
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"mark-master-sheet/internal/config"
+	"mark-master-sheet/pkg/models"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// TestAddAndReadmit tests setting aside a file with its reason and putting it back
+func TestAddAndReadmit(t *testing.T) {
+	for _, mode := range []string{config.QuarantineMove, config.QuarantineCopy} {
+		t.Run(mode, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "students")
+			dir := filepath.Join(t.TempDir(), "quarantine")
+			source := filepath.Join(root, "alice", "grading.xlsx")
+			writeFile(t, source, []byte("broken"))
+
+			cause := &models.ValidationError{Field: "student_id", Message: "student ID is empty", File: source}
+			dest, err := New(dir, root, mode, "run-1").Add(source, cause)
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if want := filepath.Join(dir, "alice", "grading.xlsx"); dest != want {
+				t.Errorf("Add() = %s, want %s", dest, want)
+			}
+			if _, err := os.Stat(source); (err == nil) != (mode == config.QuarantineCopy) {
+				t.Errorf("source exists = %v after %s", err == nil, mode)
+			}
+
+			entries, err := List(dir)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("List() = %v, %v, want the quarantined file", entries, err)
+			}
+			reason := entries[0].Reason
+			if reason.Stage != "validation" || reason.Field != "student_id" || reason.Message != "student ID is empty" ||
+				reason.RelativePath != "alice/grading.xlsx" || reason.RunID != "run-1" {
+				t.Errorf("reason = %+v", reason)
+			}
+
+			// Fix the file and put it back
+			writeFile(t, dest, []byte("fixed"))
+			if err := Readmit(entries[0]); err != nil {
+				t.Fatalf("Readmit() error = %v", err)
+			}
+			if data, _ := os.ReadFile(source); string(data) != "fixed" {
+				t.Errorf("source = %q after Readmit(), want the fixed file", data)
+			}
+			if entries, _ := List(dir); len(entries) != 0 {
+				t.Errorf("List() after Readmit() = %v, want empty", entries)
+			}
+		})
+	}
+}
+
+// TestReadmitNewerSubmission tests that neither a moved nor a copied file replaces a newer submission
+func TestReadmitNewerSubmission(t *testing.T) {
+	for _, mode := range []string{config.QuarantineMove, config.QuarantineCopy} {
+		t.Run(mode, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(t.TempDir(), "quarantine")
+			source := filepath.Join(root, "grading.xlsx")
+			writeFile(t, source, []byte("broken"))
+
+			dest, err := New(dir, root, mode, "").Add(source, errors.New("invalid"))
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			writeFile(t, dest, []byte("fixed in quarantine"))
+			writeFile(t, source, []byte("resubmitted"))
+
+			entries, _ := List(dir)
+			if err := Readmit(entries[0]); !errors.Is(err, ErrNewerSubmission) {
+				t.Errorf("Readmit() error = %v, want %v", err, ErrNewerSubmission)
+			}
+			if data, _ := os.ReadFile(source); string(data) != "resubmitted" {
+				t.Errorf("source = %q after Readmit(), want the newer submission kept", data)
+			}
+		})
+	}
+}
+
+// TestAddArchiveEntry tests that files inside archives are copied and cannot be re-admitted
+func TestAddArchiveEntry(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "quarantine")
+	bulk := filepath.Join(root, "bulk.zip")
+
+	f, err := os.Create(bulk)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	w := zip.NewWriter(f)
+	entry, _ := w.Create("alice/grading.xlsx")
+	fmt.Fprint(entry, "broken")
+	w.Close()
+	f.Close()
+
+	dest, err := New(dir, root, config.QuarantineMove, "").Add(bulk+"!/alice/grading.xlsx", errors.New("invalid"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "broken" || dest != filepath.Join(dir, "bulk.zip", "alice", "grading.xlsx") {
+		t.Errorf("Add() = %s containing %q, want the extracted entry", dest, data)
+	}
+	if _, err := os.Stat(bulk); err != nil {
+		t.Errorf("archive removed: %v", err)
+	}
+
+	entries, _ := List(dir)
+	if len(entries) != 1 || entries[0].Reason.Mode != config.QuarantineCopy || !entries[0].Reason.ArchiveEntry {
+		t.Fatalf("List() = %+v, want a copied archive entry", entries)
+	}
+	if err := Readmit(entries[0]); !errors.Is(err, ErrArchiveEntry) {
+		t.Errorf("Readmit() error = %v, want %v", err, ErrArchiveEntry)
+	}
+}
+
+// TestEligible tests which failures send a file to quarantine
+func TestEligible(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"validation error", &models.ValidationError{Field: "student_id"}, true},
+		{"missing worksheet", &models.FileProcessingError{Stage: "worksheet_validation"}, true},
+		{"corrupt workbook", &models.FileProcessingError{Stage: "opening", Cause: errors.New("zip: not a valid zip file")}, true},
+		{"timeout", &models.FileProcessingError{Stage: models.StageTimeout}, false},
+		{"file in use", &models.FileProcessingError{Stage: "opening", Cause: &fs.PathError{Op: "open", Err: syscall.EBUSY}}, false},
+		{"file gone", &models.FileProcessingError{Stage: "opening", Cause: &fs.PathError{Op: "open", Err: fs.ErrNotExist}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Eligible(tt.err); got != tt.want {
+				t.Errorf("Eligible(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestListMissingFolder tests listing before anything was quarantined
+func TestListMissingFolder(t *testing.T) {
+	entries, err := List(filepath.Join(t.TempDir(), "quarantine"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("List() = %v, %v, want no entries", entries, err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package state records the student files read by earlier runs of the Mark Master Sheet
+// Consolidator, with their size, modification time, content hash and marks, so that
+// incremental runs only re-read files that changed and only write marks that differ.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mark-master-sheet/internal/archive"
+	"mark-master-sheet/internal/config"
+)
+
+// FileName is the name of the state file inside the output folder
+const FileName = "processing_state.json"
+
+// Version is the format version of the state file
+const Version = 1
+
+// FileState describes a student file as it was when its marks were written
+type FileState struct {
+	Path        string             `json:"path"`
+	Size        int64              `json:"size"`
+	ModTime     time.Time          `json:"mod_time"`
+	SHA256      string             `json:"sha256"`
+	StudentID   string             `json:"student_id,omitempty"`
+	StudentName string             `json:"student_name,omitempty"`
+	Marks       map[string]float64 `json:"marks,omitempty"`
+	RunID       string             `json:"run_id,omitempty"`
+}
+
+// State lists the student files written to a master sheet
+type State struct {
+	Version         int                   `json:"version"`
+	MasterSheetPath string                `json:"master_sheet_path"`
+	SettingsHash    string                `json:"settings_hash"`
+	MasterHash      string                `json:"master_hash,omitempty"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	Files           map[string]*FileState `json:"files"`
+}
+
+// New creates an empty state for a master sheet and Excel settings hash
+func New(masterSheetPath, settingsHash string) *State {
+	return &State{
+		Version:         Version,
+		MasterSheetPath: masterSheetPath,
+		SettingsHash:    settingsHash,
+		Files:           make(map[string]*FileState),
+	}
+}
+
+// Path returns the state file path for an output folder
+func Path(outputFolder string) string {
+	return filepath.Join(outputFolder, FileName)
+}
+
+// Load reads a state file. A missing file is returned as a nil state.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]*FileState)
+	}
+
+	return &s, nil
+}
+
+// Save writes the state file, replacing any previous version atomically
+func (s *State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
+
+// Matches reports whether the state was recorded for the master sheet and Excel settings,
+// so that its marks can be compared with the current files
+func (s *State) Matches(masterSheetPath, settingsHash string) bool {
+	if s == nil || s.Version != Version || s.SettingsHash != settingsHash {
+		return false
+	}
+	absA, errA := filepath.Abs(s.MasterSheetPath)
+	absB, errB := filepath.Abs(masterSheetPath)
+	if errA != nil || errB != nil {
+		return filepath.Clean(s.MasterSheetPath) == filepath.Clean(masterSheetPath)
+	}
+	return absA == absB
+}
+
+// MasterChanged reports whether the master sheet content differs from the content written by
+// the recorded run, for example after an undo, a backup restore or an edit by hand. Its marks
+// then no longer tell what the master sheet holds.
+func (s *State) MasterChanged(masterHash string) bool {
+	return s.MasterHash == "" || s.MasterHash != masterHash
+}
+
+// Get returns the recorded state of a file, or nil
+func (s *State) Get(path string) *FileState {
+	if s == nil {
+		return nil
+	}
+	return s.Files[path]
+}
+
+// Record stores the state of a file
+func (s *State) Record(file *FileState) {
+	s.Files[file.Path] = file
+}
+
+// SettingsHash returns a hash of the Excel settings that decide which marks are read and how
+// they are written. A state recorded under different settings cannot be used incrementally.
+func SettingsHash(cfg *config.ExcelConfig) string {
+	data, _ := json.Marshal(struct {
+		StudentWorksheet string
+		MasterWorksheet  string
+		StudentIDCell    string
+		StudentNameCell  string
+		MarkCells        []string
+		MasterColumns    []string
+		Rounding         config.RoundingConfig
+	}{
+		cfg.StudentWorksheetName,
+		cfg.MasterWorksheetName,
+		cfg.StudentIDCell,
+		cfg.StudentNameCell,
+		cfg.MarkCells,
+		cfg.MasterColumns,
+		cfg.Rounding,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Check fingerprints a file and reports whether it changed since the previous state.
+// Files whose size and modification time are unchanged are not hashed again; archive
+// entries are always hashed. For an unchanged file the previous marks are kept.
+func Check(path string, previous *FileState) (*FileState, bool, error) {
+	current := &FileState{Path: path}
+
+	if archive.IsArchivePath(path) {
+		data, err := archive.ReadFile(path)
+		if err != nil {
+			return nil, true, err
+		}
+		sum := sha256.Sum256(data)
+		current.Size = int64(len(data))
+		current.SHA256 = hex.EncodeToString(sum[:])
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to access %s: %w", path, err)
+		}
+		current.Size = info.Size()
+		current.ModTime = info.ModTime()
+
+		if previous != nil && previous.Size == current.Size && previous.ModTime.Equal(current.ModTime) {
+			kept := *previous
+			return &kept, false, nil
+		}
+		if current.SHA256, err = hashFile(path); err != nil {
+			return nil, true, err
+		}
+	}
+
+	if previous == nil || previous.SHA256 != current.SHA256 {
+		return current, true, nil
+	}
+
+	// Same content under a new modification time, e.g. copied again from the LMS
+	current.StudentID = previous.StudentID
+	current.StudentName = previous.StudentName
+	current.Marks = previous.Marks
+	current.RunID = previous.RunID
+	return current, false, nil
+}
+
+// hashFile returns the hex SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,108 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mark-master-sheet/internal/config"
+)
+
+// TestCheck tests detecting changed files by size, modification time and content hash
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "student.xlsx")
+	if err := os.WriteFile(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	first, changed, err := Check(path, nil)
+	if err != nil || !changed {
+		t.Fatalf("Check() new file = changed %v, error %v, want changed", changed, err)
+	}
+	if first.SHA256 == "" || first.Size != 5 {
+		t.Fatalf("Check() = %+v, want size and hash", first)
+	}
+	first.StudentID = "STU001"
+	first.Marks = map[string]float64{"C6": 10}
+
+	tests := []struct {
+		name        string
+		content     string
+		modTime     time.Time
+		wantChanged bool
+	}{
+		{name: "untouched", wantChanged: false},
+		{name: "same content, new time", content: "first", modTime: first.ModTime.Add(time.Hour), wantChanged: false},
+		{name: "new content", content: "other", modTime: first.ModTime.Add(2 * time.Hour), wantChanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+				if err := os.Chtimes(path, tt.modTime, tt.modTime); err != nil {
+					t.Fatalf("Failed to set times: %v", err)
+				}
+			}
+
+			current, changed, err := Check(path, first)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("Check() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !changed && (current.StudentID != "STU001" || current.Marks["C6"] != 10) {
+				t.Errorf("Check() = %+v, want the previous marks kept", current)
+			}
+		})
+	}
+}
+
+// TestSaveLoad tests the state file round trip and matching against the current settings
+func TestSaveLoad(t *testing.T) {
+	path := Path(t.TempDir())
+
+	missing, err := Load(path)
+	if err != nil || missing != nil {
+		t.Fatalf("Load() missing file = %v, %v, want nil state", missing, err)
+	}
+
+	excel := &config.ExcelConfig{MarkCells: []string{"C6"}, MasterColumns: []string{"I"}}
+	s := New("master.xlsx", SettingsHash(excel))
+	s.Record(&FileState{Path: "a.xlsx", SHA256: "abc", StudentID: "STU001", Marks: map[string]float64{"C6": 7.5}})
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := loaded.Get("a.xlsx"); got == nil || got.Marks["C6"] != 7.5 {
+		t.Errorf("Get() = %+v, want the recorded file", got)
+	}
+	if !loaded.Matches("master.xlsx", SettingsHash(excel)) {
+		t.Error("Matches() = false for the same master and settings")
+	}
+
+	excel.MasterColumns = []string{"J"}
+	if loaded.Matches("master.xlsx", SettingsHash(excel)) {
+		t.Error("Matches() = true after the master columns changed")
+	}
+	if loaded.Matches("other.xlsx", loaded.SettingsHash) {
+		t.Error("Matches() = true for another master sheet")
+	}
+
+	if !loaded.MasterChanged("abc") {
+		t.Error("MasterChanged() = false for a state recorded without a master hash")
+	}
+	loaded.MasterHash = "abc"
+	if loaded.MasterChanged("abc") || !loaded.MasterChanged("def") {
+		t.Error("MasterChanged() does not compare the master hash")
+	}
+}
@@ -0,0 +1,259 @@
+// Package watch monitors the student files folder of the Mark Master Sheet Consolidator and
+// reports batches of changed submissions once they have stopped changing.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"mark-master-sheet/internal/lock"
+)
+
+// Trigger handles a batch of changed paths. Returning an error keeps the paths pending,
+// so they are handed over again after the next debounce.
+type Trigger func(ctx context.Context, changed []string) error
+
+// Watcher watches a folder tree, or a single archive, for new and changed student files
+type Watcher struct {
+	root     string
+	file     string // the watched archive when the root is not a folder
+	debounce time.Duration
+	settle   time.Duration
+	fsw      *fsnotify.Watcher
+	onError  func(error)
+}
+
+// fileStamp is the size and modification time of a file, zero if it does not exist
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+	open    bool
+}
+
+// New starts watching root and every folder below it. Changes are reported once no event
+// arrived for the debounce interval and the files kept their size and time for the settle interval.
+func New(root string, debounce, settle time.Duration, onError func(error)) (*Watcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", root, err)
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start file watcher: %w", err)
+	}
+
+	w := &Watcher{
+		root:     filepath.Clean(root),
+		debounce: debounce,
+		settle:   settle,
+		fsw:      fsw,
+		onError:  onError,
+	}
+
+	if info.IsDir() {
+		w.addTree(w.root)
+		return w, nil
+	}
+
+	// A single archive is watched through its folder
+	w.file = w.root
+	if err := fsw.Add(filepath.Dir(w.root)); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", filepath.Dir(w.root), err)
+	}
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// Run hands batches of changed paths to trigger until ctx is cancelled. The trigger runs
+// on the watching goroutine, so changes made meanwhile are reported in the next batch.
+func (w *Watcher) Run(ctx context.Context, trigger Trigger) error {
+	pending := make(map[string]bool)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			if w.handle(event, pending) {
+				resetTimer(timer, w.debounce)
+			}
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			w.onError(err)
+
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+
+			stable, err := w.stable(ctx, paths)
+			if err != nil {
+				return nil
+			}
+			if !stable {
+				resetTimer(timer, w.debounce)
+				continue
+			}
+
+			pending = make(map[string]bool)
+			if err := trigger(ctx, paths); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				w.onError(err)
+				for _, path := range paths {
+					pending[path] = true
+				}
+				resetTimer(timer, w.debounce)
+			}
+		}
+	}
+}
+
+// handle adds the paths of a file system event to the pending set, reporting whether any were added
+func (w *Watcher) handle(event fsnotify.Event, pending map[string]bool) bool {
+	path := filepath.Clean(event.Name)
+	if w.file != "" {
+		if path != w.file || event.Op == fsnotify.Chmod {
+			return false
+		}
+		pending[path] = true
+		return true
+	}
+
+	if ignored(filepath.Base(path)) || event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	// Folders copied or created inside the tree are watched as well, with the files already in them
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			files := w.addTree(path)
+			for _, file := range files {
+				pending[file] = true
+			}
+			return len(files) > 0
+		}
+	}
+
+	if !relevant(path) {
+		return false
+	}
+	pending[path] = true
+	return true
+}
+
+// addTree watches a folder and its subfolders, returning the submission files found in them
+func (w *Watcher) addTree(dir string) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			w.onError(err)
+			return nil
+		}
+		if path != dir && ignored(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if err := w.fsw.Add(path); err != nil {
+				w.onError(fmt.Errorf("failed to watch %s: %w", path, err))
+			}
+			return nil
+		}
+		if relevant(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// stable reports whether none of the files changed size or time during the settle interval
+// and none is open in Excel
+func (w *Watcher) stable(ctx context.Context, paths []string) (bool, error) {
+	before := stamps(paths)
+
+	timer := time.NewTimer(w.settle)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	after := stamps(paths)
+	for _, path := range paths {
+		if before[path] != after[path] || after[path].open {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// stamps returns the current size and time of the files
+func stamps(paths []string) map[string]fileStamp {
+	result := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		var stamp fileStamp
+		if info, err := os.Stat(path); err == nil {
+			stamp.size = info.Size()
+			stamp.modTime = info.ModTime()
+			stamp.open = lock.ExcelOwnerFile(path) != ""
+		}
+		result[path] = stamp
+	}
+	return result
+}
+
+// resetTimer restarts a timer that may have fired without being read
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// ignored reports whether a name is an Office owner file, hidden file or macOS archive folder
+func ignored(name string) bool {
+	return strings.HasPrefix(name, "~$") || strings.HasPrefix(name, ".") || name == "__MACOSX"
+}
+
+// relevant reports whether a path may hold submissions
+func relevant(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx", ".xls", ".zip":
+		return true
+	}
+	return false
+}
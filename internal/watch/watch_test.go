@@ -0,0 +1,152 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startWatcher runs a watcher on dir, sending each batch and the trigger's result through channels
+func startWatcher(t *testing.T, dir string, results ...error) <-chan []string {
+	t.Helper()
+	w, err := New(dir, 50*time.Millisecond, 50*time.Millisecond, func(error) {})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan []string, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		calls := 0
+		w.Run(ctx, func(ctx context.Context, changed []string) error {
+			batches <- changed
+			calls++
+			if calls <= len(results) {
+				return results[calls-1]
+			}
+			return nil
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		w.Close()
+	})
+	return batches
+}
+
+// nextBatch waits for the next batch of changed paths
+func nextBatch(t *testing.T, batches <-chan []string) []string {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch reported")
+		return nil
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// TestWatcherDebouncesBursts tests that a burst of writes is reported as one batch without ignored files
+func TestWatcherDebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	batches := startWatcher(t, dir)
+
+	writeFile(t, filepath.Join(dir, "b.xlsx"), "1")
+	writeFile(t, filepath.Join(dir, "a.xlsx"), "1")
+	writeFile(t, filepath.Join(dir, "~$a.xlsx"), "owner")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "ignored")
+	os.Remove(filepath.Join(dir, "~$a.xlsx"))
+	writeFile(t, filepath.Join(dir, "a.xlsx"), "12")
+
+	want := []string{filepath.Join(dir, "a.xlsx"), filepath.Join(dir, "b.xlsx")}
+	if got := nextBatch(t, batches); !reflect.DeepEqual(got, want) {
+		t.Errorf("batch = %v, want %v", got, want)
+	}
+}
+
+// TestWatcherNewFolders tests that folders created after the start are watched with their files
+func TestWatcherNewFolders(t *testing.T) {
+	dir := t.TempDir()
+	batches := startWatcher(t, dir)
+
+	// A folder moved in with its content already inside
+	staging := filepath.Join(t.TempDir(), "alice")
+	writeFile(t, filepath.Join(staging, "grading.xlsx"), "1")
+	if err := os.Rename(staging, filepath.Join(dir, "alice")); err != nil {
+		t.Skipf("cannot move folders between temp directories: %v", err)
+	}
+	if got := nextBatch(t, batches); len(got) != 1 || got[0] != filepath.Join(dir, "alice", "grading.xlsx") {
+		t.Errorf("batch = %v, want the moved file", got)
+	}
+
+	// Changes inside the new folder are reported too
+	writeFile(t, filepath.Join(dir, "alice", "late.xlsx"), "1")
+	if got := nextBatch(t, batches); len(got) != 1 || got[0] != filepath.Join(dir, "alice", "late.xlsx") {
+		t.Errorf("batch = %v, want the file in the new folder", got)
+	}
+}
+
+// TestWatcherWaitsForOpenFiles tests that files open in Excel are only reported once closed
+func TestWatcherWaitsForOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	owner := filepath.Join(dir, "~$marks.xlsx")
+	writeFile(t, owner, "owner")
+	batches := startWatcher(t, dir)
+
+	writeFile(t, filepath.Join(dir, "marks.xlsx"), "1")
+	select {
+	case batch := <-batches:
+		t.Fatalf("batch %v reported while the file is open", batch)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	os.Remove(owner)
+	if got := nextBatch(t, batches); len(got) != 1 {
+		t.Errorf("batch = %v, want the closed file", got)
+	}
+}
+
+// TestWatcherRetriesFailedTrigger tests that paths stay pending when the trigger fails
+func TestWatcherRetriesFailedTrigger(t *testing.T) {
+	dir := t.TempDir()
+	batches := startWatcher(t, dir, errors.New("master sheet is locked"))
+
+	path := filepath.Join(dir, "a.xlsx")
+	writeFile(t, path, "1")
+	first := nextBatch(t, batches)
+	second := nextBatch(t, batches)
+	if !reflect.DeepEqual(first, []string{path}) || !reflect.DeepEqual(second, first) {
+		t.Errorf("batches = %v then %v, want %s twice", first, second, path)
+	}
+}
+
+// TestWatcherArchiveRoot tests watching a single archive as the student files folder
+func TestWatcherArchiveRoot(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "bulk.zip")
+	writeFile(t, archive, "1")
+	batches := startWatcher(t, archive)
+
+	writeFile(t, filepath.Join(dir, "other.xlsx"), "1")
+	writeFile(t, archive, "12")
+	if got := nextBatch(t, batches); !reflect.DeepEqual(got, []string{archive}) {
+		t.Errorf("batch = %v, want only the archive", got)
+	}
+}
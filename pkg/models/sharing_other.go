@@ -0,0 +1,9 @@
+//go:build !windows
+
+package models
+
+// sharingViolation reports whether err means another program has the file open or locked,
+// which other systems report as EAGAIN or EBUSY
+func sharingViolation(err error) bool {
+	return false
+}
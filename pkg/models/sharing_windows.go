@@ -0,0 +1,17 @@
+package models
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows errors of a file opened or locked by another program, such as Excel
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// sharingViolation reports whether err means another program has the file open or locked
+func sharingViolation(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
@@ -3,16 +3,23 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
 	"time"
 )
 
 // StudentData represents the extracted data from a student's Excel file
 type StudentData struct {
-	StudentID string             `json:"student_id"`
-	FilePath  string             `json:"file_path"`
-	Marks     map[string]float64 `json:"marks"`
-	Timestamp time.Time          `json:"timestamp"`
+	StudentID   string             `json:"student_id"`
+	StudentName string             `json:"student_name,omitempty"`
+	FilePath    string             `json:"file_path"`
+	Marks       map[string]float64 `json:"marks"`
+	Timestamp   time.Time          `json:"timestamp"`
 }
 
 // ProcessingResult represents the result of processing a single file
@@ -22,21 +29,68 @@ type ProcessingResult struct {
 	Success     bool          `json:"success"`
 	Error       error         `json:"error,omitempty"`
 	Duration    time.Duration `json:"duration"`
+	Resumed     bool          `json:"resumed,omitempty"`
 }
 
 // ProcessingSummary contains overall processing statistics
 type ProcessingSummary struct {
-	TotalFiles       int           `json:"total_files"`
-	SuccessfulFiles  int           `json:"successful_files"`
-	FailedFiles      int           `json:"failed_files"`
-	SkippedFiles     int           `json:"skipped_files"`
-	StudentsUpdated  int           `json:"students_updated"`
-	StudentsNotFound int           `json:"students_not_found"`
-	TotalDuration    time.Duration `json:"total_duration"`
-	StartTime        time.Time     `json:"start_time"`
-	EndTime          time.Time     `json:"end_time"`
-	Errors           []string      `json:"errors,omitempty"`
-	Warnings         []string      `json:"warnings,omitempty"`
+	RunID                string           `json:"run_id,omitempty"`
+	TotalFiles           int              `json:"total_files"`
+	SuccessfulFiles      int              `json:"successful_files"`
+	FailedFiles          int              `json:"failed_files"`
+	SkippedFiles         int              `json:"skipped_files"`
+	UnchangedFiles       int              `json:"unchanged_files,omitempty"`
+	ResumedFiles         int              `json:"resumed_files,omitempty"`
+	QuarantinedFiles     int              `json:"quarantined_files,omitempty"`
+	StudentsUpdated      int              `json:"students_updated"`
+	StudentsNotFound     int              `json:"students_not_found"`
+	StudentsAdded        int              `json:"students_added"`
+	FormulasRecalculated int              `json:"formulas_recalculated,omitempty"`
+	VerificationFailures int              `json:"verification_failures,omitempty"`
+	Cancelled            bool             `json:"cancelled,omitempty"`
+	CancelledFiles       int              `json:"cancelled_files,omitempty"`
+	TotalDuration        time.Duration    `json:"total_duration"`
+	StartTime            time.Time        `json:"start_time"`
+	EndTime              time.Time        `json:"end_time"`
+	JournalPath          string           `json:"journal_path,omitempty"`
+	Errors               []string         `json:"errors,omitempty"`
+	Warnings             []string         `json:"warnings,omitempty"`
+	SkippedPaths         []SkippedPath    `json:"skipped_paths,omitempty"`
+	Duplicates           []DuplicateGroup `json:"duplicates,omitempty"`
+}
+
+// Kinds of possible duplicate submissions
+const (
+	DuplicateIdenticalFile  = "identical_file"
+	DuplicateIdenticalMarks = "identical_marks"
+	DuplicateRepeatedFile   = "repeated_file" // one student ID, e.g. a double upload or an unedited copy
+)
+
+// DuplicateGroup lists submissions that look copied from each other, for the module leader to check
+type DuplicateGroup struct {
+	Kind     string             `json:"kind"`
+	Hash     string             `json:"hash"`
+	Students []string           `json:"students"`
+	Files    []string           `json:"files"`
+	Marks    map[string]float64 `json:"marks,omitempty"`
+}
+
+// String describes the group for the duplicates section of the summary
+func (g DuplicateGroup) String() string {
+	label := "Identical marks"
+	switch g.Kind {
+	case DuplicateIdenticalFile:
+		label = "Identical files"
+	case DuplicateRepeatedFile:
+		label = "Same file handed in more than once"
+	}
+	return fmt.Sprintf("%s: %s", label, strings.Join(g.Students, ", "))
+}
+
+// SkippedPath is a file or folder left out of discovery, with the reason
+type SkippedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
 }
 
 // ValidationError represents a validation error with context
@@ -52,6 +106,14 @@ func (e ValidationError) Error() string {
 		e.File, e.Field, e.Value, e.Message)
 }
 
+// Retryable reports false: reading the same file again gives the same invalid value
+func (e ValidationError) Retryable() bool {
+	return false
+}
+
+// StageTimeout is the stage of a file whose reading overran the per-file timeout
+const StageTimeout = "timeout"
+
 // FileProcessingError represents an error during file processing
 type FileProcessingError struct {
 	FilePath string `json:"file_path"`
@@ -69,6 +131,39 @@ func (e FileProcessingError) Error() string {
 		e.Stage, e.FilePath, e.Message)
 }
 
+// Retryable reports whether the file could not be opened because of a transient I/O failure,
+// such as a file still being copied or locked by another program. Problems with the content
+// of the file, like a missing worksheet, are never retried.
+func (e FileProcessingError) Retryable() bool {
+	return e.Stage == "opening" && transientIO(e.Cause)
+}
+
+// IsRetryable reports whether reading a file again may succeed after err.
+// Errors without a classification are retried only when they look like transient I/O failures.
+func IsRetryable(err error) bool {
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+	return transientIO(err)
+}
+
+// transientIO reports whether err is an I/O failure that may go away, such as a file still being
+// copied or locked by another program, as opposed to a missing file or one that may not be read
+func transientIO(err error) bool {
+	if err == nil || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrInvalid) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY) || sharingViolation(err)
+}
+
 // IsValidStudentID checks if a student ID is valid (alphanumeric, not empty)
 func (s *StudentData) IsValidStudentID() bool {
 	if s.StudentID == "" {
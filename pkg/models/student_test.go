@@ -1,6 +1,11 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -174,6 +179,42 @@ func TestFileProcessingError_Error(t *testing.T) {
 	}
 }
 
+// TestIsRetryable tests that only transient I/O failures are retried
+func TestIsRetryable(t *testing.T) {
+	busy := &fs.PathError{Op: "open", Path: "/path/to/file.xlsx", Err: syscall.EBUSY}
+	missing := &fs.PathError{Op: "open", Path: "/path/to/file.xlsx", Err: fs.ErrNotExist}
+	denied := &fs.PathError{Op: "open", Path: "/path/to/file.xlsx", Err: fs.ErrPermission}
+	invalid := &fs.PathError{Op: "open", Path: "/path/to/file.xlsx", Err: fs.ErrInvalid}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"validation error", &ValidationError{Field: "student_id", Message: "student ID is empty"}, false},
+		{"missing worksheet", &FileProcessingError{Stage: "worksheet_validation", Message: "worksheet 'Grading Sheet' not found"}, false},
+		{"file in use", &FileProcessingError{Stage: "opening", Cause: busy}, true},
+		{"truncated file", &FileProcessingError{Stage: "opening", Cause: io.ErrUnexpectedEOF}, true},
+		{"missing file", &FileProcessingError{Stage: "opening", Cause: missing}, false},
+		{"permission denied", &FileProcessingError{Stage: "opening", Cause: denied}, false},
+		{"invalid argument", &FileProcessingError{Stage: "opening", Cause: invalid}, false},
+		{"would block", &FileProcessingError{Stage: "opening", Cause: &fs.PathError{Op: "read", Err: syscall.EAGAIN}}, true},
+		{"other I/O failure", &FileProcessingError{Stage: "opening", Cause: &fs.PathError{Op: "open", Err: errors.New("is a directory")}}, false},
+		{"corrupt file", &FileProcessingError{Stage: "opening", Cause: errors.New("zip: not a valid zip file")}, false},
+		{"wrapped", fmt.Errorf("reading: %w", FileProcessingError{Stage: "opening", Cause: busy}), true},
+		{"unclassified I/O", busy, true},
+		{"unclassified", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestProcessingSummary(t *testing.T) {
 	summary := &ProcessingSummary{
 		TotalFiles:       100,